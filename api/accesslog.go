@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/handlers"
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+// accessLog writes access logs in the common, combined or json format,
+// optionally only for some of the services resolved by the gateway
+type accessLog struct {
+	out io.Writer
+	// common, combined or json
+	format string
+	// log requests by default
+	enabled bool
+	// services always logged
+	include map[string]bool
+	// services never logged
+	exclude map[string]bool
+}
+
+func newAccessLog(out io.Writer, format string, enabled bool, include, exclude []string) *accessLog {
+	a := &accessLog{
+		out:     out,
+		format:  format,
+		enabled: enabled,
+		include: make(map[string]bool),
+		exclude: make(map[string]bool),
+	}
+	for _, s := range include {
+		a.include[s] = true
+	}
+	for _, s := range exclude {
+		a.exclude[s] = true
+	}
+	return a
+}
+
+// service returns the name of the service the request was resolved to
+func service(r *http.Request) string {
+	if ep, ok := r.Context().Value(resolver.Endpoint{}).(*resolver.Endpoint); ok && ep != nil {
+		return ep.Name
+	}
+	return ""
+}
+
+// log determines whether a request to the service should be logged
+func (a *accessLog) log(service string) bool {
+	if a.exclude[service] {
+		return false
+	}
+	if a.include[service] {
+		return true
+	}
+	return a.enabled
+}
+
+// accessLogEntry is a json access log line
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RemoteAddr string  `json:"remote_addr"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Proto      string  `json:"proto"`
+	Service    string  `json:"service,omitempty"`
+	Status     int     `json:"status"`
+	Bytes      int     `json:"bytes"`
+	DurationMs float64 `json:"duration_ms"`
+	UserAgent  string  `json:"user_agent,omitempty"`
+}
+
+// sizeRecorder keeps the status code and the size of the response body
+type sizeRecorder struct {
+	*statusRecorder
+	size int
+}
+
+func (w *sizeRecorder) Write(b []byte) (int, error) {
+	n, err := w.statusRecorder.Write(b)
+	w.size += n
+	return n, err
+}
+
+// serveJSON serves the request, writing a json log line to the buffer
+func (a *accessLog) serveJSON(buf *bytes.Buffer, h http.Handler, w http.ResponseWriter, r *http.Request) {
+	// handlers may rewrite the request
+	entry := accessLogEntry{
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Proto:      r.Proto,
+		UserAgent:  r.UserAgent(),
+	}
+
+	start := time.Now()
+	sw := &sizeRecorder{statusRecorder: &statusRecorder{ResponseWriter: w, status: http.StatusOK}}
+	h.ServeHTTP(sw, r)
+
+	entry.Time = start.UTC().Format(time.RFC3339Nano)
+	entry.Service = service(r)
+	entry.Status = sw.status
+	entry.Bytes = sw.size
+	entry.DurationMs = float64(time.Since(start)) / float64(time.Millisecond)
+	json.NewEncoder(buf).Encode(entry)
+}
+
+func (a *accessLog) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the service is only known once the request is resolved
+		// so buffer the log line until the request completes
+		var buf bytes.Buffer
+		switch a.format {
+		case "json":
+			a.serveJSON(&buf, h, w, r)
+		case "common":
+			handlers.LoggingHandler(&buf, h).ServeHTTP(w, r)
+		default:
+			handlers.CombinedLoggingHandler(&buf, h).ServeHTTP(w, r)
+		}
+
+		if a.log(service(r)) {
+			a.out.Write(buf.Bytes())
+		}
+	})
+}
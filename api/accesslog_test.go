@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+func TestAccessLog(t *testing.T) {
+	testData := []struct {
+		enabled bool
+		include []string
+		exclude []string
+		service string
+		logged  bool
+	}{
+		{true, nil, nil, "greeter", true},
+		{false, nil, nil, "greeter", false},
+		{false, []string{"greeter"}, nil, "greeter", true},
+		{true, nil, []string{"greeter"}, "greeter", false},
+		{true, nil, []string{"greeter"}, "other", true},
+		// requests which don't resolve to a service follow the default
+		{false, []string{"greeter"}, nil, "", false},
+	}
+
+	for _, d := range testData {
+		var buf bytes.Buffer
+		a := newAccessLog(&buf, "combined", d.enabled, d.include, d.exclude)
+
+		// the service is resolved by the auth wrapper within the access log
+		h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(d.service) > 0 {
+				ep := &resolver.Endpoint{Name: d.service}
+				*r = *r.Clone(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/greeter/say", nil))
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected the response to be written got %d", w.Code)
+		}
+		if logged := buf.Len() > 0; logged != d.logged {
+			t.Fatalf("Expected request to %q logged to be %v got %q", d.service, d.logged, buf.String())
+		}
+		if d.logged && !strings.Contains(buf.String(), `"GET /greeter/say HTTP/1.1" 202`) {
+			t.Fatalf("Expected combined log line got %q", buf.String())
+		}
+	}
+}
+
+func TestAccessLogFormat(t *testing.T) {
+	testData := []struct {
+		format string
+		line   string
+	}{
+		{"common", `"GET /greeter/say HTTP/1.1" 202 2`},
+		{"json", `"method":"GET","path":"/greeter/say","proto":"HTTP/1.1","service":"greeter","status":202,"bytes":2`},
+	}
+
+	for _, d := range testData {
+		var buf bytes.Buffer
+		h := newAccessLog(&buf, d.format, true, nil, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ep := &resolver.Endpoint{Name: "greeter"}
+			*r = *r.Clone(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("ok"))
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/greeter/say", nil))
+
+		if !strings.Contains(buf.String(), d.line) {
+			t.Fatalf("Expected %s log line to contain %s got %q", d.format, d.line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/server/acme"
+	log "github.com/micro/go-micro/v2/logger"
+	xacme "golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// autocertProvider is the autocert ACME provider with a timeout on the
+// requests made to the ACME server so a slow server can't stall
+// certificate operations indefinitely
+type autocertProvider struct {
+	timeout time.Duration
+}
+
+func newAutocertProvider(timeout time.Duration) acme.Provider {
+	return &autocertProvider{timeout: timeout}
+}
+
+// manager returns a certificate manager for the hosts
+func (a *autocertProvider) manager(hosts ...string) *autocert.Manager {
+	m := &autocert.Manager{
+		Prompt: autocert.AcceptTOS,
+		Client: &xacme.Client{
+			DirectoryURL: autocert.DefaultACMEDirectory,
+			HTTPClient:   &http.Client{Timeout: a.timeout},
+		},
+	}
+	if len(hosts) > 0 {
+		m.HostPolicy = autocert.HostWhitelist(hosts...)
+	}
+
+	// cache certificates where the autocert listener does
+	dir, err := os.UserCacheDir()
+	if err == nil {
+		dir = filepath.Join(dir, "golang-autocert")
+		err = os.MkdirAll(dir, 0700)
+	}
+	if err != nil {
+		log.Warnf("autocert not using a cache: %v", err)
+	} else {
+		m.Cache = autocert.DirCache(dir)
+	}
+
+	return m
+}
+
+// Listen listens on the standard TLS port
+func (a *autocertProvider) Listen(hosts ...string) (net.Listener, error) {
+	return a.manager(hosts...).Listener(), nil
+}
+
+// TLSConfig returns a tls config fetching certificates for the hosts
+func (a *autocertProvider) TLSConfig(hosts ...string) (*tls.Config, error) {
+	return a.manager(hosts...).TLSConfig(), nil
+}
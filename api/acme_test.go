@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAutocertProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "autocert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("XDG_CACHE_HOME", dir)
+	defer os.Unsetenv("XDG_CACHE_HOME")
+
+	p := newAutocertProvider(time.Second * 5).(*autocertProvider)
+	m := p.manager("example.com")
+
+	if m.Client.HTTPClient.Timeout != time.Second*5 {
+		t.Fatalf("Expected ACME client timeout of 5s got %v", m.Client.HTTPClient.Timeout)
+	}
+	if m.Cache == nil {
+		t.Fatal("Expected certificates to be cached")
+	}
+	if err := m.HostPolicy(context.Background(), "other.com"); err == nil {
+		t.Fatal("Expected host not configured to be rejected")
+	}
+}
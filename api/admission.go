@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// admission rejects requests to services without nodes in the registry
+// with a 503 once they're resolved, rather than forwarding them to wait
+// for the call to time out. Requests are let through when the registry
+// can't be read so an outage of the registry doesn't fail every request.
+type admission struct {
+	registry  registry.Registry
+	namespace string
+}
+
+func newAdmission(reg registry.Registry, namespace string) *admission {
+	return &admission{
+		registry:  reg,
+		namespace: namespace,
+	}
+}
+
+// available determines whether the service has nodes to serve requests
+func (a *admission) available(name string) bool {
+	for _, n := range []string{name, a.namespace + "." + name} {
+		services, err := a.registry.GetService(n)
+		if err == registry.ErrNotFound {
+			continue
+		} else if err != nil {
+			return true
+		}
+		for _, s := range services {
+			if len(s.Nodes) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *admission) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := service(r); len(name) > 0 && !a.available(name) {
+			writeError(w, fmt.Sprintf("Service %s unavailable", name), http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// downRegistry can't be read
+type downRegistry struct {
+	registry.Registry
+}
+
+func (r *downRegistry) GetService(name string) ([]*registry.Service, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestAdmission(t *testing.T) {
+	reg := &testRegistry{services: map[string][]*registry.Service{
+		"go.micro.api.greeter": {{
+			Name:  "go.micro.api.greeter",
+			Nodes: []*registry.Node{{Id: "greeter-1", Address: "10.0.0.1:8080"}},
+		}},
+		"go.micro.api.stopped": {{Name: "go.micro.api.stopped"}},
+	}}
+
+	testData := []struct {
+		registry registry.Registry
+		service  string
+		code     int
+	}{
+		{reg, "greeter", http.StatusOK},
+		{reg, "go.micro.api.greeter", http.StatusOK},
+		{reg, "stopped", http.StatusServiceUnavailable},
+		{reg, "missing", http.StatusServiceUnavailable},
+		// unresolved requests are let through
+		{reg, "", http.StatusOK},
+		// as are requests when the registry is down
+		{&downRegistry{}, "greeter", http.StatusOK},
+	}
+
+	for _, d := range testData {
+		h := newAdmission(d.registry, "go.micro.api").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		if len(d.service) > 0 {
+			ep := &resolver.Endpoint{Name: d.service}
+			r = r.WithContext(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %q got %d", d.code, d.service, w.Code)
+		}
+	}
+}
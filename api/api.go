@@ -2,14 +2,20 @@
 package api
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/go-acme/lego/v3/challenge"
 	"github.com/go-acme/lego/v3/providers/dns/cloudflare"
+	"github.com/go-acme/lego/v3/providers/dns/route53"
 	"github.com/gorilla/mux"
+	cmagic "github.com/mholt/certmagic"
 	"github.com/micro/cli/v2"
 	"github.com/micro/go-micro/v2"
 	ahandler "github.com/micro/go-micro/v2/api/handler"
@@ -26,27 +32,35 @@ import (
 	regRouter "github.com/micro/go-micro/v2/api/router/registry"
 	"github.com/micro/go-micro/v2/api/server"
 	"github.com/micro/go-micro/v2/api/server/acme"
-	"github.com/micro/go-micro/v2/api/server/acme/autocert"
 	"github.com/micro/go-micro/v2/api/server/acme/certmagic"
-	httpapi "github.com/micro/go-micro/v2/api/server/http"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/debug/trace"
 	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/registry/cache"
+	"github.com/micro/go-micro/v2/store"
 	"github.com/micro/go-micro/v2/sync/memory"
 	"github.com/micro/micro/v2/api/auth"
+	"github.com/micro/micro/v2/internal/errorformat"
 	"github.com/micro/micro/v2/internal/handler"
 	"github.com/micro/micro/v2/internal/helper"
 	"github.com/micro/micro/v2/internal/namespace"
 	cfstore "github.com/micro/micro/v2/internal/plugins/store/cloudflare"
+	s3store "github.com/micro/micro/v2/internal/plugins/store/s3"
 	rrmicro "github.com/micro/micro/v2/internal/resolver/api"
 	"github.com/micro/micro/v2/internal/stats"
 	"github.com/micro/micro/v2/plugin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // 如果执行micro api 命令是，没有通过命令行参数指定这些参数值，则使用下列的默认值
 var (
 	Name                  = "go.micro.api"                    // 用于设置API网关服务器的名称
 	Address               = ":8080"                           // API网关监听的端口号，客户端根据 API 网关的公网 IP 和这个端口号即可与这个 API 网关进行通信
+	InternalAddress       = ""
 	Handler               = "meta"                            // 用于设置 API 网关的请求处理器，默认是 meta，这些处理器可用于决定请求路由如何处理，Go Micro 支持的所有处理器可以查看这里：micro/go-micro/api/handler
 	Resolver              = "micro"                           // 用于将 HTTP 请求路由映射到对应的后端 API 服务接口，默认是 micro，和 handler 类似，你可以到 micro/go-micro/api/resolver 路径下查看 Go Micro 支持的所有解析器
+	ResolverWeights       = []string{}
 	RPCPath               = "/rpc"
 	APIPath               = "/"
 	ProxyPath             = "/{service:[a-zA-Z0-9]+}"
@@ -54,9 +68,159 @@ var (
 	Type                  = "api"
 	HeaderPrefix          = "X-Micro-"
 	EnableRPC             = false
+	DisableVersion        = false
+	DisableFaviconStrip   = false
 	ACMEProvider          = "autocert"
 	ACMEChallengeProvider = "cloudflare"
+	ACMEStorage           = ""
+	ACMEStorageDir        = ""
 	ACMECA                = acme.LetsEncryptProductionCA
+	ACMETimeout           = 30 * time.Second
+	ServeStaleOnError     = false
+	ServeStaleMaxAge      = time.Hour
+	ServeStaleMethods     = ""
+	PathCase              = []string{}
+	MaxPathDepth          = 32
+	PathRewrites          []string
+	Maintenance           = false
+	MaintenanceAllowlist  = []string{}
+	MaintenanceKeys       = []string{}
+	ErrorFormat           = ""
+	ErrorTemplate         = ""
+	TCPNoDelay            = true
+	TCPReadBuffer         = 0
+	TCPWriteBuffer        = 0
+	ShedLatencyTarget     = time.Duration(0)
+	ShedAggressiveness    = 1.0
+	ExpectContinue        = "gateway"
+	ExpectMaxSize         = int64(0)
+	MaxRequestBody        = int64(32 << 20)
+	MaxProxyRequestBody   = int64(0)
+	FormToJSONPaths       = []string{}
+	DuplicateHeaderPolicy = "reject"
+	DuplicateHeaders      = []string{"Authorization", "Proxy-Authorization", "Content-Type"}
+	AccessLog             = true
+	AccessLogInclude      = []string{}
+	AccessLogExclude      = []string{}
+	AccessLogFormat       = "combined"
+	DefaultContentType    = "application/json"
+	DisableSniffing       = false
+	RouteConflict         = ""
+	CaptureRatio          = 0.0
+	CapturePaths          = []string{}
+	CaptureRedact         = []string{}
+	CaptureMaxBody        = int64(64 * 1024)
+	FollowRedirects       = false
+	MaxRedirects          = 10
+	EnableCompression     = false
+	CompressionLevel      = -1
+	CompressionMinSize    = 1024
+	CompressionSkipPaths  = []string{}
+	ChunkedPolicies       = []string{}
+	ChunkedBufferMax      = 10 << 20
+	ResponseHeaders       = []string{}
+	ForceResponseHeaders  = false
+	CompressionSkipTypes  = []string{"image/", "video/", "audio/", "application/zip", "application/gzip", "application/x-gzip"}
+	UpgradeAllowlist      []string
+	TimingHeaders         = false
+	BackendLimits         = false
+	RejectUnavailable     = false
+	MethodNotAllowed      = false
+	MethodNotAllowedMsg   = "Method not allowed"
+	RateLimitHeaders      = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+	RateLimit             = float64(0)
+	RateLimitBurst        = 0
+	RateLimitKey          = ""
+	TenantRateLimit       = float64(0)
+	TenantRateLimitBurst  = 0
+	TenantRateLimitKey    = ""
+	TenantRateLimits      []string
+	RequestSigningKeys    []string
+	AuthAPIKeys           = ""
+	AuthAPIKeyHeader      = "X-Api-Key"
+	Region                = ""
+	FailoverRegions       = []string{}
+	Zone                  = ""
+	ZoneFallback          = "any"
+	SelectorStrategy      = "random"
+	AffinityKey           = ""
+	RetryBufferSize       = int64(0)
+	RetryAttempts         = 1
+	RetryCount            = 1
+	RetryBackoff          = 100 * time.Millisecond
+	RequestTimeout        = time.Duration(0)
+	StatsDimensions       = []string{}
+	HeadMode              = "auto"
+	HeadGetPaths          = []string{}
+	EnableChaos           = false
+	RequestIDHeader       = "X-Request-Id"
+	RequestIDFormat       = "uuid"
+	RequestIDPrefix       = ""
+	RequestIDPattern      = ""
+	CORSSameOriginBypass  = false
+	CORSAllowedOrigins    = []string{}
+	CORSAllowedMethods    = []string{}
+	CORSAllowedHeaders    = []string{}
+	SizeRoutes            = []string{}
+	SizeRouteUnknown      = "small"
+	ShutdownOrder         = "service"
+	ShutdownDrainTimeout  = 30 * time.Second
+	ShutdownDelay         = time.Duration(0)
+	TLSNextProtos         = []string{}
+	ProxyBufferSize       = 32 * 1024
+	BackendMaxHeaderBytes = int64(1 << 20)
+	DialTimeout           = time.Duration(0)
+	ReadTimeout           = 60 * time.Second
+	WriteTimeout          = 60 * time.Second
+	IdleTimeout           = 120 * time.Second
+	HealthPath            = "/healthz"
+	ReadyPath             = "/readyz"
+	EnableTracing         = false
+	TraceSampleRate       = 1.0
+	TraceSampleRates      = []string{}
+	TracePathSampleRates  = ""
+	TraceOTLPEndpoint     = ""
+	MetricsExemplars      = false
+	SLOThresholds         = []string{}
+	SLOWindow             = 5 * time.Minute
+	RequestEncodings      = []string{}
+	DecompressRequests    = false
+	DecompressMaxSize     = int64(10 << 20)
+	CompositeConfig       = ""
+	TLSMinVersion         = "1.2"
+	TLSCipherSuites       = []string{}
+	TLSClientCA           = ""
+	TLSClientAuth         = ""
+	TLSInfoHeaders        = false
+	TLSCheckRevocation    = false
+	TLSCRLURL             = ""
+	TLSOCSPResponder      = ""
+	TLSRevocationRefresh  = time.Hour
+	TLSRevocationStrict   = false
+	NamespaceOverrides    = []string{}
+	HTTP2MaxStreams       = uint32(250)
+	ConnMaxRequestRate    = 0
+	ConnMaxResetRate      = 100
+	AdminToken            = ""
+	EnablePprof           = false
+	PprofAddress          = "127.0.0.1:6060"
+	DecodeResponses       = false
+	BulkheadLimit         = 0
+	BulkheadLimits        = []string{}
+	BulkheadQueueTimeout  = time.Second
+	RequireHTTPS          = false
+	HTTPSPolicy           = "redirect"
+	TrustedProxies        = []string{}
+	TemplateRoutes        = ""
+	QueryDefaults         = ""
+	BodySchemas           = ""
+	RequiredHeaders       = ""
+	StatusRewrites        = ""
+	Rollouts              = []string{}
+	RolloutStep           = 10
+	RolloutInterval       = 5 * time.Minute
+	RolloutMaxErrorRate   = 0.05
+	RolloutMinRequests    = 20
 )
 
 // 在该函数中，首先读取命令参数并将其赋值给全局变量，比如 address、handler、name（server_name）、resolver、namespace 等
@@ -69,21 +233,486 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	if len(ctx.String("address")) > 0 {
 		Address = ctx.String("address")
 	}
+	if len(ctx.String("internal_address")) > 0 {
+		InternalAddress = ctx.String("internal_address")
+	}
 	if len(ctx.String("handler")) > 0 {
 		Handler = ctx.String("handler")
 	}
 	if len(ctx.String("resolver")) > 0 {
 		Resolver = ctx.String("resolver")
 	}
+	if len(ctx.String("resolver_weights")) > 0 {
+		ResolverWeights = splitList(ctx.String("resolver_weights"))
+	}
+	if ctx.IsSet("disable_version_endpoint") {
+		DisableVersion = ctx.Bool("disable_version_endpoint")
+	}
+	if ctx.IsSet("disable_favicon_strip") {
+		DisableFaviconStrip = ctx.Bool("disable_favicon_strip")
+	}
 	if len(ctx.String("enable_rpc")) > 0 {
 		EnableRPC = ctx.Bool("enable_rpc")
 	}
+	if len(ctx.String("rpc_path")) > 0 {
+		RPCPath = ctx.String("rpc_path")
+		if !strings.HasPrefix(RPCPath, "/") {
+			log.Fatalf("%s is not a valid rpc path\n", RPCPath)
+		}
+	}
 	if len(ctx.String("acme_provider")) > 0 {
 		ACMEProvider = ctx.String("acme_provider")
 	}
+	if len(ctx.String("acme_challenge_provider")) > 0 {
+		ACMEChallengeProvider = ctx.String("acme_challenge_provider")
+	}
+	if len(ctx.String("acme_storage")) > 0 {
+		ACMEStorage = ctx.String("acme_storage")
+	}
+	if len(ctx.String("acme_storage_dir")) > 0 {
+		ACMEStorageDir = ctx.String("acme_storage_dir")
+	}
 	if len(ctx.String("type")) > 0 {
 		Type = ctx.String("type")
 	}
+	if ctx.IsSet("tcp_nodelay") {
+		TCPNoDelay = ctx.Bool("tcp_nodelay")
+	}
+	if i := ctx.Int("tcp_read_buffer"); i > 0 {
+		TCPReadBuffer = i
+	}
+	if i := ctx.Int("tcp_write_buffer"); i > 0 {
+		TCPWriteBuffer = i
+	}
+	if d := ctx.Duration("shed_latency_target"); d > 0 {
+		ShedLatencyTarget = d
+	}
+	if f := ctx.Float64("shed_aggressiveness"); f > 0 {
+		ShedAggressiveness = f
+	}
+	if len(ctx.String("expect_continue")) > 0 {
+		ExpectContinue = ctx.String("expect_continue")
+	}
+	if i := ctx.Int64("expect_max_size"); i > 0 {
+		ExpectMaxSize = i
+	}
+	if ctx.IsSet("max_request_body") {
+		MaxRequestBody = ctx.Int64("max_request_body")
+	}
+	if i := ctx.Int64("max_proxy_request_body"); i > 0 {
+		MaxProxyRequestBody = i
+	}
+	if len(ctx.String("form_to_json_paths")) > 0 {
+		FormToJSONPaths = splitList(ctx.String("form_to_json_paths"))
+	}
+	if len(ctx.String("duplicate_header_policy")) > 0 {
+		DuplicateHeaderPolicy = ctx.String("duplicate_header_policy")
+	}
+	if len(ctx.String("duplicate_headers")) > 0 {
+		DuplicateHeaders = splitList(ctx.String("duplicate_headers"))
+	}
+	if ctx.IsSet("access_log") {
+		AccessLog = ctx.Bool("access_log")
+	}
+	if len(ctx.String("access_log_include")) > 0 {
+		AccessLogInclude = splitList(ctx.String("access_log_include"))
+	}
+	if len(ctx.String("access_log_exclude")) > 0 {
+		AccessLogExclude = splitList(ctx.String("access_log_exclude"))
+	}
+	if len(ctx.String("access_log_format")) > 0 {
+		AccessLogFormat = ctx.String("access_log_format")
+	}
+	if len(ctx.String("default_content_type")) > 0 {
+		DefaultContentType = ctx.String("default_content_type")
+	}
+	if ctx.IsSet("disable_content_sniffing") {
+		DisableSniffing = ctx.Bool("disable_content_sniffing")
+	}
+	if len(ctx.String("route_conflict")) > 0 {
+		RouteConflict = ctx.String("route_conflict")
+	}
+	if f := ctx.Float64("capture_ratio"); f > 0 {
+		CaptureRatio = f
+	}
+	if len(ctx.String("capture_paths")) > 0 {
+		CapturePaths = splitList(ctx.String("capture_paths"))
+	}
+	if len(ctx.String("capture_redact")) > 0 {
+		CaptureRedact = splitList(ctx.String("capture_redact"))
+	}
+	if i := ctx.Int64("capture_max_body"); i > 0 {
+		CaptureMaxBody = i
+	}
+	if ctx.IsSet("follow_redirects") {
+		FollowRedirects = ctx.Bool("follow_redirects")
+	}
+	if i := ctx.Int("max_redirects"); i > 0 {
+		MaxRedirects = i
+	}
+	if ctx.IsSet("enable_compression") {
+		EnableCompression = ctx.Bool("enable_compression")
+	}
+	if ctx.IsSet("compression_level") {
+		CompressionLevel = ctx.Int("compression_level")
+		if err := validLevel(CompressionLevel); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if ctx.IsSet("compression_min_size") {
+		CompressionMinSize = ctx.Int("compression_min_size")
+	}
+	if len(ctx.String("compression_skip_paths")) > 0 {
+		CompressionSkipPaths = splitList(ctx.String("compression_skip_paths"))
+	}
+	if len(ctx.String("chunked_policies")) > 0 {
+		ChunkedPolicies = splitList(ctx.String("chunked_policies"))
+	}
+	if i := ctx.Int("chunked_buffer_max"); i > 0 {
+		ChunkedBufferMax = i
+	}
+	if len(ctx.StringSlice("response_headers")) > 0 {
+		ResponseHeaders = ctx.StringSlice("response_headers")
+	}
+	if ctx.IsSet("force_response_headers") {
+		ForceResponseHeaders = ctx.Bool("force_response_headers")
+	}
+	if len(ctx.String("compression_skip_types")) > 0 {
+		CompressionSkipTypes = splitList(ctx.String("compression_skip_types"))
+	}
+	if len(ctx.String("upgrade_allowlist")) > 0 {
+		UpgradeAllowlist = splitList(ctx.String("upgrade_allowlist"))
+	}
+	if ctx.IsSet("enable_timing_headers") {
+		TimingHeaders = ctx.Bool("enable_timing_headers")
+	}
+	if ctx.IsSet("enforce_backend_limits") {
+		BackendLimits = ctx.Bool("enforce_backend_limits")
+	}
+	if ctx.IsSet("reject_unavailable") {
+		RejectUnavailable = ctx.Bool("reject_unavailable")
+	}
+	if ctx.IsSet("method_not_allowed") {
+		MethodNotAllowed = ctx.Bool("method_not_allowed")
+	}
+	if len(ctx.String("method_not_allowed_message")) > 0 {
+		MethodNotAllowedMsg = ctx.String("method_not_allowed_message")
+	}
+	if len(ctx.String("region")) > 0 {
+		Region = ctx.String("region")
+	}
+	if len(ctx.String("failover_regions")) > 0 {
+		FailoverRegions = splitList(ctx.String("failover_regions"))
+	}
+	if len(ctx.String("zone")) > 0 {
+		Zone = ctx.String("zone")
+	}
+	if len(ctx.String("zone_fallback")) > 0 {
+		ZoneFallback = ctx.String("zone_fallback")
+	}
+	if len(ctx.String("selector_strategy")) > 0 {
+		SelectorStrategy = ctx.String("selector_strategy")
+	}
+	if len(ctx.String("affinity_key")) > 0 {
+		AffinityKey = ctx.String("affinity_key")
+	}
+	if i := ctx.Int64("retry_buffer_size"); i > 0 {
+		RetryBufferSize = i
+	}
+	if ctx.IsSet("retry_attempts") {
+		RetryAttempts = ctx.Int("retry_attempts")
+	}
+	if ctx.IsSet("retry_count") {
+		RetryCount = ctx.Int("retry_count")
+	}
+	if d := ctx.Duration("retry_backoff"); d > 0 {
+		RetryBackoff = d
+	}
+	if d := ctx.Duration("request_timeout"); d > 0 {
+		RequestTimeout = d
+	}
+	if len(ctx.String("stats_dimensions")) > 0 {
+		StatsDimensions = splitList(ctx.String("stats_dimensions"))
+	}
+	if len(ctx.String("head_mode")) > 0 {
+		HeadMode = ctx.String("head_mode")
+	}
+	if len(ctx.String("head_get_paths")) > 0 {
+		HeadGetPaths = splitList(ctx.String("head_get_paths"))
+	}
+	if ctx.IsSet("enable_chaos") {
+		EnableChaos = ctx.Bool("enable_chaos")
+	}
+	if len(ctx.String("size_routes")) > 0 {
+		SizeRoutes = splitList(ctx.String("size_routes"))
+	}
+	if len(ctx.String("size_route_unknown")) > 0 {
+		SizeRouteUnknown = ctx.String("size_route_unknown")
+	}
+	if len(ctx.String("shutdown_order")) > 0 {
+		ShutdownOrder = ctx.String("shutdown_order")
+	}
+	if ctx.IsSet("shutdown_drain_timeout") {
+		ShutdownDrainTimeout = ctx.Duration("shutdown_drain_timeout")
+	}
+	if d := ctx.Duration("shutdown_delay"); d > 0 {
+		ShutdownDelay = d
+	}
+	if len(ctx.String("tls_next_protos")) > 0 {
+		TLSNextProtos = splitList(ctx.String("tls_next_protos"))
+	}
+	if i := ctx.Int("proxy_buffer_size"); i > 0 {
+		ProxyBufferSize = i
+	}
+	if i := ctx.Int64("backend_max_header_bytes"); i > 0 {
+		BackendMaxHeaderBytes = i
+	}
+	if d := ctx.Duration("dial_timeout"); d > 0 {
+		DialTimeout = d
+	}
+	if ctx.IsSet("read_timeout") {
+		ReadTimeout = ctx.Duration("read_timeout")
+	}
+	if ctx.IsSet("write_timeout") {
+		WriteTimeout = ctx.Duration("write_timeout")
+	}
+	if ctx.IsSet("idle_timeout") {
+		IdleTimeout = ctx.Duration("idle_timeout")
+	}
+	if len(ctx.String("health_path")) > 0 {
+		HealthPath = ctx.String("health_path")
+	}
+	if len(ctx.String("ready_path")) > 0 {
+		ReadyPath = ctx.String("ready_path")
+	}
+	if ctx.IsSet("enable_tracing") {
+		EnableTracing = ctx.Bool("enable_tracing")
+	}
+	if ctx.IsSet("trace_sample_rate") {
+		TraceSampleRate = ctx.Float64("trace_sample_rate")
+		if err := validRate(TraceSampleRate); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(ctx.String("trace_sample_rates")) > 0 {
+		TraceSampleRates = splitList(ctx.String("trace_sample_rates"))
+	}
+	if len(ctx.String("trace_path_sample_rates")) > 0 {
+		TracePathSampleRates = ctx.String("trace_path_sample_rates")
+	}
+	if len(ctx.String("trace_otlp_endpoint")) > 0 {
+		TraceOTLPEndpoint = ctx.String("trace_otlp_endpoint")
+	}
+	if ctx.IsSet("metrics_exemplars") {
+		MetricsExemplars = ctx.Bool("metrics_exemplars")
+	}
+	if len(ctx.String("slo_thresholds")) > 0 {
+		SLOThresholds = splitList(ctx.String("slo_thresholds"))
+	}
+	if ctx.IsSet("slo_window") {
+		SLOWindow = ctx.Duration("slo_window")
+		if SLOWindow < time.Minute {
+			log.Fatalf("%v is not a valid slo window, it must be at least a minute\n", SLOWindow)
+		}
+	}
+	if len(ctx.String("request_encodings")) > 0 {
+		RequestEncodings = splitList(ctx.String("request_encodings"))
+	}
+	if ctx.IsSet("decompress_requests") {
+		DecompressRequests = ctx.Bool("decompress_requests")
+	}
+	if i := ctx.Int64("decompress_max_size"); i > 0 {
+		DecompressMaxSize = i
+	}
+	if len(ctx.String("composite_config")) > 0 {
+		CompositeConfig = ctx.String("composite_config")
+	}
+	if len(ctx.String("tls_min_version")) > 0 {
+		TLSMinVersion = ctx.String("tls_min_version")
+	}
+	if len(ctx.String("tls_cipher_suites")) > 0 {
+		TLSCipherSuites = splitList(ctx.String("tls_cipher_suites"))
+	}
+	if len(ctx.String("tls_client_ca")) > 0 {
+		TLSClientCA = ctx.String("tls_client_ca")
+	}
+	if len(ctx.String("tls_client_auth")) > 0 {
+		TLSClientAuth = ctx.String("tls_client_auth")
+	}
+	if ctx.IsSet("tls_info_headers") {
+		TLSInfoHeaders = ctx.Bool("tls_info_headers")
+	}
+	if ctx.IsSet("tls_check_revocation") {
+		TLSCheckRevocation = ctx.Bool("tls_check_revocation")
+	}
+	if len(ctx.String("tls_crl_url")) > 0 {
+		TLSCRLURL = ctx.String("tls_crl_url")
+	}
+	if len(ctx.String("tls_ocsp_responder")) > 0 {
+		TLSOCSPResponder = ctx.String("tls_ocsp_responder")
+	}
+	if d := ctx.Duration("tls_revocation_refresh"); d > 0 {
+		TLSRevocationRefresh = d
+	}
+	if ctx.IsSet("tls_revocation_strict") {
+		TLSRevocationStrict = ctx.Bool("tls_revocation_strict")
+	}
+	if len(ctx.String("namespace_overrides")) > 0 {
+		NamespaceOverrides = splitList(ctx.String("namespace_overrides"))
+	}
+	if i := ctx.Int("http2_max_concurrent_streams"); i > 0 {
+		HTTP2MaxStreams = uint32(i)
+	}
+	if ctx.IsSet("conn_max_request_rate") {
+		ConnMaxRequestRate = ctx.Int("conn_max_request_rate")
+	}
+	if ctx.IsSet("conn_max_reset_rate") {
+		ConnMaxResetRate = ctx.Int("conn_max_reset_rate")
+	}
+	if len(ctx.String("admin_token")) > 0 {
+		AdminToken = ctx.String("admin_token")
+	}
+	if ctx.IsSet("enable_pprof") {
+		EnablePprof = ctx.Bool("enable_pprof")
+	}
+	if len(ctx.String("pprof_address")) > 0 {
+		PprofAddress = ctx.String("pprof_address")
+	}
+	if ctx.IsSet("decode_responses") {
+		DecodeResponses = ctx.Bool("decode_responses")
+	}
+	if i := ctx.Int("bulkhead_limit"); i > 0 {
+		BulkheadLimit = i
+	}
+	if len(ctx.String("bulkhead_limits")) > 0 {
+		BulkheadLimits = splitList(ctx.String("bulkhead_limits"))
+	}
+	if d := ctx.Duration("bulkhead_queue_timeout"); d > 0 {
+		BulkheadQueueTimeout = d
+	}
+	if ctx.IsSet("require_https") {
+		RequireHTTPS = ctx.Bool("require_https")
+	}
+	if len(ctx.String("https_policy")) > 0 {
+		HTTPSPolicy = ctx.String("https_policy")
+	}
+	if len(ctx.String("trusted_proxies")) > 0 {
+		TrustedProxies = splitList(ctx.String("trusted_proxies"))
+	}
+	if len(ctx.String("template_routes")) > 0 {
+		TemplateRoutes = ctx.String("template_routes")
+	}
+	if len(ctx.String("query_defaults")) > 0 {
+		QueryDefaults = ctx.String("query_defaults")
+	}
+	if len(ctx.String("body_schemas")) > 0 {
+		BodySchemas = ctx.String("body_schemas")
+	}
+	if len(ctx.String("required_headers")) > 0 {
+		RequiredHeaders = ctx.String("required_headers")
+	}
+	if len(ctx.String("status_rewrites")) > 0 {
+		StatusRewrites = ctx.String("status_rewrites")
+	}
+	if len(ctx.String("rollouts")) > 0 {
+		Rollouts = splitList(ctx.String("rollouts"))
+	}
+	if i := ctx.Int("rollout_step"); i > 0 {
+		RolloutStep = i
+	}
+	if d := ctx.Duration("rollout_interval"); d > 0 {
+		RolloutInterval = d
+	}
+	if ctx.IsSet("rollout_max_error_rate") {
+		RolloutMaxErrorRate = ctx.Float64("rollout_max_error_rate")
+	}
+	if ctx.IsSet("rollout_min_requests") {
+		RolloutMinRequests = ctx.Int("rollout_min_requests")
+	}
+	if d := ctx.Duration("acme_timeout"); d > 0 {
+		ACMETimeout = d
+	}
+	if f := ctx.Float64("rate_limit"); f > 0 {
+		RateLimit = f
+	}
+	if i := ctx.Int("rate_limit_burst"); i > 0 {
+		RateLimitBurst = i
+	}
+	if len(ctx.String("rate_limit_key")) > 0 {
+		RateLimitKey = ctx.String("rate_limit_key")
+	}
+	if f := ctx.Float64("tenant_rate_limit"); f > 0 {
+		TenantRateLimit = f
+	}
+	if i := ctx.Int("tenant_rate_limit_burst"); i > 0 {
+		TenantRateLimitBurst = i
+	}
+	if len(ctx.String("tenant_rate_limit_key")) > 0 {
+		TenantRateLimitKey = ctx.String("tenant_rate_limit_key")
+	}
+	if len(ctx.String("auth_api_keys")) > 0 {
+		AuthAPIKeys = ctx.String("auth_api_keys")
+	}
+	if len(ctx.String("auth_api_key_header")) > 0 {
+		AuthAPIKeyHeader = ctx.String("auth_api_key_header")
+	}
+	if len(ctx.StringSlice("request_signing_keys")) > 0 {
+		RequestSigningKeys = ctx.StringSlice("request_signing_keys")
+	}
+	if len(ctx.StringSlice("tenant_rate_limits")) > 0 {
+		TenantRateLimits = ctx.StringSlice("tenant_rate_limits")
+	}
+	if len(ctx.String("rate_limit_headers")) > 0 {
+		RateLimitHeaders = splitList(ctx.String("rate_limit_headers"))
+		if len(RateLimitHeaders) != 3 {
+			log.Fatal("rate_limit_headers must list the limit, remaining and reset header names")
+		}
+	}
+	if ctx.IsSet("serve_stale_on_error") {
+		ServeStaleOnError = ctx.Bool("serve_stale_on_error")
+	}
+	if d := ctx.Duration("serve_stale_max_age"); d > 0 {
+		ServeStaleMaxAge = d
+	}
+	if len(ctx.String("serve_stale_methods")) > 0 {
+		ServeStaleMethods = ctx.String("serve_stale_methods")
+	}
+	if len(ctx.String("path_case")) > 0 {
+		PathCase = splitList(ctx.String("path_case"))
+	}
+	if len(ctx.StringSlice("rewrite")) > 0 {
+		PathRewrites = ctx.StringSlice("rewrite")
+	}
+	if i := ctx.Int("max_path_depth"); i > 0 {
+		MaxPathDepth = i
+	}
+	if ctx.IsSet("maintenance") {
+		Maintenance = ctx.Bool("maintenance")
+	}
+	if len(ctx.String("maintenance_allowlist")) > 0 {
+		MaintenanceAllowlist = splitList(ctx.String("maintenance_allowlist"))
+	}
+	if len(ctx.String("maintenance_keys")) > 0 {
+		MaintenanceKeys = splitList(ctx.String("maintenance_keys"))
+	}
+	if len(ctx.String("error_format")) > 0 {
+		ErrorFormat = ctx.String("error_format")
+	}
+	if len(ctx.String("error_template")) > 0 {
+		ErrorTemplate = ctx.String("error_template")
+	}
+	if len(ctx.String("request_id_header")) > 0 {
+		RequestIDHeader = ctx.String("request_id_header")
+	}
+	if len(ctx.String("request_id_format")) > 0 {
+		RequestIDFormat = ctx.String("request_id_format")
+	}
+	if len(ctx.String("request_id_prefix")) > 0 {
+		RequestIDPrefix = ctx.String("request_id_prefix")
+	}
+	if len(ctx.String("request_id_pattern")) > 0 {
+		RequestIDPattern = ctx.String("request_id_pattern")
+	}
 	if len(ctx.String("namespace")) > 0 {
 		// remove the service type from the namespace to allow for
 		// backwards compatability
@@ -101,6 +730,11 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	// Init API
 	var opts []server.Option
 
+	// reloads the tls certificate, set when tls is enabled
+	var certs *certReloader
+	// checks client certificates are not revoked, set when enabled
+	var revocation *revocationChecker
+
 	// 根据是否设置 enable_acme 或 enable_tls 参数对服务器进行初始化设置，决定是否要启用 HTTPS，以及为哪些服务器启用。
 	if ctx.Bool("enable_acme") {
 		hosts := helper.ACMEHosts(ctx)
@@ -108,36 +742,92 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 		opts = append(opts, server.ACMEHosts(hosts...))
 		switch ACMEProvider {
 		case "autocert":
-			opts = append(opts, server.ACMEProvider(autocert.NewProvider()))
+			opts = append(opts, server.ACMEProvider(newAutocertProvider(ACMETimeout)))
 		case "certmagic":
-			if ACMEChallengeProvider != "cloudflare" {
-				log.Fatal("The only implemented DNS challenge provider is cloudflare")
+			var challengeProvider challenge.Provider
+			switch ACMEChallengeProvider {
+			case "cloudflare":
+				apiToken := os.Getenv("CF_API_TOKEN")
+				if len(apiToken) == 0 {
+					log.Fatal("env variable CF_API_TOKEN must be set")
+				}
+				config := cloudflare.NewDefaultConfig()
+				config.AuthToken = apiToken
+				config.ZoneToken = apiToken
+				config.HTTPClient = &http.Client{Timeout: ACMETimeout}
+				p, err := cloudflare.NewDNSProviderConfig(config)
+				if err != nil {
+					log.Fatal(err.Error())
+				}
+				challengeProvider = p
+			case "route53":
+				if len(os.Getenv("AWS_ACCESS_KEY_ID")) == 0 || len(os.Getenv("AWS_SECRET_ACCESS_KEY")) == 0 || len(os.Getenv("AWS_REGION")) == 0 {
+					log.Fatal("env variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION must be set")
+				}
+				p, err := route53.NewDNSProviderConfig(route53.NewDefaultConfig())
+				if err != nil {
+					log.Fatal(err.Error())
+				}
+				challengeProvider = p
+			default:
+				log.Fatalf("%s is not a valid DNS challenge provider\n", ACMEChallengeProvider)
 			}
-			apiToken, accountID := os.Getenv("CF_API_TOKEN"), os.Getenv("CF_ACCOUNT_ID")
-			kvID := os.Getenv("KV_NAMESPACE_ID")
-			if len(apiToken) == 0 || len(accountID) == 0 {
-				log.Fatal("env variables CF_API_TOKEN and CF_ACCOUNT_ID must be set")
-			}
-			if len(kvID) == 0 {
-				log.Fatal("env var KV_NAMESPACE_ID must be set to your cloudflare workers KV namespace ID")
+
+			// keep certificates with the provider of the challenge unless set
+			acmeStorage := ACMEStorage
+			if len(acmeStorage) == 0 {
+				acmeStorage = "cloudflare"
+				if ACMEChallengeProvider == "route53" {
+					acmeStorage = "s3"
+				}
 			}
 
-			cloudflareStore := cfstore.NewStore(
-				cfstore.Token(apiToken),
-				cfstore.Account(accountID),
-				cfstore.Namespace(kvID),
-				cfstore.CacheTTL(time.Minute),
-			)
-			storage := certmagic.NewStorage(
-				memory.NewSync(),
-				cloudflareStore,
-			)
-			config := cloudflare.NewDefaultConfig()
-			config.AuthToken = apiToken
-			config.ZoneToken = apiToken
-			challengeProvider, err := cloudflare.NewDNSProviderConfig(config)
-			if err != nil {
-				log.Fatal(err.Error())
+			// the certificate cache, kept in a store synced in memory
+			// unless on local disk
+			var storage interface{}
+			switch acmeStorage {
+			case "cloudflare":
+				apiToken, accountID := os.Getenv("CF_API_TOKEN"), os.Getenv("CF_ACCOUNT_ID")
+				kvID := os.Getenv("KV_NAMESPACE_ID")
+				if len(apiToken) == 0 || len(accountID) == 0 {
+					log.Fatal("env variables CF_API_TOKEN and CF_ACCOUNT_ID must be set")
+				}
+				if len(kvID) == 0 {
+					log.Fatal("env var KV_NAMESPACE_ID must be set to your cloudflare workers KV namespace ID")
+				}
+				storage = certmagic.NewStorage(
+					memory.NewSync(),
+					cfstore.NewStore(
+						cfstore.Token(apiToken),
+						cfstore.Account(accountID),
+						cfstore.Namespace(kvID),
+						cfstore.CacheTTL(time.Minute),
+					),
+				)
+			case "s3":
+				bucket, region := os.Getenv("ACME_S3_BUCKET"), os.Getenv("AWS_REGION")
+				if len(os.Getenv("AWS_ACCESS_KEY_ID")) == 0 || len(os.Getenv("AWS_SECRET_ACCESS_KEY")) == 0 || len(region) == 0 {
+					log.Fatal("env variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION must be set")
+				}
+				if len(bucket) == 0 {
+					log.Fatal("env var ACME_S3_BUCKET must be set to the s3 bucket certificates are kept in")
+				}
+				storage = certmagic.NewStorage(
+					memory.NewSync(),
+					s3store.NewStore(
+						s3store.Bucket(bucket),
+						s3store.Region(region),
+						s3store.Prefix("acme/"),
+					),
+				)
+			case "file":
+				// certmagic keeps certificates in its data directory by default
+				storage = cmagic.Default.Storage
+				if len(ACMEStorageDir) > 0 {
+					storage = &cmagic.FileStorage{Path: ACMEStorageDir}
+				}
+			default:
+				log.Fatalf("%s is not a valid ACME storage\n", acmeStorage)
 			}
 
 			opts = append(opts,
@@ -161,6 +851,74 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 			return
 		}
 
+		// serve the certificate from a reloader so it can be rotated
+		certs, err = newCertReloader(ctx.String("tls_cert_file"), ctx.String("tls_key_file"))
+		if err != nil {
+			log.Fatalf("Failed to load TLS certificate: %v", err)
+		}
+		config.Certificates = nil
+		config.GetCertificate = certs.GetCertificate
+
+		// constrain the protocol versions and cipher suites negotiated
+		switch TLSMinVersion {
+		case "1.0":
+			config.MinVersion = tls.VersionTLS10
+		case "1.1":
+			config.MinVersion = tls.VersionTLS11
+		case "1.2":
+			config.MinVersion = tls.VersionTLS12
+		case "1.3":
+			config.MinVersion = tls.VersionTLS13
+		default:
+			log.Fatalf("%s is not a valid TLS version\n", TLSMinVersion)
+		}
+		if len(TLSCipherSuites) > 0 {
+			if config.MinVersion == tls.VersionTLS13 {
+				log.Warn("Ignoring tls_cipher_suites as the TLS 1.3 cipher suites aren't configurable")
+			} else {
+				suites, err := parseCipherSuites(TLSCipherSuites)
+				if err != nil {
+					log.Fatal(err)
+				}
+				config.CipherSuites = suites
+			}
+		}
+
+		// verify client certificates against the ca, required unless
+		// the client auth mode says otherwise
+		if len(TLSClientCA) > 0 {
+			pool, err := loadClientCAs(TLSClientCA)
+			if err != nil {
+				log.Fatalf("Failed to load TLS client CA: %v", err)
+			}
+			config.ClientCAs = pool
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+
+		// connections with invalid certificates fail the handshake
+		switch TLSClientAuth {
+		case "":
+		case "none":
+			config.ClientAuth = tls.NoClientCert
+		case "request":
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		case "require-and-verify":
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		default:
+			log.Fatalf("%s is not a valid TLS client auth mode\n", TLSClientAuth)
+		}
+		if config.ClientAuth != tls.NoClientCert && config.ClientCAs == nil {
+			log.Fatal("Verifying client certificates requires tls_client_ca or tls_client_ca_file")
+		}
+
+		// reject requests with revoked client certificates
+		if TLSCheckRevocation {
+			if config.ClientCAs == nil {
+				log.Fatal("Checking client certificate revocation requires tls_client_ca or tls_client_ca_file")
+			}
+			revocation = newRevocationChecker(TLSCRLURL, TLSOCSPResponder, TLSRevocationRefresh, TLSRevocationStrict)
+		}
+
 		opts = append(opts, server.EnableTLS(true))
 		opts = append(opts, server.TLSConfig(config))
 	}
@@ -168,6 +926,18 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	if ctx.Bool("enable_cors") {
 		opts = append(opts, server.EnableCORS(true))
 	}
+	if ctx.IsSet("cors_same_origin_bypass") {
+		CORSSameOriginBypass = ctx.Bool("cors_same_origin_bypass")
+	}
+	if len(ctx.String("cors_allowed_origins")) > 0 {
+		CORSAllowedOrigins = splitList(ctx.String("cors_allowed_origins"))
+	}
+	if len(ctx.String("cors_allowed_methods")) > 0 {
+		CORSAllowedMethods = splitList(ctx.String("cors_allowed_methods"))
+	}
+	if len(ctx.String("cors_allowed_headers")) > 0 {
+		CORSAllowedHeaders = splitList(ctx.String("cors_allowed_headers"))
+	}
 
 	// create the router
 	// Micro API 底层基于 gorilla/mux 包实现 HTTP 请求路由的分发
@@ -176,75 +946,562 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	r := mux.NewRouter()
 	h = r
 
+	// the api server, created once the handlers are set up
+	var api serverGroup
+
+	// report draining once stopping so load balancers stop routing here
+	draining := func() bool {
+		return api.Draining()
+	}
+
+	// format the errors generated by the gateway like those of the backends
+	if len(ErrorFormat) > 0 {
+		envelope, err := errorformat.New(ErrorFormat, ErrorTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		errorformat.Set(envelope)
+	}
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, "Not found", http.StatusNotFound)
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+
+	// report how long the backend took to respond
+	if TimingHeaders {
+		r.Use(func(h http.Handler) http.Handler {
+			return timingHandler(HeaderPrefix+"Upstream-Time", h)
+		})
+	}
+
+	// records gateway events e.g shed requests in the stats
+	record := func(event string) {}
+	// records events broken down by a label e.g the service
+	recordLabel := func(event, label string) {}
+	// records durations broken down by a label
+	observe := func(name, label string, d time.Duration) {}
+
 	if ctx.Bool("enable_stats") {
-		st := stats.New()
+		for _, d := range StatsDimensions {
+			if !stats.ValidDimension(d) {
+				log.Fatalf("%s is not a valid stats dimension\n", d)
+			}
+		}
+
+		st := stats.New(stats.Dimensions(StatsDimensions...), stats.Status(func() string {
+			if draining() {
+				return "draining"
+			}
+			return "ready"
+		}))
 		r.HandleFunc("/stats", st.StatsHandler)
 		h = st.ServeHTTP(r)
 		st.Start()
 		defer st.Stop()
+		record = func(event string) { st.Record(event, 1) }
+		recordLabel = func(event, label string) { st.RecordLabel(event, label, 1) }
+		observe = st.Observe
 	}
 
-	// return version and list of services
-	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "OPTIONS" {
-			return
+	// sign requests to services configured with a key, after anything
+	// rewriting the body
+	if len(RequestSigningKeys) > 0 {
+		keys, err := loadSigningKeys(RequestSigningKeys)
+		if err != nil {
+			log.Fatalf("Failed to load signing keys: %v", err)
 		}
-
-		response := fmt.Sprintf(`{"version": "%s"}`, ctx.App.Version)
-		w.Write([]byte(response))
-	})
-
-	// strip favicon.ico
-	r.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {})
-
-	srvOpts = append(srvOpts, micro.Name(Name))
-	if i := time.Duration(ctx.Int("register_ttl")); i > 0 {
-		srvOpts = append(srvOpts, micro.RegisterTTL(i*time.Second))
+		// bodies are buffered to be signed so need a bound when unlimited
+		max := MaxRequestBody
+		if max <= 0 {
+			max = 32 << 20
+		}
+		h = newSigner(keys, max).Handler(h)
 	}
-	if i := time.Duration(ctx.Int("register_interval")); i > 0 {
-		srvOpts = append(srvOpts, micro.RegisterInterval(i*time.Second))
+
+	// throttle each client, keyed by ip or a header such as an api key
+	if RateLimit > 0 {
+		trusted, err := parseCIDRs(TrustedProxies)
+		if err != nil {
+			log.Fatal(err)
+		}
+		h = newClientLimits(RateLimit, RateLimitBurst, RateLimitKey, trusted, RateLimitHeaders).Handler(h)
 	}
 
-	// initialise service
-	// 2.然后经过一些服务器全局参数的设置之后，传入这些全局参数来初始化服务
-	service := micro.NewService(srvOpts...)
+	// throttle each tenant across services, keyed by namespace or a header
+	if TenantRateLimit > 0 || len(TenantRateLimits) > 0 {
+		tenants, err := parseTenantLimits(TenantRateLimits)
+		if err != nil {
+			log.Fatal(err)
+		}
+		limit := tenantLimit{rate: TenantRateLimit, burst: TenantRateLimitBurst}
+		h = newTenantLimits(TenantRateLimitKey, limit, tenants, RateLimitHeaders).Handler(h)
+	}
 
-	// register rpc handler
-	// 3.接下来，注册RPC请求处理器
-	// 默认 RPC 请求路径是 /rpc
-	// 客户端需要通过这个路径发起 POST 请求，请求参数一般是 JSON 格式数据或者编码过的 RPC 表单请求，例如：
-	// 详见：https://micro.mu/docs/cn/api.html
+	// buffers the http handler copies proxied bodies through
+	pool := newProxyBufferPool(ProxyBufferSize)
+	// connects to backends, failing fast when they can't be reached
+	dialer := newBackendDialer(DialTimeout)
 
-	//curl -d 'service=go.micro.srv.greeter' \
-	//-d 'method=Say.Hello' \
-	//-d 'request={"name": "John"}' \
-    //http://localhost:8080/rpc
-    // 或
-	//curl -H 'Content-Type: application/json' \
-	//-d '{"service": "go.micro.srv.greeter", "method": "Say.Hello", "request": {"name": "John"}}' \
-    //http://localhost:8080/rpc
+	// export requests to prometheus
+	if ctx.Bool("enable_metrics") {
+		// exemplars link durations to traces, only exposed in OpenMetrics
+		exemplars := MetricsExemplars && EnableTracing
+		r.Handle("/metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: exemplars,
+		}))
+		h = newMetrics(prometheus.DefaultRegisterer, pool, dialer, exemplars).Handler(h)
 
-	// 处理器底层会将 RPC 请求转化为对 Go Micro 底层服务的请求，对应的处理器源码位于 micro/micro/internal/handler/rpc.go 中。
-	// 这里应该是会然后 api handler，直接由Handler.RPC进行处理
-	if EnableRPC {
-		log.Infof("Registering RPC Handler at %s", RPCPath)
-		r.HandleFunc(RPCPath, handler.RPC)
+		// track the latency objectives of services over a rolling window
+		if len(SLOThresholds) > 0 {
+			thresholds, err := parseSLOThresholds(SLOThresholds)
+			if err != nil {
+				log.Fatal(err)
+			}
+			h = newSLO(prometheus.DefaultRegisterer, thresholds, SLOWindow).Handler(h)
+		}
 	}
 
-	// create the namespace resolver
-	nsResolver := namespace.NewResolver(Type, Namespace)
+	// shed load when the p99 latency exceeds the target
+	if ShedLatencyTarget > 0 {
+		log.Infof("Shedding load above p99 latency of %v", ShedLatencyTarget)
+		h = newShedder(ShedLatencyTarget, ShedAggressiveness, func() { record("shed") }).Handler(h)
+	}
 
-	// resolver options
-	// 解析器参数
-	ropts := []resolver.Option{
-		resolver.WithNamespace(nsResolver.Resolve),
-		resolver.WithHandler(Handler),
+	// reject everyone but the allowlist while in maintenance
+	if Maintenance {
+		allowed, err := parseCIDRs(MaintenanceAllowlist)
+		if err != nil {
+			log.Fatal(err)
+		}
+		trusted, err := parseCIDRs(TrustedProxies)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Info("Serving in maintenance mode")
+		h = newMaintenance(allowed, MaintenanceKeys, trusted).Handler(h)
 	}
 
-	// default resolver
+	// enforce https, e.g when tls is terminated by an upstream proxy
+	if RequireHTTPS {
+		switch HTTPSPolicy {
+		case "redirect", "reject":
+		default:
+			log.Fatalf("%s is not a valid https policy\n", HTTPSPolicy)
+		}
+		trusted, err := parseCIDRs(TrustedProxies)
+		if err != nil {
+			log.Fatal(err)
+		}
+		h = newRequireHTTPS(HTTPSPolicy == "redirect", trusted).Handler(h)
+	}
+
+	switch ExpectContinue {
+	case "gateway", "forward", "reject":
+		h = expectHandler(ExpectContinue, ExpectMaxSize, h)
+	default:
+		log.Fatalf("%s is not a valid Expect: 100-continue policy\n", ExpectContinue)
+	}
+
+	switch HeadMode {
+	case "forward", "get", "auto":
+		h = headHandler(HeadMode, HeadGetPaths, h)
+	default:
+		log.Fatalf("%s is not a valid HEAD mode\n", HeadMode)
+	}
+
+	// inject faults to test client resilience, never enable in production
+	if EnableChaos {
+		log.Warn("Chaos enabled, injecting latency and errors into requests")
+		c := &chaos{
+			latencyRatio: ctx.Float64("chaos_latency_ratio"),
+			latency:      ctx.Duration("chaos_latency"),
+			jitter:       ctx.Duration("chaos_latency_jitter"),
+			errorRatio:   ctx.Float64("chaos_error_ratio"),
+			errorCode:    ctx.Int("chaos_error_code"),
+			paths:        splitList(ctx.String("chaos_paths")),
+			record:       record,
+		}
+		if err := c.validate(); err != nil {
+			log.Fatal(err)
+		}
+		h = c.Handler(h)
+	}
+
+	// add the query params backends expect that clients leave out
+	if len(QueryDefaults) > 0 {
+		defaults, err := loadQueryDefaults(QueryDefaults)
+		if err != nil {
+			log.Fatalf("Failed to load query defaults: %v", err)
+		}
+		h = queryDefaultsHandler(defaults, h)
+	}
+
+	// fill in the body fields backends expect that clients leave out
+	if len(BodySchemas) > 0 {
+		schemas, err := loadBodySchemas(BodySchemas)
+		if err != nil {
+			log.Fatalf("Failed to load body schemas: %v", err)
+		}
+		// bodies are buffered to be rewritten so need a bound when unlimited
+		max := MaxRequestBody
+		if max <= 0 {
+			max = 32 << 20
+		}
+		h = bodyDefaultsHandler(schemas, max, h)
+	}
+
+	// reject requests missing the headers their route requires
+	if len(RequiredHeaders) > 0 {
+		required, err := loadRequiredHeaders(RequiredHeaders)
+		if err != nil {
+			log.Fatalf("Failed to load required headers: %v", err)
+		}
+		h = requiredHeadersHandler(required, h)
+	}
+
+	// map the status codes of backends to those clients expect
+	if len(StatusRewrites) > 0 {
+		rewrites, err := loadStatusRewrites(StatusRewrites)
+		if err != nil {
+			log.Fatalf("Failed to load status rewrites: %v", err)
+		}
+		h = statusRewriteHandler(rewrites, h)
+	}
+
+	// set a predictable content type on responses
+	h = contentTypeHandler(DefaultContentType, DisableSniffing, h)
+
+	// reject upgrades to protocols the operator hasn't allowed, only the
+	// proxying handlers can upgrade to websockets by default and the others
+	// can't upgrade at all
+	if UpgradeAllowlist == nil {
+		switch Handler {
+		case "http", "proxy", "web":
+			UpgradeAllowlist = []string{"websocket"}
+		}
+	}
+	h = upgradeHandler(UpgradeAllowlist, h)
+
+	// keep serving read requests from the last response while backends fail
+	if ServeStaleOnError {
+		var methods map[string][]string
+		if len(ServeStaleMethods) > 0 {
+			var err error
+			methods, err = loadStaleMethods(ServeStaleMethods)
+			if err != nil {
+				log.Fatalf("Failed to load stale methods: %v", err)
+			}
+		}
+		h = newStaleCache(ServeStaleMaxAge, methods).Handler(h)
+	}
+
+	// compress responses, skipping routes and content types marked to skip
+	if EnableCompression {
+		h = newCompressor(CompressionLevel, CompressionMinSize, CompressionSkipPaths, CompressionSkipTypes).Handler(h)
+	}
+
+	// send responses of routes with a content length or streamed chunked
+	// for clients and intermediaries mishandling chunked encoding
+	if len(ChunkedPolicies) > 0 {
+		policies, err := parseChunkedPolicies(ChunkedPolicies)
+		if err != nil {
+			log.Fatal(err)
+		}
+		h = chunkedHandler(policies, ChunkedBufferMax, h)
+	}
+
+	// convert form requests to json for backends only accepting json
+	if len(FormToJSONPaths) > 0 {
+		h = newFormTransformer(FormToJSONPaths).Handler(h)
+	}
+
+	// reject request bodies in encodings we don't support
+	if len(RequestEncodings) > 0 || DecompressRequests {
+		h = newDecompressor(RequestEncodings, DecompressRequests, DecompressMaxSize).Handler(h)
+	}
+
+	// limit the size of request bodies, the http handler may allow larger
+	// uploads while rpc calls keep the default. The http handler proxies
+	// every path but the rpc one.
+	if MaxRequestBody > 0 || MaxProxyRequestBody > 0 {
+		paths := make(map[string]int64)
+		if MaxProxyRequestBody > 0 && (Handler == "http" || Handler == "proxy") {
+			paths["/"] = MaxProxyRequestBody
+			if EnableRPC {
+				paths[RPCPath] = MaxRequestBody
+			}
+		}
+		h = newBodyLimiter(MaxRequestBody, paths).Handler(h)
+	}
+
+	// capture a sample of requests for replay
+	if CaptureRatio > 0 {
+		log.Infof("Capturing %v of requests to the store", CaptureRatio)
+		h = newCapturer(store.DefaultStore, CaptureRatio, CapturePaths, CaptureRedact, CaptureMaxBody).Handler(h)
+	}
+
+	// return version and list of services. Routes match in the order they're
+	// registered so the exact "/" route takes precedence over the api handler
+	// registered on PathPrefix(APIPath) below, disable it to route the root
+	// to a service
+	if !DisableVersion {
+		r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "OPTIONS" {
+				return
+			}
+
+			response := fmt.Sprintf(`{"version": "%s"}`, ctx.App.Version)
+			w.Write([]byte(response))
+		})
+	}
+
+	// profile the gateway, only ever on its own listener so profiles are
+	// never exposed with the api
+	if EnablePprof {
+		if len(PprofAddress) == 0 {
+			log.Fatal("pprof_address is required to enable pprof")
+		}
+		l, err := net.Listen("tcp", PprofAddress)
+		if err != nil {
+			log.Fatalf("Failed to listen for pprof: %v", err)
+		}
+		ps := &http.Server{Handler: pprofHandler()}
+		go func() {
+			if err := ps.Serve(l); err != nil && err != http.ErrServerClosed {
+				log.Errorf("pprof server error: %v", err)
+			}
+		}()
+		defer ps.Close()
+		log.Infof("Serving pprof on %s", l.Addr().String())
+	}
+
+	// admin endpoints, gated by the admin token
+	if len(AdminToken) > 0 {
+		// list the loaded plugins and their health
+		r.Handle("/_plugins", adminHandler(AdminToken, http.HandlerFunc(pluginsHandler)))
+		// change the log level at runtime
+		r.Handle("/_loglevel", newLogLevel(AdminToken))
+		// reload the tls certificate from disk
+		if certs != nil {
+			r.Handle("/_tls/reload", adminHandler(AdminToken, certs))
+		}
+	}
+
+	// strip favicon.ico, which otherwise takes precedence over the api
+	// handler the same as the version endpoint
+	if !DisableFaviconStrip {
+		r.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {})
+	}
+
+	srvOpts = append(srvOpts, micro.Name(Name))
+	if i := time.Duration(ctx.Int("register_ttl")); i > 0 {
+		srvOpts = append(srvOpts, micro.RegisterTTL(i*time.Second))
+	}
+	if i := time.Duration(ctx.Int("register_interval")); i > 0 {
+		srvOpts = append(srvOpts, micro.RegisterInterval(i*time.Second))
+	}
+
+	// client wrappers in the order applied, kept to wrap other clients alike
+	var wrappers []client.Wrapper
+
+	// prefer local backends and fail over to other regions
+	if len(Region) > 0 {
+		wrappers = append(wrappers, failoverWrapper(Region, FailoverRegions))
+	}
+
+	// prefer backends in the local zone, falling back to the others
+	if len(Zone) > 0 {
+		switch ZoneFallback {
+		case "any", "missing", "none":
+			wrappers = append(wrappers, zoneWrapper(Zone, ZoneFallback))
+		default:
+			log.Fatalf("%s is not a valid zone fallback\n", ZoneFallback)
+		}
+	}
+
+	// send requests with the same affinity key to the same backend
+	strategy := randomStrategy
+	var hashing *affinity
+	switch SelectorStrategy {
+	case "random":
+	case "maglev":
+		key, err := parseAffinityKey(AffinityKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		hashing = newAffinity(key)
+		strategy = hashing.strategy
+		wrappers = append(wrappers, affinityWrapper(hashing))
+	default:
+		log.Fatalf("%s is not a valid selector strategy\n", SelectorStrategy)
+	}
+
+	// send retried requests to other nodes than those which failed
+	var attempts *attemptTracker
+	if RetryBufferSize > 0 {
+		attempts = newAttemptTracker()
+		wrappers = append(wrappers, retryNodesWrapper(attempts))
+	}
+
+	// retry failed backend calls of idempotent requests on other nodes,
+	// keeping the client from retrying the others
+	wrappers = append(wrappers, retryWrapper(RetryCount, RetryBackoff))
+
+	// progressively cut services over to their new version
+	if len(Rollouts) > 0 {
+		rollouts, err := parseRollouts(Rollouts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rc := newRolloutController(rollouts, RolloutStep, RolloutInterval, RolloutMaxErrorRate, RolloutMinRequests, recordLabel)
+		wrappers = append(wrappers, rolloutWrapper(rc))
+		// list the rollouts, gated by the admin token
+		if len(AdminToken) > 0 {
+			r.Handle("/_rollouts", adminHandler(AdminToken, rc))
+		}
+		rc.Start()
+	}
+
+	// cap the time calls take to connect to a node, outside the retries so
+	// only the last node timing out fails the call with a 503
+	wrappers = append(wrappers, dialTimeoutWrapper(dialer))
+
+	// cancel backend calls at the deadline of their request, wrapping the
+	// others so their calls share it
+	var timeouts *requestTimeouts
+	if RequestTimeout > 0 {
+		timeouts = newRequestTimeouts(RequestTimeout, store.DefaultStore, time.Minute)
+		if err := timeouts.load(); err != nil {
+			log.Errorf("Failed to load request timeouts: %v", err)
+		}
+		timeouts.Start()
+		wrappers = append(wrappers, timeoutWrapper)
+	}
+
+	for _, w := range wrappers {
+		srvOpts = append(srvOpts, micro.WrapClient(w))
+	}
+
+	// with the api shutdown order the gateway stops accepting and drains
+	// requests before the service deregisters, so clients stop being
+	// routed to this instance before it leaves the registry
+	switch ShutdownOrder {
+	case "service":
+	case "api":
+		srvOpts = append(srvOpts, micro.BeforeStop(func() error {
+			log.Info("Stopping HTTP API before deregistering")
+			return api.Stop()
+		}))
+	default:
+		log.Fatalf("%s is not a valid shutdown order\n", ShutdownOrder)
+	}
+
+	// the liveness and readiness checks, ready once the service registered
+	var hc *health
+	srvOpts = append(srvOpts, micro.AfterStart(func() error {
+		hc.setRegistered()
+		return nil
+	}))
+
+	// initialise service
+	// 2.然后经过一些服务器全局参数的设置之后，传入这些全局参数来初始化服务
+	service := micro.NewService(srvOpts...)
+
+	// liveness and readiness checks e.g for kubernetes probes
+	hc = newHealth(service.Options().Registry, draining)
+	r.HandleFunc(HealthPath, hc.Live)
+	r.HandleFunc(ReadyPath, hc.Ready)
+
+	// register rpc handler
+	// 3.接下来，注册RPC请求处理器
+	// 默认 RPC 请求路径是 /rpc
+	// 客户端需要通过这个路径发起 POST 请求，请求参数一般是 JSON 格式数据或者编码过的 RPC 表单请求，例如：
+	// 详见：https://micro.mu/docs/cn/api.html
+
+	//curl -d 'service=go.micro.srv.greeter' \
+	//-d 'method=Say.Hello' \
+	//-d 'request={"name": "John"}' \
+    //http://localhost:8080/rpc
+    // 或
+	//curl -H 'Content-Type: application/json' \
+	//-d '{"service": "go.micro.srv.greeter", "method": "Say.Hello", "request": {"name": "John"}}' \
+    //http://localhost:8080/rpc
+
+	// 处理器底层会将 RPC 请求转化为对 Go Micro 底层服务的请求，对应的处理器源码位于 micro/micro/internal/handler/rpc.go 中。
+	// 这里应该是会然后 api handler，直接由Handler.RPC进行处理
+	if EnableRPC {
+		log.Infof("Registering RPC Handler at %s", RPCPath)
+		r.HandleFunc(RPCPath, handler.RPC)
+	}
+
+	// the backends of composite and template routes, resolved ahead of the
+	// resolver so requests are authorised for the services they call
+	backends := newRouteResolver()
+
+	// fan composite routes out to multiple backends, registered
+	// ahead of the handlers so they take precedence
+	if len(CompositeConfig) > 0 {
+		routes, err := loadComposites(CompositeConfig)
+		if err != nil {
+			log.Fatalf("Failed to load composite config: %v", err)
+		}
+		for path, calls := range routes {
+			log.Infof("Registering Composite Handler at %s", path)
+			r.Handle(path, newComposite(service.Client(), calls))
+			backends.add(path, "", compositeEndpoints(calls)...)
+		}
+	}
+
+	// map url templates to backend methods, registered ahead of
+	// the handlers so they take precedence
+	if len(TemplateRoutes) > 0 {
+		routes, err := loadTemplateRoutes(TemplateRoutes)
+		if err != nil {
+			log.Fatalf("Failed to load template routes: %v", err)
+		}
+		for _, t := range routes {
+			log.Infof("Registering Template Handler at %s %s", t.Method, t.Path)
+			route := r.Handle(t.Path, newTemplateHandler(service.Client(), t))
+			if len(t.Method) > 0 {
+				route.Methods(t.Method)
+			}
+			backends.add(t.Path, t.Method, &resolver.Endpoint{Name: t.Service, Method: t.Endpoint})
+		}
+	}
+
+	// create the namespace resolver
+	overrides, err := namespace.ParseOverrides(NamespaceOverrides)
+	if err != nil {
+		log.Fatal(err)
+	}
+	nsResolver := namespace.NewResolver(Type, Namespace, overrides...)
+
+	// resolver options
+	// 解析器参数
+	ropts := []resolver.Option{
+		resolver.WithNamespace(nsResolver.Resolve),
+		resolver.WithHandler(Handler),
+	}
+
+	// registry cache shared by everything looking up services per request
+	regCache := cache.New(service.Options().Registry)
+
+	// default resolver
 	// 4.初始化默认路由解析器
 	rr := rrmicro.NewResolver(ropts...)
 
+	// only check the registry for overlapping services when asked to, by
+	// default the micro resolver picks the service by the path alone
+	switch policy := rrmicro.Policy(RouteConflict); policy {
+	case "":
+	case rrmicro.LongestPrefix, rrmicro.FirstRegistered, rrmicro.Priority:
+		rr = rrmicro.NewConflictResolver(regCache, policy, ropts...)
+	default:
+		log.Fatalf("%s is not a valid route conflict policy\n", RouteConflict)
+	}
+
 	// Resolver是解析器名称，默认是micro，也可以通过命令行参数指定
 	switch Resolver {
 	case "host":
@@ -253,6 +1510,36 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 		rr = path.NewResolver(ropts...)
 	case "grpc":
 		rr = grpc.NewResolver(ropts...)
+	case "weighted":
+		weights, err := parseResolverWeights(ResolverWeights)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rr = newWeightedResolver(rr, weights)
+	}
+
+	// resolve requests under an override without its prefix
+	if len(overrides) > 0 {
+		rr = nsResolver.Strip(rr)
+	}
+
+	// route large requests e.g uploads to a dedicated tier
+	if len(SizeRoutes) > 0 {
+		routes, err := parseSizeRoutes(SizeRoutes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		switch SizeRouteUnknown {
+		case "small", "large":
+		default:
+			log.Fatalf("%s is not a valid size route default\n", SizeRouteUnknown)
+		}
+		rr = newSizeResolver(rr, routes, SizeRouteUnknown == "large")
+	}
+
+	// resolve composite and template routes to their backends
+	if len(backends.endpoints) > 0 {
+		rr = backends.fallback(rr)
 	}
 
 	// Handler是 API 请求处理器，默认是meta
@@ -309,11 +1596,13 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 			router.WithResolver(rr),
 			router.WithRegistry(service.Options().Registry),
 		)
-		ht := ahttp.NewHandler(
-			ahandler.WithNamespace(apiNamespace),
-			ahandler.WithRouter(rt),
-			ahandler.WithClient(service.Client()),
-		)
+		ht := proxyHandler(rt, pool, BackendMaxHeaderBytes, dialer, strategy)
+		if FollowRedirects {
+			ht = redirectHandler(MaxRedirects, ht)
+		}
+		if DecodeResponses {
+			ht = decodeHandler(ht)
+		}
 		r.PathPrefix(ProxyPath).Handler(ht)
 	case "web":
 		log.Infof("Registering API Web Handler at %s", APIPath)
@@ -334,8 +1623,96 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 			router.WithResolver(rr),
 			router.WithRegistry(service.Options().Registry),
 		)
-		r.PathPrefix(APIPath).Handler(handler.Meta(service, rt, nsResolver.Resolve))
+		r.PathPrefix(APIPath).Handler(handler.Meta(service, rt, nsResolver.Resolve, wrappers...))
+	}
+
+	// pass the http method to the client wrappers deciding on failover
+	// and retries
+	if len(Region) > 0 || len(Zone) > 0 || RetryCount > 0 {
+		h = methodHandler(h)
+	}
+
+	// pass the affinity key to the client wrappers and the proxy
+	if hashing != nil {
+		h = hashing.Handler(h)
+	}
+
+	// buffer request bodies so retried backend calls can resend them
+	if RetryBufferSize > 0 {
+		h = bufferHandler(RetryBufferSize, RetryAttempts, attempts, h)
+	}
+
+	// bound the time of requests, including their retries
+	if timeouts != nil {
+		h = timeouts.Handler(h)
+	}
+
+	// enforce the limits backends advertise in their metadata
+	if BackendLimits {
+		h = newBackendLimits(regCache, apiNamespace, RateLimitHeaders).Handler(h)
+	}
+
+	// fail fast when the service has no nodes
+	if RejectUnavailable {
+		h = newAdmission(regCache, apiNamespace).Handler(h)
+	}
+
+	// reject methods the endpoints matching the path don't accept
+	if MethodNotAllowed {
+		h = newMethodChecker(regCache, apiNamespace, MethodNotAllowedMsg).Handler(h)
+	}
+
+	// bound the requests in flight to each service
+	if BulkheadLimit > 0 || len(BulkheadLimits) > 0 {
+		limits, err := parseLimits(BulkheadLimits)
+		if err != nil {
+			log.Fatal(err)
+		}
+		h = newBulkhead(BulkheadLimit, limits, BulkheadQueueTimeout,
+			func(name string, d time.Duration) { observe("bulkhead_wait", name, d) },
+			func(name string) { recordLabel("bulkhead_rejected", name) },
+		).Handler(h)
+	}
+
+	// trace a sample of requests at the rate of the path or resolved service
+	if EnableTracing {
+		rates, err := parseSampleRates(TraceSampleRates)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var pathRates map[string]float64
+		if len(TracePathSampleRates) > 0 {
+			pathRates, err = loadPathSampleRates(TracePathSampleRates)
+			if err != nil {
+				log.Fatalf("Failed to load path sample rates: %v", err)
+			}
+		}
+		// export spans to an opentelemetry collector, flushing those left
+		// once the server stops
+		tr := trace.DefaultTracer
+		if len(TraceOTLPEndpoint) > 0 {
+			ot := newOTLPTracer(TraceOTLPEndpoint, Name)
+			defer ot.Stop()
+			tr = ot
+		}
+		h = newTracer(tr, TraceSampleRate, rates, pathRates).Handler(h)
+	}
+
+	// tag every request with an id, keeping valid ids set by the client
+	switch RequestIDFormat {
+	case "uuid", "ulid", "counter":
+	default:
+		log.Fatalf("%s is not a valid request id format\n", RequestIDFormat)
+	}
+	var idPattern *regexp.Regexp
+	if len(RequestIDPattern) > 0 {
+		re, err := regexp.Compile(RequestIDPattern)
+		if err != nil {
+			log.Fatalf("Invalid request id pattern: %v", err)
+		}
+		idPattern = re
 	}
+	h = newRequestIDs(RequestIDHeader, RequestIDFormat, RequestIDPrefix, idPattern).Handler(h)
 
 	// reverse wrap handler
 	plugins := append(Plugins(), plugin.Plugins()...)
@@ -349,10 +1726,129 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	// 当有 HTTP 请求过来时，该网关服务器就可以对其进行解析（通过上述初始化的 Resolver）和处理（通过 API 请求处理器处理）并将结果返回给客户端
 	// （相应源码位于 micro/go-micro/api/handler/api/api.go 的 ServeHTTP 方法，以协程方式启动服务器对客户端请求进行处理，底层服务调用逻辑和我们前面介绍的客户端服务发现原理一致）
 	// 以上就是 Micro API 网关的底层实现源码，我们可以看到这个默认的 API 网关采用的是 API 网关架构模式的第一种模式：单节点网关模式，所有的 API 请求都会经过这个单一入口对底层服务进行请求。
-	authWrapper := auth.Wrapper(rr, nsResolver)
-	api := httpapi.NewServer(Address, server.WrapHandler(authWrapper))
+	var authOpts []auth.Option
+	// gate requests by api key rather than account
+	if len(AuthAPIKeys) > 0 {
+		authOpts = append(authOpts, auth.WithAPIKeys(store.DefaultStore, AuthAPIKeys+"/", AuthAPIKeyHeader))
+	}
+	authWrapper := auth.Wrapper(rr, nsResolver, authOpts...)
+
+	// normalise the case of paths before they're resolved
+	if len(PathCase) > 0 {
+		cases, err := parsePathCases(PathCase)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, server.WrapHandler(func(h http.Handler) http.Handler {
+			return pathCaseHandler(cases, h)
+		}))
+	}
+
+	// rewrite legacy paths before they're normalised and resolved
+	if len(PathRewrites) > 0 {
+		rewrites, err := parsePathRewrites(PathRewrites)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, server.WrapHandler(func(h http.Handler) http.Handler {
+			return pathRewriteHandler(rewrites, h)
+		}))
+	}
+
+	// reject pathological paths before they're resolved
+	opts = append(opts, server.WrapHandler(func(h http.Handler) http.Handler {
+		return pathDepthHandler(MaxPathDepth, h)
+	}))
+
+	// pass the verified client certificate to auth and the backends
+	opts = append(opts, server.WrapHandler(func(h http.Handler) http.Handler {
+		return clientCertHandler(HeaderPrefix+"Client-Cert-CN", h)
+	}))
+
+	// reject revoked client certificates before they're passed on
+	if revocation != nil {
+		opts = append(opts, server.WrapHandler(func(h http.Handler) http.Handler {
+			return revocationHandler(revocation, h)
+		}))
+	}
+
+	// pass the tls version, cipher suite and sni in the request context
+	opts = append(opts, server.WrapHandler(func(h http.Handler) http.Handler {
+		return tlsInfoHandler(HeaderPrefix, TLSInfoHeaders, h)
+	}))
+
+	// settle duplicate headers before auth reads them
+	switch DuplicateHeaderPolicy {
+	case "reject", "first", "last":
+		opts = append(opts, server.WrapHandler(func(h http.Handler) http.Handler {
+			return duplicateHeaderHandler(DuplicateHeaderPolicy, DuplicateHeaders, h)
+		}))
+	default:
+		log.Fatalf("%s is not a valid duplicate header policy\n", DuplicateHeaderPolicy)
+	}
+
+	// harden every response, including those rejected by auth and cors
+	// preflights, set by the server outside the wrappers
+	var responseHeaders http.Header
+	if len(ResponseHeaders) > 0 {
+		var err error
+		responseHeaders, err = parseResponseHeaders(ResponseHeaders)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// report the total response time including the gateway overhead
+	if TimingHeaders {
+		opts = append(opts, server.WrapHandler(func(h http.Handler) http.Handler {
+			return timingHandler(HeaderPrefix+"Response-Time", h)
+		}))
+	}
+
+	switch AccessLogFormat {
+	case "common", "combined", "json":
+	default:
+		log.Fatalf("%s is not a valid access log format\n", AccessLogFormat)
+	}
+
+	api = serverGroup{newServer(Address, server.WrapHandler(authWrapper))}
+	// serve internal clients in plaintext alongside the main address
+	var internal *httpServer
+	if len(InternalAddress) > 0 {
+		internal = newServer(InternalAddress, server.WrapHandler(authWrapper))
+		api = append(api, internal)
+	}
 
 	api.Init(opts...)
+	if internal != nil {
+		internal.Init(server.EnableTLS(false), server.EnableACME(false))
+	}
+	api.Configure(
+		withNoDelay(TCPNoDelay),
+		withReadBuffer(TCPReadBuffer),
+		withWriteBuffer(TCPWriteBuffer),
+		withAccessLog(newAccessLog(os.Stdout, AccessLogFormat, AccessLog, AccessLogInclude, AccessLogExclude)),
+		withCORSSameOriginBypass(CORSSameOriginBypass),
+		withCORS(corsOptions{
+			origins: CORSAllowedOrigins,
+			methods: CORSAllowedMethods,
+			headers: CORSAllowedHeaders,
+		}),
+		withDrainTimeout(ShutdownDrainTimeout),
+		withShutdownDelay(ShutdownDelay),
+		withNextProtos(TLSNextProtos),
+		withTimeouts(serverTimeouts{
+			read:  ReadTimeout,
+			write: WriteTimeout,
+			idle:  IdleTimeout,
+		}),
+		withMaxConcurrentStreams(HTTP2MaxStreams),
+		withResponseHeaders(responseHeaders, ForceResponseHeaders),
+	)
+	// close connections flooding the gateway e.g with http2 rapid resets
+	if ConnMaxRequestRate > 0 || ConnMaxResetRate > 0 {
+		api.Configure(withConnLimiter(newConnLimiter(ConnMaxRequestRate, ConnMaxResetRate, record)))
+	}
 	api.Handle("/", h)
 
 	// Start API
@@ -371,6 +1867,7 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 
 	// Stop API
 	// 只有service停止之后，这里才会执行
+	// a no-op if already stopped before deregistering
 	if err := api.Stop(); err != nil {
 		log.Fatal(err)
 	}
@@ -391,9 +1888,14 @@ func Commands(options ...micro.Option) []*cli.Command {
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "address",
-				Usage:   "Set the api address e.g 0.0.0.0:8080",
+				Usage:   "Set the api address e.g 0.0.0.0:8080, or a unix socket e.g unix:///var/run/micro-api.sock",
 				EnvVars: []string{"MICRO_API_ADDRESS"},
 			},
+			&cli.StringFlag{
+				Name:    "internal_address",
+				Usage:   "Set an address also serving the api in plaintext for internal clients e.g 127.0.0.1:8081",
+				EnvVars: []string{"MICRO_API_INTERNAL_ADDRESS"},
+			},
 			&cli.StringFlag{
 				Name:    "handler",
 				Usage:   "Specify the request handler to be used for mapping HTTP requests to services; {api, event, http, rpc}",
@@ -411,19 +1913,844 @@ func Commands(options ...micro.Option) []*cli.Command {
 			},
 			&cli.StringFlag{
 				Name:    "resolver",
-				Usage:   "Set the hostname resolver used by the API {host, path, grpc}",
+				Usage:   "Set the hostname resolver used by the API {host, path, grpc, weighted}",
 				EnvVars: []string{"MICRO_API_RESOLVER"},
 			},
+			&cli.StringFlag{
+				Name:    "resolver_weights",
+				Usage:   "Comma separated list of service=target:weight splitting the traffic of services with the weighted resolver e.g go.micro.api.foo=go.micro.api.foo:90,go.micro.api.foo=go.micro.api.foo-v2:10",
+				EnvVars: []string{"MICRO_API_RESOLVER_WEIGHTS"},
+			},
 			&cli.BoolFlag{
 				Name:    "enable_rpc",
 				Usage:   "Enable call the backend directly via /rpc",
 				EnvVars: []string{"MICRO_API_ENABLE_RPC"},
 			},
 			&cli.BoolFlag{
-				Name:    "enable_cors",
-				Usage:   "Enable CORS, allowing the API to be called by frontend applications",
-				EnvVars: []string{"MICRO_API_ENABLE_CORS"},
-				Value:   true,
+				Name:    "disable_version_endpoint",
+				Usage:   "Disable the version returned at /, leaving the root to the api handler",
+				EnvVars: []string{"MICRO_API_DISABLE_VERSION_ENDPOINT"},
+			},
+			&cli.BoolFlag{
+				Name:    "disable_favicon_strip",
+				Usage:   "Disable the empty response to /favicon.ico, leaving it to the api handler",
+				EnvVars: []string{"MICRO_API_DISABLE_FAVICON_STRIP"},
+			},
+			&cli.StringFlag{
+				Name:    "rpc_path",
+				Usage:   "Set the path of the rpc endpoint enabled by enable_rpc. Defaults to /rpc",
+				EnvVars: []string{"MICRO_API_RPC_PATH"},
+			},
+			&cli.BoolFlag{
+				Name:    "enable_cors",
+				Usage:   "Enable CORS, allowing the API to be called by frontend applications",
+				EnvVars: []string{"MICRO_API_ENABLE_CORS"},
+				Value:   true,
+			},
+			&cli.BoolFlag{
+				Name:    "cors_same_origin_bypass",
+				Usage:   "Skip CORS handling of requests whose Origin matches the gateway e.g for frontends served by the gateway",
+				EnvVars: []string{"MICRO_API_CORS_SAME_ORIGIN_BYPASS"},
+			},
+			&cli.StringFlag{
+				Name:    "cors_allowed_origins",
+				Usage:   "Comma separated list of origins allowed to make cross origin requests, any if empty e.g https://app.example.com",
+				EnvVars: []string{"MICRO_API_CORS_ALLOWED_ORIGINS"},
+			},
+			&cli.StringFlag{
+				Name:    "cors_allowed_methods",
+				Usage:   "Comma separated list of methods allowed cross origin e.g GET,POST. Defaults to POST, PATCH, GET, OPTIONS, PUT, DELETE",
+				EnvVars: []string{"MICRO_API_CORS_ALLOWED_METHODS"},
+			},
+			&cli.StringFlag{
+				Name:    "cors_allowed_headers",
+				Usage:   "Comma separated list of request headers allowed cross origin e.g Content-Type,Authorization",
+				EnvVars: []string{"MICRO_API_CORS_ALLOWED_HEADERS"},
+			},
+			&cli.BoolFlag{
+				Name:    "tcp_nodelay",
+				Usage:   "Set TCP_NODELAY on accepted connections, disabling Nagle's algorithm for lower latency",
+				EnvVars: []string{"MICRO_API_TCP_NODELAY"},
+				Value:   true,
+			},
+			&cli.IntFlag{
+				Name:    "tcp_read_buffer",
+				Usage:   "Set the socket read buffer size in bytes of accepted connections",
+				EnvVars: []string{"MICRO_API_TCP_READ_BUFFER"},
+			},
+			&cli.IntFlag{
+				Name:    "tcp_write_buffer",
+				Usage:   "Set the socket write buffer size in bytes of accepted connections",
+				EnvVars: []string{"MICRO_API_TCP_WRITE_BUFFER"},
+			},
+			&cli.DurationFlag{
+				Name:    "shed_latency_target",
+				Usage:   "Shed a fraction of new requests with a 503 when the recent p99 latency exceeds this target e.g 500ms",
+				EnvVars: []string{"MICRO_API_SHED_LATENCY_TARGET"},
+			},
+			&cli.Float64Flag{
+				Name:    "shed_aggressiveness",
+				Usage:   "Scale the fraction of requests shed when over the latency target",
+				EnvVars: []string{"MICRO_API_SHED_AGGRESSIVENESS"},
+				Value:   1.0,
+			},
+			&cli.StringFlag{
+				Name:    "expect_continue",
+				Usage:   "Set how Expect: 100-continue is handled; {gateway, forward, reject}",
+				EnvVars: []string{"MICRO_API_EXPECT_CONTINUE"},
+			},
+			&cli.Int64Flag{
+				Name:    "expect_max_size",
+				Usage:   "Reject Expect: 100-continue requests with a Content-Length above this many bytes",
+				EnvVars: []string{"MICRO_API_EXPECT_MAX_SIZE"},
+			},
+			&cli.Int64Flag{
+				Name:    "max_request_body",
+				Usage:   "Set the max size of request bodies in bytes, larger requests are rejected with a 413 and 0 is unlimited. Defaults to 32MB",
+				EnvVars: []string{"MICRO_API_MAX_REQUEST_BODY"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "request_signing_keys",
+				Usage:   "Sign requests to a service with the key in a file e.g a mounted secret, in the format service=file, can be repeated. The HMAC-SHA256 of the timestamp, method, path and body is sent in the Micro-Api-Signature header",
+				EnvVars: []string{"MICRO_API_REQUEST_SIGNING_KEYS"},
+			},
+			&cli.StringFlag{
+				Name:    "auth_api_keys",
+				Usage:   "Authenticate requests by API key rather than account, set to the store namespace of the valid keys e.g apikeys. Keys are read from {namespace}/{key} with the namespace or service they're scoped to as their value e.g go.micro or go.micro.api.greeter, empty for any. Missing or unknown keys get a 401",
+				EnvVars: []string{"MICRO_API_AUTH_API_KEYS"},
+			},
+			&cli.StringFlag{
+				Name:    "auth_api_key_header",
+				Usage:   "Set the header API keys are read from. Defaults to X-Api-Key",
+				EnvVars: []string{"MICRO_API_AUTH_API_KEY_HEADER"},
+			},
+			&cli.Int64Flag{
+				Name:    "max_proxy_request_body",
+				Usage:   "Set the max size of request bodies proxied by the http handler in bytes overriding max_request_body e.g for uploads",
+				EnvVars: []string{"MICRO_API_MAX_PROXY_REQUEST_BODY"},
+			},
+			&cli.StringFlag{
+				Name:    "form_to_json_paths",
+				Usage:   "Comma separated list of path prefixes whose form requests are converted to JSON before forwarding e.g /v1/signup,/legacy",
+				EnvVars: []string{"MICRO_API_FORM_TO_JSON_PATHS"},
+			},
+			&cli.StringFlag{
+				Name:    "duplicate_header_policy",
+				Usage:   "Set how requests repeating a checked header are handled; {reject, first, last}. Defaults to reject",
+				EnvVars: []string{"MICRO_API_DUPLICATE_HEADER_POLICY"},
+			},
+			&cli.StringFlag{
+				Name:    "duplicate_headers",
+				Usage:   "Comma separated list of headers checked for duplicates. Defaults to Authorization,Proxy-Authorization,Content-Type",
+				EnvVars: []string{"MICRO_API_DUPLICATE_HEADERS"},
+			},
+			&cli.BoolFlag{
+				Name:    "access_log",
+				Usage:   "Enable access logging for all requests",
+				EnvVars: []string{"MICRO_API_ACCESS_LOG"},
+				Value:   true,
+			},
+			&cli.StringFlag{
+				Name:    "access_log_include",
+				Usage:   "Comma separated list of resolved services to always access log e.g go.micro.api.greeter",
+				EnvVars: []string{"MICRO_API_ACCESS_LOG_INCLUDE"},
+			},
+			&cli.StringFlag{
+				Name:    "access_log_exclude",
+				Usage:   "Comma separated list of resolved services to never access log",
+				EnvVars: []string{"MICRO_API_ACCESS_LOG_EXCLUDE"},
+			},
+			&cli.StringFlag{
+				Name:    "access_log_format",
+				Usage:   "Set the format of the access log; {common, combined, json}. json logs the resolved service and latency too. Defaults to combined",
+				EnvVars: []string{"MICRO_API_ACCESS_LOG_FORMAT"},
+			},
+			&cli.StringFlag{
+				Name:    "default_content_type",
+				Usage:   "Set the content type of responses which don't set one e.g application/json",
+				EnvVars: []string{"MICRO_API_DEFAULT_CONTENT_TYPE"},
+			},
+			&cli.BoolFlag{
+				Name:    "disable_content_sniffing",
+				Usage:   "Set X-Content-Type-Options: nosniff on responses so clients don't sniff the content type",
+				EnvVars: []string{"MICRO_API_DISABLE_CONTENT_SNIFFING"},
+			},
+			&cli.StringFlag{
+				Name:    "route_conflict",
+				Usage:   "Set how a service is picked when the path prefixes of several registered services match a request; {longest, first, priority}. Unset by default, the service being picked by the path alone without checking the registry for conflicts",
+				EnvVars: []string{"MICRO_API_ROUTE_CONFLICT"},
+			},
+			&cli.Float64Flag{
+				Name:    "capture_ratio",
+				Usage:   "Capture this fraction of requests to the store for replay e.g 0.01",
+				EnvVars: []string{"MICRO_API_CAPTURE_RATIO"},
+			},
+			&cli.StringFlag{
+				Name:    "capture_paths",
+				Usage:   "Comma separated list of path prefixes to capture requests for",
+				EnvVars: []string{"MICRO_API_CAPTURE_PATHS"},
+			},
+			&cli.StringFlag{
+				Name:    "capture_redact",
+				Usage:   "Comma separated list of headers, query params and json fields to redact when capturing",
+				EnvVars: []string{"MICRO_API_CAPTURE_REDACT"},
+			},
+			&cli.Int64Flag{
+				Name:    "capture_max_body",
+				Usage:   "Set the max number of body bytes captured per request",
+				EnvVars: []string{"MICRO_API_CAPTURE_MAX_BODY"},
+			},
+			&cli.BoolFlag{
+				Name:    "follow_redirects",
+				Usage:   "Follow redirects from backends in the http handler rather than passing them through to the client",
+				EnvVars: []string{"MICRO_API_FOLLOW_REDIRECTS"},
+			},
+			&cli.IntFlag{
+				Name:    "max_redirects",
+				Usage:   "Set the max number of backend redirects followed before passing the redirect through",
+				EnvVars: []string{"MICRO_API_MAX_REDIRECTS"},
+			},
+			&cli.BoolFlag{
+				Name:    "enable_compression",
+				Usage:   "Enable gzip/deflate compression of responses for clients which accept it",
+				EnvVars: []string{"MICRO_API_ENABLE_COMPRESSION"},
+			},
+			&cli.IntFlag{
+				Name:    "compression_level",
+				Usage:   "Set the compression level from 1 (fastest) to 9 (smallest), -1 uses the default",
+				EnvVars: []string{"MICRO_API_COMPRESSION_LEVEL"},
+			},
+			&cli.IntFlag{
+				Name:    "compression_min_size",
+				Usage:   "Set the min size in bytes of a response to compress it, smaller responses are sent as is. Defaults to 1024",
+				EnvVars: []string{"MICRO_API_COMPRESSION_MIN_SIZE"},
+			},
+			&cli.StringFlag{
+				Name:    "compression_skip_paths",
+				Usage:   "Comma separated list of path prefixes whose responses aren't compressed e.g /files",
+				EnvVars: []string{"MICRO_API_COMPRESSION_SKIP_PATHS"},
+			},
+			&cli.StringFlag{
+				Name:    "compression_skip_types",
+				Usage:   "Comma separated list of content type prefixes which aren't compressed. Defaults to images, video, audio and archives",
+				EnvVars: []string{"MICRO_API_COMPRESSION_SKIP_TYPES"},
+			},
+			&cli.StringFlag{
+				Name:    "chunked_policies",
+				Usage:   "Comma separated list of prefix=policy setting how responses are sent; buffer sends them with a Content-Length, chunked streams them e.g /downloads=buffer,/events=chunked",
+				EnvVars: []string{"MICRO_API_CHUNKED_POLICIES"},
+			},
+			&cli.IntFlag{
+				Name:    "chunked_buffer_max",
+				Usage:   "Set the max size of responses buffered by the buffer chunked policy, larger ones are sent chunked. Defaults to 10MB",
+				EnvVars: []string{"MICRO_API_CHUNKED_BUFFER_MAX"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "response_headers",
+				Usage:   "Set a header on every response as key=value, repeatable e.g X-Frame-Options=DENY. Values set by the backend are kept",
+				EnvVars: []string{"MICRO_API_RESPONSE_HEADERS"},
+			},
+			&cli.BoolFlag{
+				Name:    "force_response_headers",
+				Usage:   "Overwrite the values backends set for the response_headers",
+				EnvVars: []string{"MICRO_API_FORCE_RESPONSE_HEADERS"},
+			},
+			&cli.StringFlag{
+				Name:    "upgrade_allowlist",
+				Usage:   "Comma separated list of protocols requests may upgrade to e.g websocket. Defaults to websocket for the http, proxy and web handlers, upgrades being rejected for the others",
+				EnvVars: []string{"MICRO_API_UPGRADE_ALLOWLIST"},
+			},
+			&cli.BoolFlag{
+				Name:    "enable_timing_headers",
+				Usage:   "Debug latency by setting the X-Micro-Upstream-Time and X-Micro-Response-Time headers in milliseconds on responses",
+				EnvVars: []string{"MICRO_API_ENABLE_TIMING_HEADERS"},
+			},
+			&cli.BoolFlag{
+				Name:    "enforce_backend_limits",
+				Usage:   "Reject requests exceeding the max_body_size and rate_limit advertised in a backend's registry metadata",
+				EnvVars: []string{"MICRO_API_ENFORCE_BACKEND_LIMITS"},
+			},
+			&cli.BoolFlag{
+				Name:    "reject_unavailable",
+				Usage:   "Reject requests to services without nodes in the registry with a 503 rather than waiting for the call to fail",
+				EnvVars: []string{"MICRO_API_REJECT_UNAVAILABLE"},
+			},
+			&cli.BoolFlag{
+				Name:    "method_not_allowed",
+				Usage:   "Reject requests whose path matches an api endpoint of the service but not its methods with a 405, listing the methods in the Allow header",
+				EnvVars: []string{"MICRO_API_METHOD_NOT_ALLOWED"},
+			},
+			&cli.StringFlag{
+				Name:    "method_not_allowed_message",
+				Usage:   "Set the message of the error returned with a 405. Defaults to Method not allowed",
+				EnvVars: []string{"MICRO_API_METHOD_NOT_ALLOWED_MESSAGE"},
+			},
+			&cli.StringFlag{
+				Name:    "region",
+				Usage:   "Set the local region, only backends with matching region node metadata, or none, are called e.g us",
+				EnvVars: []string{"MICRO_API_REGION"},
+			},
+			&cli.StringFlag{
+				Name:    "failover_regions",
+				Usage:   "Comma separated list of regions idempotent requests fail over to in priority order when the local region is unreachable",
+				EnvVars: []string{"MICRO_API_FAILOVER_REGIONS"},
+			},
+			&cli.StringFlag{
+				Name:    "zone",
+				Usage:   "Set the local zone, backends with matching zone node metadata are preferred e.g us-east-1a",
+				EnvVars: []string{"MICRO_API_ZONE"},
+			},
+			&cli.StringFlag{
+				Name:    "zone_fallback",
+				Usage:   "Set when requests fall back to other zones; {any, missing, none}. any when the zone has no backends or idempotent requests can't reach them, missing only when it has none. Defaults to any",
+				EnvVars: []string{"MICRO_API_ZONE_FALLBACK"},
+			},
+			&cli.StringFlag{
+				Name:    "selector_strategy",
+				Usage:   "Set how backend nodes are selected; {random, maglev}. maglev consistently hashes requests by affinity_key to the same node. Defaults to random",
+				EnvVars: []string{"MICRO_API_SELECTOR_STRATEGY"},
+			},
+			&cli.StringFlag{
+				Name:    "affinity_key",
+				Usage:   "Set the header or query parameter requests are hashed by with the maglev strategy e.g header:X-User-Id or query:user",
+				EnvVars: []string{"MICRO_API_AFFINITY_KEY"},
+			},
+			&cli.Int64Flag{
+				Name:    "retry_buffer_size",
+				Usage:   "Buffer bodies of idempotent requests, or those with an Idempotency-Key, up to this many bytes so they can be retried. Each in flight request may hold this much memory",
+				EnvVars: []string{"MICRO_API_RETRY_BUFFER_SIZE"},
+			},
+			&cli.IntFlag{
+				Name:    "retry_attempts",
+				Usage:   "Set the number of times a buffered request is retried when the backend responds with a 502, 503 or 504. Defaults to 1",
+				EnvVars: []string{"MICRO_API_RETRY_ATTEMPTS"},
+			},
+			&cli.IntFlag{
+				Name:    "retry_count",
+				Usage:   "Set the number of times failed backend calls of idempotent requests, or those with an Idempotency-Key, are retried on other nodes. Other requests are never retried. Defaults to 1",
+				EnvVars: []string{"MICRO_API_RETRY_COUNT"},
+			},
+			&cli.DurationFlag{
+				Name:    "retry_backoff",
+				Usage:   "Set the time waited before retrying a backend call, doubled on every retry. Defaults to 100ms",
+				EnvVars: []string{"MICRO_API_RETRY_BACKOFF"},
+			},
+			&cli.DurationFlag{
+				Name:    "request_timeout",
+				Usage:   "Set the time after which backend calls are cancelled and the client gets a 504, unlimited if 0. Services can override it in the store at api/timeout/{service}",
+				EnvVars: []string{"MICRO_API_REQUEST_TIMEOUT"},
+			},
+			&cli.StringFlag{
+				Name:    "stats_dimensions",
+				Usage:   "Comma separated list of dimensions to break down /stats by; {route, method, host, status}",
+				EnvVars: []string{"MICRO_API_STATS_DIMENSIONS"},
+			},
+			&cli.BoolFlag{
+				Name:    "enable_metrics",
+				Usage:   "Enable the prometheus /metrics endpoint",
+				EnvVars: []string{"MICRO_API_ENABLE_METRICS"},
+			},
+			&cli.BoolFlag{
+				Name:    "metrics_exemplars",
+				Usage:   "Add the trace id of traced requests to their durations as exemplars, served in the OpenMetrics format when tracing is enabled",
+				EnvVars: []string{"MICRO_API_METRICS_EXEMPLARS"},
+			},
+			&cli.StringFlag{
+				Name:    "slo_thresholds",
+				Usage:   "Comma separated list of service=duration latency objectives exported as metrics e.g go.micro.api.greeter=250ms",
+				EnvVars: []string{"MICRO_API_SLO_THRESHOLDS"},
+			},
+			&cli.DurationFlag{
+				Name:    "slo_window",
+				Usage:   "Set the rolling window latency objectives are tracked over. Defaults to 5m",
+				EnvVars: []string{"MICRO_API_SLO_WINDOW"},
+			},
+			&cli.StringFlag{
+				Name:    "head_mode",
+				Usage:   "Set how HEAD requests are served; {forward, get, auto}. auto falls back to a GET when the backend doesn't implement HEAD",
+				EnvVars: []string{"MICRO_API_HEAD_MODE"},
+			},
+			&cli.StringFlag{
+				Name:    "head_get_paths",
+				Usage:   "Comma separated list of path prefixes whose HEAD requests are always served by a GET",
+				EnvVars: []string{"MICRO_API_HEAD_GET_PATHS"},
+			},
+			&cli.BoolFlag{
+				Name:    "enable_chaos",
+				Usage:   "Enable injecting latency and errors into requests for resilience testing. Never enable in production",
+				EnvVars: []string{"MICRO_API_ENABLE_CHAOS"},
+			},
+			&cli.Float64Flag{
+				Name:    "chaos_latency_ratio",
+				Usage:   "Fraction of requests to inject latency into e.g 0.1",
+				EnvVars: []string{"MICRO_API_CHAOS_LATENCY_RATIO"},
+			},
+			&cli.DurationFlag{
+				Name:    "chaos_latency",
+				Usage:   "Set the base latency injected e.g 500ms",
+				EnvVars: []string{"MICRO_API_CHAOS_LATENCY"},
+			},
+			&cli.DurationFlag{
+				Name:    "chaos_latency_jitter",
+				Usage:   "Set the max random latency added to the base latency",
+				EnvVars: []string{"MICRO_API_CHAOS_LATENCY_JITTER"},
+			},
+			&cli.Float64Flag{
+				Name:    "chaos_error_ratio",
+				Usage:   "Fraction of requests to fail e.g 0.05",
+				EnvVars: []string{"MICRO_API_CHAOS_ERROR_RATIO"},
+			},
+			&cli.IntFlag{
+				Name:    "chaos_error_code",
+				Usage:   "Set the status code of injected errors between 100 and 599",
+				EnvVars: []string{"MICRO_API_CHAOS_ERROR_CODE"},
+				Value:   http.StatusServiceUnavailable,
+			},
+			&cli.StringFlag{
+				Name:    "chaos_paths",
+				Usage:   "Comma separated list of path prefixes to inject faults into, defaults to all",
+				EnvVars: []string{"MICRO_API_CHAOS_PATHS"},
+			},
+			&cli.StringFlag{
+				Name:    "size_routes",
+				Usage:   "Comma separated list of prefix:threshold:service routing requests larger than threshold bytes to the service e.g /files/upload:1048576:go.micro.api.upload",
+				EnvVars: []string{"MICRO_API_SIZE_ROUTES"},
+			},
+			&cli.StringFlag{
+				Name:    "size_route_unknown",
+				Usage:   "Set how size routes treat requests without a Content-Length e.g chunked uploads; {small, large}",
+				EnvVars: []string{"MICRO_API_SIZE_ROUTE_UNKNOWN"},
+			},
+			&cli.StringFlag{
+				Name:    "shutdown_order",
+				Usage:   "Set the shutdown order; service stops the service first, api stops and drains the http server before deregistering; {service, api}",
+				EnvVars: []string{"MICRO_API_SHUTDOWN_ORDER"},
+			},
+			&cli.DurationFlag{
+				Name:    "shutdown_drain_timeout",
+				Aliases: []string{"shutdown_timeout"},
+				Usage:   "Set how long to wait for in flight requests to complete on shutdown, closing them after. Defaults to 30s, 0 closes them straight away",
+				EnvVars: []string{"MICRO_API_SHUTDOWN_DRAIN_TIMEOUT"},
+			},
+			&cli.DurationFlag{
+				Name:    "shutdown_delay",
+				Usage:   "Set how long to keep serving on shutdown with the readiness check reporting draining, so load balancers stop routing first e.g 5s",
+				EnvVars: []string{"MICRO_API_SHUTDOWN_DELAY"},
+			},
+			&cli.DurationFlag{
+				Name:    "read_timeout",
+				Usage:   "Set the max time to read a request including the body, unlimited if 0. Defaults to 60s",
+				EnvVars: []string{"MICRO_API_READ_TIMEOUT"},
+			},
+			&cli.DurationFlag{
+				Name:    "write_timeout",
+				Usage:   "Set the max time to write a response, unlimited if 0. Long lived responses such as event streams need a longer timeout. Defaults to 60s",
+				EnvVars: []string{"MICRO_API_WRITE_TIMEOUT"},
+			},
+			&cli.DurationFlag{
+				Name:    "idle_timeout",
+				Usage:   "Set the max time to wait for the next request on a keep alive connection, unlimited if 0. Defaults to 120s",
+				EnvVars: []string{"MICRO_API_IDLE_TIMEOUT"},
+			},
+			&cli.IntFlag{
+				Name:    "proxy_buffer_size",
+				Usage:   "Set the size in bytes of the pooled buffers the http handler copies proxied bodies through. Defaults to 32768",
+				EnvVars: []string{"MICRO_API_PROXY_BUFFER_SIZE"},
+			},
+			&cli.Int64Flag{
+				Name:    "backend_max_header_bytes",
+				Usage:   "Set the max size of the response headers of proxied backends, larger responses are rejected with a 502. Defaults to 1048576",
+				EnvVars: []string{"MICRO_API_BACKEND_MAX_HEADER_BYTES"},
+			},
+			&cli.DurationFlag{
+				Name:    "dial_timeout",
+				Usage:   "Set the time to wait for a connection to a backend, failing with a 503 when it can't be established, separate from the request timeout. Defaults to 30s for proxied requests and 5s for calls",
+				EnvVars: []string{"MICRO_API_DIAL_TIMEOUT"},
+			},
+			&cli.StringFlag{
+				Name:    "health_path",
+				Usage:   "Set the path of the liveness check. Defaults to /healthz",
+				EnvVars: []string{"MICRO_API_HEALTH_PATH"},
+			},
+			&cli.StringFlag{
+				Name:    "ready_path",
+				Usage:   "Set the path of the readiness check, ready once registered while the registry is reachable. Defaults to /readyz",
+				EnvVars: []string{"MICRO_API_READY_PATH"},
+			},
+			&cli.BoolFlag{
+				Name:    "enable_tracing",
+				Usage:   "Enable tracing of requests to backends",
+				EnvVars: []string{"MICRO_API_ENABLE_TRACING"},
+			},
+			&cli.Float64Flag{
+				Name:    "trace_sample_rate",
+				Usage:   "Set the default fraction of requests traced between 0 and 1",
+				EnvVars: []string{"MICRO_API_TRACE_SAMPLE_RATE"},
+			},
+			&cli.StringFlag{
+				Name:    "trace_sample_rates",
+				Usage:   "Comma separated list of service=rate overriding the sample rate per service e.g go.micro.api.greeter=1",
+				EnvVars: []string{"MICRO_API_TRACE_SAMPLE_RATES"},
+			},
+			&cli.StringFlag{
+				Name:    "trace_path_sample_rates",
+				Usage:   "Set the path of a json file mapping path prefixes to sample rates taking precedence over the service rates e.g {\"/checkout\": 1}",
+				EnvVars: []string{"MICRO_API_TRACE_PATH_SAMPLE_RATES"},
+			},
+			&cli.StringFlag{
+				Name:    "trace_otlp_endpoint",
+				Usage:   "Set the url of an opentelemetry collector traces are exported to over OTLP/HTTP e.g http://localhost:4318",
+				EnvVars: []string{"MICRO_API_TRACE_OTLP_ENDPOINT"},
+			},
+			&cli.StringFlag{
+				Name:    "request_encodings",
+				Usage:   "Comma separated list of request Content-Encodings accepted, others are rejected with a 415 e.g gzip,deflate",
+				EnvVars: []string{"MICRO_API_REQUEST_ENCODINGS"},
+			},
+			&cli.BoolFlag{
+				Name:    "decompress_requests",
+				Usage:   "Decompress gzip and deflate request bodies before forwarding them",
+				EnvVars: []string{"MICRO_API_DECOMPRESS_REQUESTS"},
+			},
+			&cli.Int64Flag{
+				Name:    "decompress_max_size",
+				Usage:   "Set the max size of a decompressed request body, larger requests are rejected with a 413. Defaults to 10MB",
+				EnvVars: []string{"MICRO_API_DECOMPRESS_MAX_SIZE"},
+			},
+			&cli.StringFlag{
+				Name:    "composite_config",
+				Usage:   "Set the path of a json file mapping paths to backend calls merged into one response e.g {\"/dashboard\": {\"user\": {\"service\": \"go.micro.srv.user\", \"endpoint\": \"User.Read\"}}}",
+				EnvVars: []string{"MICRO_API_COMPOSITE_CONFIG"},
+			},
+			&cli.BoolFlag{
+				Name:    "tls_check_revocation",
+				Usage:   "Reject requests with revoked client certificates with a 403, checking the CRLs and OCSP responders listed in them unless overridden",
+				EnvVars: []string{"MICRO_API_TLS_CHECK_REVOCATION"},
+			},
+			&cli.StringFlag{
+				Name:    "tls_crl_url",
+				Usage:   "Set the URL of the CRL client certificates are checked against",
+				EnvVars: []string{"MICRO_API_TLS_CRL_URL"},
+			},
+			&cli.StringFlag{
+				Name:    "tls_ocsp_responder",
+				Usage:   "Set the URL of the OCSP responder client certificates are checked against",
+				EnvVars: []string{"MICRO_API_TLS_OCSP_RESPONDER"},
+			},
+			&cli.DurationFlag{
+				Name:    "tls_revocation_refresh",
+				Usage:   "Set how long CRLs and OCSP responses are cached e.g 1h",
+				EnvVars: []string{"MICRO_API_TLS_REVOCATION_REFRESH"},
+			},
+			&cli.BoolFlag{
+				Name:    "tls_revocation_strict",
+				Usage:   "Reject client certificates whose revocation status can't be determined",
+				EnvVars: []string{"MICRO_API_TLS_REVOCATION_STRICT"},
+			},
+			&cli.StringFlag{
+				Name:    "tls_min_version",
+				Usage:   "Set the min TLS version accepted; 1.0, 1.1, 1.2 or 1.3. Defaults to 1.2",
+				EnvVars: []string{"MICRO_API_TLS_MIN_VERSION"},
+			},
+			&cli.StringFlag{
+				Name:    "tls_cipher_suites",
+				Usage:   "Comma separated list of the cipher suites accepted up to TLS 1.2 e.g TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Ignored with a min version of 1.3 as TLS 1.3 suites aren't configurable",
+				EnvVars: []string{"MICRO_API_TLS_CIPHER_SUITES"},
+			},
+			&cli.StringFlag{
+				Name:    "tls_client_ca",
+				Usage:   "Set the PEM file of the CAs client certificates are verified against, requiring a valid client certificate unless tls_client_auth is set",
+				EnvVars: []string{"MICRO_API_TLS_CLIENT_CA"},
+			},
+			&cli.StringFlag{
+				Name:    "tls_client_auth",
+				Usage:   "Set whether client certificates are verified; none, request verifying those given or require-and-verify. The common name of verified certificates is passed in the X-Micro-Client-Cert-CN header",
+				EnvVars: []string{"MICRO_API_TLS_CLIENT_AUTH"},
+			},
+			&cli.BoolFlag{
+				Name:    "tls_info_headers",
+				Usage:   "Pass the TLS version, cipher suite and server name of requests to backends in the X-Micro-Tls-Version, X-Micro-Tls-Cipher-Suite and X-Micro-Tls-Server-Name headers. Dropped from requests without TLS",
+				EnvVars: []string{"MICRO_API_TLS_INFO_HEADERS"},
+			},
+			&cli.StringFlag{
+				Name:    "tls_next_protos",
+				Usage:   "Comma separated list of the ALPN protocols offered during the TLS handshake in order of preference e.g h2,http/1.1. Defaults to h2,http/1.1",
+				EnvVars: []string{"MICRO_API_TLS_NEXT_PROTOS"},
+			},
+			&cli.StringFlag{
+				Name:    "namespace_overrides",
+				Usage:   "Comma separated list of prefix=[namespace:]type overriding the namespace and type of requests by path e.g /grpc/=grpc,/legacy/=com.example:api",
+				EnvVars: []string{"MICRO_API_NAMESPACE_OVERRIDES"},
+			},
+			&cli.IntFlag{
+				Name:    "http2_max_concurrent_streams",
+				Usage:   "Set the max number of concurrent streams a client can open on an HTTP/2 connection. Defaults to 250",
+				EnvVars: []string{"MICRO_API_HTTP2_MAX_CONCURRENT_STREAMS"},
+			},
+			&cli.IntFlag{
+				Name:    "conn_max_request_rate",
+				Usage:   "Set the max number of requests a connection can start per second before it's closed, 0 for unlimited. Defaults to 0",
+				EnvVars: []string{"MICRO_API_CONN_MAX_REQUEST_RATE"},
+			},
+			&cli.IntFlag{
+				Name:    "conn_max_reset_rate",
+				Usage:   "Set the max number of requests a connection can cancel per second before it's closed, e.g HTTP/2 rapid resets, 0 for unlimited. Defaults to 100",
+				EnvVars: []string{"MICRO_API_CONN_MAX_RESET_RATE"},
+			},
+			&cli.StringFlag{
+				Name:    "admin_token",
+				Usage:   "Set the token required in the X-Micro-Admin-Token header by admin endpoints e.g /_loglevel, /_plugins and /_rollouts. Admin endpoints are disabled without it",
+				EnvVars: []string{"MICRO_API_ADMIN_TOKEN"},
+			},
+			&cli.BoolFlag{
+				Name:    "enable_pprof",
+				Usage:   "Enable the pprof profiling endpoints at /debug/pprof/ on pprof_address, never on the api address",
+				EnvVars: []string{"MICRO_API_ENABLE_PPROF"},
+			},
+			&cli.StringFlag{
+				Name:    "pprof_address",
+				Usage:   "Set the address of the dedicated listener the pprof endpoints are served on. Defaults to 127.0.0.1:6060",
+				EnvVars: []string{"MICRO_API_PPROF_ADDRESS"},
+			},
+			&cli.BoolFlag{
+				Name:    "decode_responses",
+				Usage:   "Decompress proxied responses whose Content-Encoding the client doesn't accept",
+				EnvVars: []string{"MICRO_API_DECODE_RESPONSES"},
+			},
+			&cli.IntFlag{
+				Name:    "bulkhead_limit",
+				Usage:   "Set the max number of requests in flight to each service, unlimited if 0. Queue wait times and rejections are reported in /stats",
+				EnvVars: []string{"MICRO_API_BULKHEAD_LIMIT"},
+			},
+			&cli.StringFlag{
+				Name:    "bulkhead_limits",
+				Usage:   "Comma separated list of service=limit overriding the bulkhead limit per service e.g go.micro.api.greeter=10",
+				EnvVars: []string{"MICRO_API_BULKHEAD_LIMITS"},
+			},
+			&cli.DurationFlag{
+				Name:    "bulkhead_queue_timeout",
+				Usage:   "Set how long requests wait for a bulkhead slot before they're rejected. Defaults to 1s",
+				EnvVars: []string{"MICRO_API_BULKHEAD_QUEUE_TIMEOUT"},
+			},
+			&cli.BoolFlag{
+				Name:    "require_https",
+				Usage:   "Require requests to be made over HTTPS, determined from X-Forwarded-Proto when sent by a trusted proxy",
+				EnvVars: []string{"MICRO_API_REQUIRE_HTTPS"},
+			},
+			&cli.StringFlag{
+				Name:    "https_policy",
+				Usage:   "Set how plain HTTP requests are handled when HTTPS is required; redirect redirects GET and HEAD requests and rejects the rest; {redirect, reject}",
+				EnvVars: []string{"MICRO_API_HTTPS_POLICY"},
+			},
+			&cli.StringFlag{
+				Name:    "trusted_proxies",
+				Usage:   "Comma separated list of IPs or CIDRs of proxies whose forwarded headers are trusted e.g 10.0.0.0/8",
+				EnvVars: []string{"MICRO_API_TRUSTED_PROXIES"},
+			},
+			&cli.StringFlag{
+				Name:    "template_routes",
+				Usage:   "Set the path of a json file mapping url templates to backend methods e.g [{\"method\": \"GET\", \"path\": \"/users/{id}\", \"service\": \"go.micro.srv.users\", \"endpoint\": \"Users.Get\"}]",
+				EnvVars: []string{"MICRO_API_TEMPLATE_ROUTES"},
+			},
+			&cli.StringFlag{
+				Name:    "query_defaults",
+				Usage:   "Set the path of a json file mapping path prefixes to query params added when a request doesn't set them e.g {\"/users\": {\"limit\": \"50\"}}",
+				EnvVars: []string{"MICRO_API_QUERY_DEFAULTS"},
+			},
+			&cli.StringFlag{
+				Name:    "body_schemas",
+				Usage:   "Set the path of a json file mapping path prefixes to the JSON Schema of their request bodies, the defaults of properties a json body leaves out being filled in e.g {\"/users\": {\"type\": \"object\", \"properties\": {\"role\": {\"type\": \"string\", \"default\": \"member\"}}}}",
+				EnvVars: []string{"MICRO_API_BODY_SCHEMAS"},
+			},
+			&cli.StringFlag{
+				Name:    "required_headers",
+				Usage:   "Set the path of a json file mapping path prefixes to headers requests must set, rejected with a 400 otherwise e.g {\"/orders\": [\"X-API-Version\"]}",
+				EnvVars: []string{"MICRO_API_REQUIRED_HEADERS"},
+			},
+			&cli.StringFlag{
+				Name:    "status_rewrites",
+				Usage:   "Set the path of a json file mapping path prefixes to the status codes of responses rewritten e.g {\"/legacy\": {\"422\": 400}}",
+				EnvVars: []string{"MICRO_API_STATUS_REWRITES"},
+			},
+			&cli.StringFlag{
+				Name:    "rollouts",
+				Usage:   "Comma separated list of service=from:to versions to progressively shift traffic between e.g go.micro.srv.greeter=1.0.0:2.0.0",
+				EnvVars: []string{"MICRO_API_ROLLOUTS"},
+			},
+			&cli.IntFlag{
+				Name:    "rollout_step",
+				Usage:   "Set the percentage of traffic shifted to the new version every interval",
+				EnvVars: []string{"MICRO_API_ROLLOUT_STEP"},
+				Value:   10,
+			},
+			&cli.DurationFlag{
+				Name:    "rollout_interval",
+				Usage:   "Set the interval between rollout steps e.g 5m",
+				EnvVars: []string{"MICRO_API_ROLLOUT_INTERVAL"},
+				Value:   5 * time.Minute,
+			},
+			&cli.Float64Flag{
+				Name:    "rollout_max_error_rate",
+				Usage:   "Set the error rate of the new version between 0 and 1 which rolls a rollout back",
+				EnvVars: []string{"MICRO_API_ROLLOUT_MAX_ERROR_RATE"},
+				Value:   0.05,
+			},
+			&cli.IntFlag{
+				Name:    "rollout_min_requests",
+				Usage:   "Set the min number of requests to the new version before a rollout moves on a step or rolls back",
+				EnvVars: []string{"MICRO_API_ROLLOUT_MIN_REQUESTS"},
+				Value:   20,
+			},
+			&cli.DurationFlag{
+				Name:    "acme_timeout",
+				Usage:   "Set the timeout of requests made by the ACME provider e.g 30s",
+				EnvVars: []string{"MICRO_API_ACME_TIMEOUT"},
+				Value:   30 * time.Second,
+			},
+			&cli.StringFlag{
+				Name:    "acme_challenge_provider",
+				Usage:   "Set the DNS challenge provider of certmagic, cloudflare or route53. Defaults to cloudflare",
+				EnvVars: []string{"MICRO_API_ACME_CHALLENGE_PROVIDER"},
+			},
+			&cli.StringFlag{
+				Name:    "acme_storage",
+				Usage:   "Set the store certmagic keeps certificates in, cloudflare, s3 or file. Defaults to the store of the challenge provider",
+				EnvVars: []string{"MICRO_API_ACME_STORAGE"},
+			},
+			&cli.StringFlag{
+				Name:    "acme_storage_dir",
+				Usage:   "Set the directory certificates are kept in by the file storage. Defaults to the certmagic data directory",
+				EnvVars: []string{"MICRO_API_ACME_STORAGE_DIR"},
+			},
+			&cli.StringFlag{
+				Name:    "rate_limit_headers",
+				Usage:   "Comma separated list of the limit, remaining and reset header names set on rate limited responses e.g RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset",
+				EnvVars: []string{"MICRO_API_RATE_LIMIT_HEADERS"},
+			},
+			&cli.Float64Flag{
+				Name:    "rate_limit",
+				Usage:   "Set the requests per second each client can make to a service, unlimited if 0. Clients over the limit get a 429",
+				EnvVars: []string{"MICRO_API_RATE_LIMIT"},
+			},
+			&cli.IntFlag{
+				Name:    "rate_limit_burst",
+				Usage:   "Set the max burst of requests a client can make to a service. Defaults to a second of requests",
+				EnvVars: []string{"MICRO_API_RATE_LIMIT_BURST"},
+			},
+			&cli.StringFlag{
+				Name:    "rate_limit_key",
+				Usage:   "Set the header identifying clients to rate limit e.g X-Api-Key. Defaults to the client IP",
+				EnvVars: []string{"MICRO_API_RATE_LIMIT_KEY"},
+			},
+			&cli.Float64Flag{
+				Name:    "tenant_rate_limit",
+				Usage:   "Set the requests per second each tenant can make across services, unlimited if 0. Tenants over the limit get a 429",
+				EnvVars: []string{"MICRO_API_TENANT_RATE_LIMIT"},
+			},
+			&cli.IntFlag{
+				Name:    "tenant_rate_limit_burst",
+				Usage:   "Set the max burst of requests a tenant can make. Defaults to a second of requests",
+				EnvVars: []string{"MICRO_API_TENANT_RATE_LIMIT_BURST"},
+			},
+			&cli.StringFlag{
+				Name:    "tenant_rate_limit_key",
+				Usage:   "Set the header identifying tenants to rate limit e.g X-Tenant-Id. Defaults to the namespace the request resolves to",
+				EnvVars: []string{"MICRO_API_TENANT_RATE_LIMIT_KEY"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "tenant_rate_limits",
+				Usage:   "Set the limit of a tenant overriding tenant_rate_limit in the format tenant=rate or tenant=rate:burst, can be repeated",
+				EnvVars: []string{"MICRO_API_TENANT_RATE_LIMITS"},
+			},
+			&cli.BoolFlag{
+				Name:    "serve_stale_on_error",
+				Usage:   "Serve the last successful response of GET requests when the backend fails",
+				EnvVars: []string{"MICRO_API_SERVE_STALE_ON_ERROR"},
+			},
+			&cli.DurationFlag{
+				Name:    "serve_stale_max_age",
+				Usage:   "Set the max age of stale responses served on backend failure e.g 1h",
+				EnvVars: []string{"MICRO_API_SERVE_STALE_MAX_AGE"},
+				Value:   time.Hour,
+			},
+			&cli.StringFlag{
+				Name:    "serve_stale_methods",
+				Usage:   "Set the path of a json file mapping path prefixes to the methods cached, overriding GET only e.g {\"/search\": [\"GET\", \"POST\"]}",
+				EnvVars: []string{"MICRO_API_SERVE_STALE_METHODS"},
+			},
+			&cli.StringFlag{
+				Name:    "path_case",
+				Usage:   "Comma separated list of prefix=mode lowercasing the paths under a prefix before they're resolved; {path, service} e.g /greeter=service",
+				EnvVars: []string{"MICRO_API_PATH_CASE"},
+			},
+			&cli.StringSliceFlag{
+				Name:    "rewrite",
+				Usage:   "Rewrite request paths matching a regex before they're resolved, in the format pattern=replacement, can be repeated. The first rule matching wins and replacements can refer to captured groups e.g ^/v1/(.*)$=/$1",
+				EnvVars: []string{"MICRO_API_REWRITE"},
+			},
+			&cli.IntFlag{
+				Name:    "max_path_depth",
+				Usage:   "Set the max number of segments in a request path, deeper paths get a 400. Defaults to 32",
+				EnvVars: []string{"MICRO_API_MAX_PATH_DEPTH"},
+			},
+			&cli.BoolFlag{
+				Name:    "maintenance",
+				Usage:   "Reject requests with a 503 apart from those on the maintenance allowlist",
+				EnvVars: []string{"MICRO_API_MAINTENANCE"},
+			},
+			&cli.StringFlag{
+				Name:    "maintenance_allowlist",
+				Usage:   "Comma separated list of client IPs or CIDRs let through in maintenance mode e.g 10.0.0.0/8",
+				EnvVars: []string{"MICRO_API_MAINTENANCE_ALLOWLIST"},
+			},
+			&cli.StringFlag{
+				Name:    "maintenance_keys",
+				Usage:   "Comma separated list of api keys sent in the X-Api-Key header let through in maintenance mode",
+				EnvVars: []string{"MICRO_API_MAINTENANCE_KEYS"},
+			},
+			&cli.StringFlag{
+				Name:    "error_format",
+				Usage:   "Set the format of errors generated by the gateway; {text, micro, error, message, jsonapi, template}",
+				EnvVars: []string{"MICRO_API_ERROR_FORMAT"},
+			},
+			&cli.StringFlag{
+				Name:    "error_template",
+				Usage:   "Set the template of errors in the template format, passed the ID, Code, Status and Message e.g {\"failure\": {{json .Message}}}",
+				EnvVars: []string{"MICRO_API_ERROR_TEMPLATE"},
+			},
+			&cli.StringFlag{
+				Name:    "request_id_header",
+				Usage:   "Set the header used to propagate request ids",
+				EnvVars: []string{"MICRO_API_REQUEST_ID_HEADER"},
+			},
+			&cli.StringFlag{
+				Name:    "request_id_format",
+				Usage:   "Set the format of generated request ids; {uuid, ulid, counter}",
+				EnvVars: []string{"MICRO_API_REQUEST_ID_FORMAT"},
+			},
+			&cli.StringFlag{
+				Name:    "request_id_prefix",
+				Usage:   "Set the prefix of counter request ids",
+				EnvVars: []string{"MICRO_API_REQUEST_ID_PREFIX"},
+			},
+			&cli.StringFlag{
+				Name:    "request_id_pattern",
+				Usage:   "Regenerate incoming request ids which don't match this pattern e.g ^[a-f0-9-]{36}$",
+				EnvVars: []string{"MICRO_API_REQUEST_ID_PATTERN"},
+			},
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:  "replay",
+				Usage: "Replay captured requests against a target",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "target",
+						Usage:   "Set the address to replay requests against e.g http://localhost:8080",
+						EnvVars: []string{"MICRO_API_REPLAY_TARGET"},
+					},
+				},
+				Action: replay,
 			},
 		},
 	}
@@ -11,26 +11,67 @@ import (
 	"github.com/micro/go-micro/v2/api/server"
 	"github.com/micro/go-micro/v2/auth"
 	"github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/store"
+	"github.com/micro/micro/v2/internal/errorformat"
 	"github.com/micro/micro/v2/internal/namespace"
 )
 
+// Options of the auth wrapper
+type Options struct {
+	// store of the valid api keys, requests are authenticated by api key
+	// rather than account when set
+	APIKeys store.Store
+	// prefix of the api keys in the store
+	APIKeyPrefix string
+	// header the api key is read from
+	APIKeyHeader string
+}
+
+type Option func(o *Options)
+
+// WithAPIKeys authenticates requests by the api key sent in the header.
+// Valid keys are those in the store under the prefix, their value being
+// the scope of the key: a namespace, e.g go.micro, a single service, e.g
+// go.micro.api.greeter, or empty for any.
+func WithAPIKeys(st store.Store, prefix, header string) Option {
+	return func(o *Options) {
+		o.APIKeys = st
+		o.APIKeyPrefix = prefix
+		o.APIKeyHeader = header
+	}
+}
+
 // Wrapper wraps a handler and authenticates requests
-func Wrapper(r resolver.Resolver, nr *namespace.Resolver) server.Wrapper {
+func Wrapper(r resolver.Resolver, nr *namespace.Resolver, opts ...Option) server.Wrapper {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+
 	return func(h http.Handler) http.Handler {
 		return authWrapper{
 			handler:    h,
 			resolver:   r,
 			nsResolver: nr,
 			auth:       auth.DefaultAuth,
+			opts:       options,
 		}
 	}
 }
 
+// endpointsResolver resolves requests calling several backends, e.g.
+// composite routes, all of which the account must have access to. None
+// are returned for requests resolved to a single endpoint.
+type endpointsResolver interface {
+	ResolveEndpoints(req *http.Request) []*resolver.Endpoint
+}
+
 type authWrapper struct {
 	handler    http.Handler
 	auth       auth.Auth
 	resolver   resolver.Resolver
 	nsResolver *namespace.Resolver
+	opts       Options
 }
 
 func (a authWrapper) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -68,7 +109,7 @@ func (a authWrapper) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		endpoint = &resolver.Endpoint{Path: req.URL.Path}
 	} else if err != nil {
 		logger.Error(err)
-		http.Error(w, err.Error(), 500)
+		errorformat.Error(w, err.Error(), 500)
 		return
 	} else {
 		// set the endpoint in the context so it can be used to resolve
@@ -77,24 +118,29 @@ func (a authWrapper) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		*req = *req.Clone(ctx)
 	}
 
-	// construct the resource name, e.g. home => go.micro.web.home
-	resName := namespace
-	if len(endpoint.Name) > 0 {
-		resName = namespace + "." + endpoint.Name
+	if a.opts.APIKeys != nil {
+		a.serveAPIKey(w, req, namespace, endpoint.Name)
+		return
 	}
 
-	// determine the resource path. there is an inconsistency in how resolvers
-	// use method, some use it as Users.ReadUser (the rpc method), and others
-	// use it as the HTTP method, e.g GET. TODO: Refactor this to make it consistent.
-	resEndpoint := endpoint.Path
-	if len(endpoint.Path) == 0 {
-		resEndpoint = endpoint.Method
+	// the endpoints the request calls, which it must have access to all of
+	endpoints := []*resolver.Endpoint{endpoint}
+	if er, ok := a.resolver.(endpointsResolver); ok {
+		if eps := er.ResolveEndpoints(req); len(eps) > 0 {
+			endpoints = eps
+		}
 	}
 
 	// Perform the verification check to see if the account has access to
-	// the resource they're requesting
-	res := &auth.Resource{Type: "service", Name: resName, Endpoint: resEndpoint, Namespace: namespace}
-	if err := a.auth.Verify(acc, res); err == nil {
+	// the resources they're requesting
+	verified := true
+	for _, ep := range endpoints {
+		if err := a.auth.Verify(acc, resource(namespace, ep)); err != nil {
+			verified = false
+			break
+		}
+	}
+	if verified {
 		// The account has the necessary permissions to access the resource
 		a.handler.ServeHTTP(w, req)
 		return
@@ -103,14 +149,14 @@ func (a authWrapper) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// The account is set, but they don't have enough permissions, hence
 	// we return a forbidden error.
 	if len(acc.ID) > 0 {
-		http.Error(w, "Forbidden request", 403)
+		errorformat.Error(w, "Forbidden request", 403)
 		return
 	}
 
 	// If there is no auth login url set, 401
 	loginURL := a.auth.Options().LoginURL
 	if loginURL == "" {
-		http.Error(w, "unauthorized request", 401)
+		errorformat.Error(w, "unauthorized request", 401)
 		return
 	}
 
@@ -119,3 +165,56 @@ func (a authWrapper) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	loginWithRedirect := fmt.Sprintf("%v?%v", loginURL, params.Encode())
 	http.Redirect(w, req, loginWithRedirect, http.StatusTemporaryRedirect)
 }
+
+// serveAPIKey serves requests with a valid api key scoped to the namespace
+// or the service, missing or unknown keys get a 401 and those scoped to
+// others a 403
+func (a authWrapper) serveAPIKey(w http.ResponseWriter, req *http.Request, namespace, service string) {
+	key := req.Header.Get(a.opts.APIKeyHeader)
+	if len(key) == 0 {
+		errorformat.Error(w, "unauthorized request", 401)
+		return
+	}
+
+	recs, err := a.opts.APIKeys.Read(a.opts.APIKeyPrefix + key)
+	if err == store.ErrNotFound || (err == nil && len(recs) == 0) {
+		errorformat.Error(w, "unauthorized request", 401)
+		return
+	} else if err != nil {
+		logger.Errorf("Failed to read api key: %v", err)
+		errorformat.Error(w, "Failed to authenticate request", 500)
+		return
+	}
+
+	// keys are scoped to a namespace or service and those within it, e.g
+	// go.micro covers go.micro.api and go.micro.api.greeter only itself
+	scope := string(recs[0].Value)
+	within := func(name string) bool {
+		return len(name) > 0 && (name == scope || strings.HasPrefix(name, scope+"."))
+	}
+	if len(scope) > 0 && !within(namespace) && !within(service) {
+		errorformat.Error(w, "Forbidden request", 403)
+		return
+	}
+
+	a.handler.ServeHTTP(w, req)
+}
+
+// resource returns the resource of the endpoint in the namespace
+func resource(namespace string, endpoint *resolver.Endpoint) *auth.Resource {
+	// construct the resource name, e.g. home => go.micro.web.home
+	resName := namespace
+	if len(endpoint.Name) > 0 {
+		resName = namespace + "." + endpoint.Name
+	}
+
+	// determine the resource path. there is an inconsistency in how resolvers
+	// use method, some use it as Users.ReadUser (the rpc method), and others
+	// use it as the HTTP method, e.g GET. TODO: Refactor this to make it consistent.
+	resEndpoint := endpoint.Path
+	if len(endpoint.Path) == 0 {
+		resEndpoint = endpoint.Method
+	}
+
+	return &auth.Resource{Type: "service", Name: resName, Endpoint: resEndpoint, Namespace: namespace}
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/auth"
+	"github.com/micro/go-micro/v2/store"
+	"github.com/micro/micro/v2/internal/errorformat"
+	"github.com/micro/micro/v2/internal/namespace"
+)
+
+// testAuth denies every request
+type testAuth struct {
+	auth.Auth
+	account *auth.Account
+}
+
+func (a *testAuth) Inspect(token string) (*auth.Account, error) {
+	return a.account, nil
+}
+
+func (a *testAuth) Verify(acc *auth.Account, res *auth.Resource) error {
+	return auth.ErrForbidden
+}
+
+func (a *testAuth) Options() auth.Options {
+	return auth.Options{}
+}
+
+// testResolver resolves every request to the greeter
+type testResolver struct{}
+
+func (testResolver) Resolve(r *http.Request) (*resolver.Endpoint, error) {
+	return &resolver.Endpoint{Name: "greeter", Path: r.URL.Path}, nil
+}
+
+func (testResolver) String() string {
+	return "test"
+}
+
+func TestWrapperErrorFormat(t *testing.T) {
+	e, err := errorformat.New("message", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errorformat.Set(e)
+	defer errorformat.Set(nil)
+
+	testData := []struct {
+		account *auth.Account
+		code    int
+		body    string
+	}{
+		{&auth.Account{}, http.StatusUnauthorized, `{"message":"unauthorized request"}`},
+		{&auth.Account{ID: "user"}, http.StatusForbidden, `{"message":"Forbidden request"}`},
+	}
+
+	for _, d := range testData {
+		h := authWrapper{
+			handler:    http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			auth:       &testAuth{account: d.account},
+			resolver:   testResolver{},
+			nsResolver: namespace.NewResolver("api", "go.micro"),
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/greeter", nil))
+
+		if w.Code != d.code || w.Body.String() != d.body {
+			t.Fatalf("Expected %d %s got %d %s", d.code, d.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+// allowAuth allows access to every resource but those denied
+type allowAuth struct {
+	testAuth
+	denied string
+}
+
+func (a *allowAuth) Verify(acc *auth.Account, res *auth.Resource) error {
+	if res.Name == a.denied {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+// compositeResolver resolves every request to several backends
+type compositeResolver struct {
+	testResolver
+}
+
+func (compositeResolver) ResolveEndpoints(r *http.Request) []*resolver.Endpoint {
+	return []*resolver.Endpoint{{Name: "greeter"}, {Name: "users"}}
+}
+
+func TestWrapperEndpoints(t *testing.T) {
+	testData := []struct {
+		denied string
+		code   int
+	}{
+		{"", http.StatusOK},
+		{"go.micro.api.greeter", http.StatusForbidden},
+		// denied access to any backend the request calls
+		{"go.micro.api.users", http.StatusForbidden},
+	}
+
+	for _, d := range testData {
+		h := authWrapper{
+			handler:    http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			auth:       &allowAuth{testAuth: testAuth{account: &auth.Account{ID: "user"}}, denied: d.denied},
+			resolver:   compositeResolver{},
+			nsResolver: namespace.NewResolver("api", "go.micro"),
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/dashboard", nil))
+
+		if w.Code != d.code {
+			t.Fatalf("Expected %d with %q denied got %d", d.code, d.denied, w.Code)
+		}
+	}
+}
+
+// keyStore holds api keys
+type keyStore struct {
+	store.Store
+	keys map[string]string
+}
+
+func (s *keyStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
+	v, ok := s.keys[key]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return []*store.Record{{Key: key, Value: []byte(v)}}, nil
+}
+
+func TestWrapperAPIKeys(t *testing.T) {
+	st := &keyStore{keys: map[string]string{
+		"apikeys/any":   "",
+		"apikeys/micro": "go.micro",
+		"apikeys/other": "com.example",
+		// the test resolver resolves every request to the greeter
+		"apikeys/greeter": "greeter",
+		"apikeys/users":   "go.micro.api.users",
+	}}
+
+	testData := []struct {
+		key  string
+		code int
+	}{
+		{"any", http.StatusOK},
+		// keys cover the namespaces within theirs
+		{"micro", http.StatusOK},
+		{"other", http.StatusForbidden},
+		// keys scoped to a service only cover it
+		{"greeter", http.StatusOK},
+		{"users", http.StatusForbidden},
+		{"unknown", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+
+	for _, d := range testData {
+		var options Options
+		WithAPIKeys(st, "apikeys/", "X-Api-Key")(&options)
+		// accounts aren't checked with api keys, the test auth denying all
+		h := authWrapper{
+			handler:    http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			auth:       &testAuth{account: &auth.Account{}},
+			resolver:   testResolver{},
+			nsResolver: namespace.NewResolver("api", "go.micro"),
+			opts:       options,
+		}
+
+		r := httptest.NewRequest("GET", "/greeter", nil)
+		if len(d.key) > 0 {
+			r.Header.Set("X-Api-Key", d.key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected %d for key %q got %d", d.code, d.key, w.Code)
+		}
+	}
+}
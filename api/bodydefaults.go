@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// bodySchema is the part of a JSON Schema defaults are applied by, the
+// properties of an object and their default values
+type bodySchema struct {
+	Type       string                 `json:"type"`
+	Properties map[string]*bodySchema `json:"properties"`
+	Default    json.RawMessage        `json:"default"`
+}
+
+// loadBodySchemas reads the schemas of request bodies from a json file
+// mapping path prefixes to a JSON Schema e.g
+//
+//	{"/users": {"type": "object", "properties": {"role": {"type": "string", "default": "member"}}}}
+func loadBodySchemas(file string) (map[string]*bodySchema, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas map[string]*bodySchema
+	if err := json.Unmarshal(b, &schemas); err != nil {
+		return nil, err
+	}
+
+	for prefix, s := range schemas {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("%s is not a valid path prefix", prefix)
+		}
+		if s == nil || len(s.Properties) == 0 {
+			return nil, fmt.Errorf("schema of %s has no properties", prefix)
+		}
+	}
+
+	return schemas, nil
+}
+
+// bodySchemaFor returns the schema of the longest prefix of the path,
+// matching whole segments
+func bodySchemaFor(schemas map[string]*bodySchema, path string) *bodySchema {
+	var match string
+	for prefix := range schemas {
+		if len(prefix) > len(match) && hasPathPrefix(path, prefix) {
+			match = prefix
+		}
+	}
+	if len(match) == 0 {
+		return nil
+	}
+	return schemas[match]
+}
+
+// apply sets the defaults of the properties missing from the object,
+// descending into the objects it has. Objects missing are only added when
+// the schema has a default for them. It returns whether anything was set.
+func (s *bodySchema) apply(obj map[string]interface{}) bool {
+	var set bool
+	for name, p := range s.Properties {
+		if p == nil {
+			continue
+		}
+		v, ok := obj[name]
+		if !ok {
+			if len(p.Default) == 0 {
+				continue
+			}
+			var d interface{}
+			dec := json.NewDecoder(bytes.NewReader(p.Default))
+			dec.UseNumber()
+			if err := dec.Decode(&d); err != nil {
+				continue
+			}
+			obj[name] = d
+			set = true
+			continue
+		}
+		if o, ok := v.(map[string]interface{}); ok && len(p.Properties) > 0 {
+			if p.apply(o) {
+				set = true
+			}
+		}
+	}
+	return set
+}
+
+// bodyDefaultsHandler fills in the properties the schema of the route has
+// defaults for which the json body of the request leaves out, so backends
+// get complete requests. Bodies up to max bytes which are json objects are
+// rewritten, anything else is passed on as it is for the backend to reject.
+func bodyDefaultsHandler(schemas map[string]*bodySchema, max int64, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := bodySchemaFor(schemas, r.URL.Path)
+		if s == nil || r.Body == nil || r.Body == http.NoBody || r.ContentLength > max ||
+			!strings.Contains(r.Header.Get("Content-Type"), "json") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+		if err != nil {
+			writeError(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(b)) > max {
+			// stream the rest as it is
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+			h.ServeHTTP(w, r)
+			return
+		}
+		r.Body.Close()
+
+		var obj map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		// keep numbers as sent rather than rounding them through floats
+		dec.UseNumber()
+		if err := dec.Decode(&obj); err == nil && obj != nil && s.apply(obj) {
+			if nb, err := json.Marshal(obj); err == nil {
+				b = nb
+			}
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+		r.ContentLength = int64(len(b))
+		h.ServeHTTP(w, r)
+	})
+}
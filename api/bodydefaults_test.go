@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyDefaults(t *testing.T) {
+	var schemas map[string]*bodySchema
+	err := json.Unmarshal([]byte(`{
+		"/users": {"type": "object", "properties": {
+			"role": {"type": "string", "default": "member"},
+			"limit": {"type": "integer", "default": 50},
+			"name": {"type": "string"},
+			"settings": {"type": "object", "properties": {
+				"theme": {"type": "string", "default": "light"}
+			}}
+		}}
+	}`), &schemas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body string
+	var length int64
+	h := bodyDefaultsHandler(schemas, 1024, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body, length = string(b), r.ContentLength
+	}))
+
+	testData := []struct {
+		path   string
+		ctype  string
+		body   string
+		expect string
+	}{
+		{"/users", "application/json", `{"name":"john"}`, `{"limit":50,"name":"john","role":"member"}`},
+		// values sent are kept, even if null, the body left as it is
+		{"/users/create", "application/json", `{"role":"admin","limit":null}`, `{"role":"admin","limit":null}`},
+		// nested objects are filled in but not added
+		{"/users", "application/json", `{"role":"admin","limit":1,"settings":{}}`, `{"limit":1,"role":"admin","settings":{"theme":"light"}}`},
+		// large numbers aren't rounded
+		{"/users", "application/json", `{"id":12345678901234567890}`, `{"id":12345678901234567890,"limit":50,"role":"member"}`},
+		// anything but json objects passes as it is
+		{"/users", "application/json", `[1, 2]`, `[1, 2]`},
+		{"/users", "application/json", `{"name":`, `{"name":`},
+		{"/users", "text/plain", `{"name":"john"}`, `{"name":"john"}`},
+		{"/orders", "application/json", `{"name":"john"}`, `{"name":"john"}`},
+		// bodies larger than the max are streamed
+		{"/users", "application/json", `{"name":"` + strings.Repeat("a", 1024) + `"}`, `{"name":"` + strings.Repeat("a", 1024) + `"}`},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("POST", d.path, strings.NewReader(d.body))
+		r.Header.Set("Content-Type", d.ctype)
+		r.ContentLength = -1
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if body != d.expect {
+			t.Fatalf("Expected body %s for %s got %s", d.expect, d.body, body)
+		}
+		if length >= 0 && length != int64(len(body)) {
+			t.Fatalf("Expected content length %d got %d", len(body), length)
+		}
+	}
+}
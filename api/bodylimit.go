@@ -0,0 +1,127 @@
+package api
+
+import (
+	"io"
+	"net/http"
+)
+
+// tooLargeWriter replaces the response with a 413 when the handler failed
+// because the request body exceeded the max size
+type tooLargeWriter struct {
+	http.ResponseWriter
+	// message of the error returned
+	msg string
+	// whether the body exceeded the max size
+	tooLarge func() bool
+	wrote    bool
+	rejected bool
+}
+
+func (w *tooLargeWriter) WriteHeader(code int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+	if w.tooLarge() {
+		w.rejected = true
+		writeError(w.ResponseWriter, w.msg, http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *tooLargeWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *tooLargeWriter) Flush() {
+	if w.rejected {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// limitedBody is a request body limited by http.MaxBytesReader, noting
+// when reads failed as it exceeded the limit
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	// the error isn't typed before go 1.19
+	if err != nil && err.Error() == "http: request body too large" {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// bodyLimiter rejects request bodies larger than the max size with a 413
+// so clients can't exhaust the memory of the gateway or backends buffering
+// them. Bodies of unknown length are cut off once they exceed it. Paths can
+// have their own limit, e.g to allow uploads through the http handler.
+type bodyLimiter struct {
+	max int64
+	// max size by path prefix
+	paths map[string]int64
+}
+
+func newBodyLimiter(max int64, paths map[string]int64) *bodyLimiter {
+	return &bodyLimiter{
+		max:   max,
+		paths: paths,
+	}
+}
+
+// limit returns the max size of the longest prefix of the path, 0 if
+// unlimited
+func (l *bodyLimiter) limit(path string) int64 {
+	var match string
+	for prefix := range l.paths {
+		if len(prefix) > len(match) && hasPathPrefix(path, prefix) {
+			match = prefix
+		}
+	}
+	if len(match) > 0 {
+		return l.paths[match]
+	}
+	return l.max
+}
+
+func (l *bodyLimiter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		max := l.limit(r.URL.Path)
+		if max <= 0 || r.Body == nil || r.Body == http.NoBody {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > max {
+			writeError(w, "Request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		lb := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, max)}
+		r.Body = lb
+
+		tw := &tooLargeWriter{
+			ResponseWriter: w,
+			msg:            "Request entity too large",
+			tooLarge:       func() bool { return lb.exceeded },
+		}
+		h.ServeHTTP(tw, r)
+		// the handler may have stopped reading without responding
+		if lb.exceeded && !tw.wrote {
+			tw.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimiter(t *testing.T) {
+	h := newBodyLimiter(8, map[string]int64{"/upload": 16}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	testData := []struct {
+		path    string
+		body    string
+		unknown bool
+		code    int
+	}{
+		{"/greeter", "12345678", false, http.StatusOK},
+		{"/greeter", "123456789", false, http.StatusRequestEntityTooLarge},
+		// bodies of unknown length are cut off once over the limit
+		{"/greeter", "123456789", true, http.StatusRequestEntityTooLarge},
+		{"/greeter", "12345678", true, http.StatusOK},
+		{"/upload/file", "123456789", true, http.StatusOK},
+		{"/upload/file", strings.Repeat("1", 17), false, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("POST", d.path, strings.NewReader(d.body))
+		if d.unknown {
+			r.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %d bytes to %s got %d", d.code, len(d.body), d.path, w.Code)
+		}
+		if d.code == http.StatusRequestEntityTooLarge && !strings.Contains(w.Body.String(), "Request entity too large") {
+			t.Fatalf("Expected an error body got %s", w.Body.String())
+		}
+	}
+}
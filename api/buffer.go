@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// retryable determines whether a request may be retried. Write methods
+// are only retried when marked idempotent with an idempotency key.
+func retryable(r *http.Request) bool {
+	if idempotentMethods[r.Method] {
+		return true
+	}
+	return len(r.Header.Get("Idempotency-Key")) > 0 || len(r.Header.Get("X-Idempotency-Key")) > 0
+}
+
+// retryWriter holds back a response from a backend which couldn't be
+// reached or was unavailable so the request can be retried
+type retryWriter struct {
+	http.ResponseWriter
+	header http.Header
+	wrote  bool
+	// whether the request can be retried on failure
+	retry bool
+	// a failure held back to retry the request
+	failed bool
+}
+
+// Header returns the headers held back until the response is committed,
+// those of the response after so trailers set once the body is written
+// reach the client
+func (w *retryWriter) Header() http.Header {
+	if w.wrote && !w.failed {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *retryWriter) WriteHeader(code int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if w.retry {
+			w.failed = true
+			return
+		}
+	}
+
+	dst := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *retryWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	// drop the body of failures being retried
+	if w.failed {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *retryWriter) Flush() {
+	if w.failed || !w.wrote {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// bufferHandler buffers the bodies of retryable requests up to max bytes
+// so they can be sent again when the backend responds with a 502, 503 or
+// 504, retrying up to attempts times. Larger bodies are streamed and can't
+// be retried.
+//
+// Buffering trades memory for resilience, every in flight request may hold
+// up to max bytes in memory so size the limit against the expected
+// concurrency.
+//
+// The nodes each attempt is sent to are tracked by the tracker if set, so
+// retries go to other nodes than those which failed.
+func bufferHandler(max int64, attempts int, tracker *attemptTracker, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(attemptsHeader)
+		if r.Body == nil || r.Body == http.NoBody || r.ContentLength > max || !retryable(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// read one byte past the max to detect bodies of unknown length
+		// which are too large to buffer
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+		if err != nil {
+			writeError(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		if int64(len(b)) > max {
+			// stream the rest without retry
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		r.Body.Close()
+		if tracker != nil {
+			var done func()
+			r, done = tracker.start(r)
+			defer done()
+		}
+		r.ContentLength = int64(len(b))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+
+		for i := 0; ; i++ {
+			// every attempt reads the whole body
+			r.Body, _ = r.GetBody()
+
+			rw := &retryWriter{
+				ResponseWriter: w,
+				header:         make(http.Header),
+				retry:          i < attempts,
+			}
+			h.ServeHTTP(rw, r)
+			if !rw.failed {
+				// send the headers of an empty response
+				if !rw.wrote {
+					rw.WriteHeader(http.StatusOK)
+				}
+				return
+			}
+
+			log.Debugf("Retrying %s %s after the backend failed", r.Method, r.URL.Path)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBufferRetry(t *testing.T) {
+	body := `{"name": "john"}`
+
+	testData := []struct {
+		method   string
+		header   string
+		max      int64
+		failures int
+		code     int
+		attempts int
+	}{
+		// retried with the whole body until the backend succeeds
+		{"PUT", "", 1024, 1, http.StatusOK, 2},
+		{"POST", "123", 1024, 1, http.StatusOK, 2},
+		// the last failure is returned once out of attempts
+		{"PUT", "", 1024, 3, http.StatusServiceUnavailable, 3},
+		// writes without an idempotency key aren't retried
+		{"POST", "", 1024, 1, http.StatusServiceUnavailable, 1},
+		// bodies too large to buffer aren't retried
+		{"PUT", "", 4, 1, http.StatusServiceUnavailable, 1},
+	}
+
+	for _, d := range testData {
+		var received []string
+		h := bufferHandler(d.max, 2, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			received = append(received, string(b))
+			if len(received) <= d.failures {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"ok": true}`))
+		}))
+
+		r := httptest.NewRequest(d.method, "/foo", strings.NewReader(body))
+		if len(d.header) > 0 {
+			r.Header.Set("Idempotency-Key", d.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %s got %d", d.code, d.method, w.Code)
+		}
+		if len(received) != d.attempts {
+			t.Fatalf("Expected %d attempts for %s got %d", d.attempts, d.method, len(received))
+		}
+		for _, b := range received {
+			if b != body {
+				t.Fatalf("Expected every attempt to receive the whole body got %q", b)
+			}
+		}
+		// only the response of the last attempt is sent
+		if d.code == http.StatusOK && (w.Body.String() != `{"ok": true}` || w.Header().Get("Content-Type") != "application/json") {
+			t.Fatalf("Unexpected response %q %v", w.Body.String(), w.Header())
+		}
+		if d.code != http.StatusOK && strings.Count(w.Body.String(), "unavailable") != 1 {
+			t.Fatalf("Expected one failure in the response got %q", w.Body.String())
+		}
+	}
+}
+
+func TestBufferRetryTrailers(t *testing.T) {
+	var attempts int
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("body"))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	defer backend.Close()
+
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := httptest.NewServer(bufferHandler(1024, 2, nil, httputil.NewSingleHostReverseProxy(u)))
+	defer gateway.Close()
+
+	req, err := http.NewRequest("PUT", gateway.URL, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	ioutil.ReadAll(rsp.Body)
+
+	if attempts != 2 {
+		t.Fatalf("Expected the request to be retried got %d attempts", attempts)
+	}
+	// trailers are set once the body is written
+	if v := rsp.Trailer.Get("Grpc-Status"); v != "0" {
+		t.Fatalf("Expected the Grpc-Status trailer got %q", v)
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseLimits parses per service limits in the format service=limit
+func parseLimits(list []string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid limit, expected service=limit", s)
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s is not a valid limit", parts[1])
+		}
+		limits[parts[0]] = n
+	}
+	return limits, nil
+}
+
+// bulkhead bounds the requests in flight to each service so a slow service
+// can't tie up the gateway. Requests over the limit queue for a slot up to
+// the timeout before they're rejected. The time spent queueing and the
+// rejections are reported per service so the limits can be tuned.
+type bulkhead struct {
+	// default limit, unlimited if zero
+	limit int
+	// limits per service
+	limits map[string]int
+	// max time spent waiting for a slot
+	timeout time.Duration
+	// report the time waited and rejections
+	observe func(service string, wait time.Duration)
+	reject  func(service string)
+
+	sync.Mutex
+	// slots of the services with requests in flight, services are
+	// resolved from the path so idle ones are dropped
+	slots map[string]*slots
+}
+
+// slots is the semaphore bounding the requests to a service
+type slots struct {
+	sem chan struct{}
+	// requests holding or waiting for a slot
+	refs int
+}
+
+func newBulkhead(limit int, limits map[string]int, timeout time.Duration, observe func(string, time.Duration), reject func(string)) *bulkhead {
+	return &bulkhead{
+		limit:   limit,
+		limits:  limits,
+		timeout: timeout,
+		observe: observe,
+		reject:  reject,
+		slots:   make(map[string]*slots),
+	}
+}
+
+// get returns the slots of the service, nil if it's unlimited. The slots
+// must be returned with put.
+func (b *bulkhead) get(service string) *slots {
+	n, ok := b.limits[service]
+	if !ok {
+		n = b.limit
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	s, ok := b.slots[service]
+	if !ok {
+		s = &slots{sem: make(chan struct{}, n)}
+		b.slots[service] = s
+	}
+	s.refs++
+	return s
+}
+
+// put drops the slots of the service once no request uses them
+func (b *bulkhead) put(service string, s *slots) {
+	b.Lock()
+	defer b.Unlock()
+	s.refs--
+	if s.refs == 0 {
+		delete(b.slots, service)
+	}
+}
+
+// acquire waits for a slot returning whether one was taken
+func (b *bulkhead) acquire(r *http.Request, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+
+	t := time.NewTimer(b.timeout)
+	defer t.Stop()
+
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func (b *bulkhead) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := service(r)
+		if len(name) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		s := b.get(name)
+		if s == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		defer b.put(name, s)
+
+		start := time.Now()
+		ok := b.acquire(r, s.sem)
+		b.observe(name, time.Since(start))
+
+		if !ok {
+			b.reject(name)
+			w.Header().Set("Retry-After", "1")
+			writeError(w, "Service busy", http.StatusServiceUnavailable)
+			return
+		}
+		defer func() { <-s.sem }()
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+func TestBulkhead(t *testing.T) {
+	limits, err := parseLimits([]string{"go.micro.api.slow=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mtx sync.Mutex
+	var waits int
+	rejected := make(map[string]int)
+
+	b := newBulkhead(0, limits, time.Millisecond*20, func(service string, d time.Duration) {
+		mtx.Lock()
+		waits++
+		mtx.Unlock()
+	}, func(service string) {
+		mtx.Lock()
+		rejected[service]++
+		mtx.Unlock()
+	})
+
+	started := make(chan bool)
+	release := make(chan bool)
+	h := b.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			close(started)
+			<-release
+		}
+	}))
+
+	request := func(service, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		ep := &resolver.Endpoint{Name: service}
+		r = r.WithContext(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// hold the only slot of the slow service
+	done := make(chan bool)
+	go func() {
+		request("go.micro.api.slow", "/block")
+		close(done)
+	}()
+	<-started
+
+	if w := request("go.micro.api.slow", "/foo"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected queued request to be rejected got %d", w.Code)
+	}
+	if w := request("go.micro.api.other", "/foo"); w.Code != http.StatusOK {
+		t.Fatalf("Expected unlimited service to be served got %d", w.Code)
+	}
+
+	close(release)
+	<-done
+
+	if w := request("go.micro.api.slow", "/foo"); w.Code != http.StatusOK {
+		t.Fatalf("Expected request to be served once the slot is free got %d", w.Code)
+	}
+
+	// no slots are kept for idle services
+	b.Lock()
+	if len(b.slots) > 0 {
+		t.Fatalf("Expected idle slots to be dropped got %d", len(b.slots))
+	}
+	b.Unlock()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if rejected["go.micro.api.slow"] != 1 {
+		t.Fatalf("Expected 1 rejection got %v", rejected)
+	}
+	if waits != 3 {
+		t.Fatalf("Expected 3 observed waits got %d", waits)
+	}
+}
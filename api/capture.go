@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/micro/cli/v2"
+	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/store"
+)
+
+var (
+	// prefix of captured request keys in the store
+	capturePrefix = "api/capture/"
+	// how long captured requests are kept
+	captureExpiry = time.Hour * 24
+	// value replacing redacted data
+	redacted = "REDACTED"
+	// always redacted when capturing
+	defaultRedact = []string{"Authorization", "Cookie", "X-Api-Key"}
+)
+
+// capturedRequest is a request captured for later replay
+type capturedRequest struct {
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Header    http.Header `json:"header"`
+	Body      []byte      `json:"body"`
+	Timestamp int64       `json:"timestamp"`
+	// the body was larger than the max captured and isn't kept
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// capturer writes a sample of requests to the store so they
+// can be replayed later e.g to reproduce production issues
+type capturer struct {
+	store store.Store
+	// fraction of requests captured
+	ratio float64
+	// only capture requests with these path prefixes
+	paths []string
+	// max body size captured
+	maxBody int64
+	// headers, query params and json fields to redact
+	redact map[string]bool
+}
+
+func newCapturer(st store.Store, ratio float64, paths, redact []string, maxBody int64) *capturer {
+	c := &capturer{
+		store:   st,
+		ratio:   ratio,
+		paths:   paths,
+		maxBody: maxBody,
+		redact:  make(map[string]bool),
+	}
+	for _, k := range append(defaultRedact, redact...) {
+		c.redact[strings.ToLower(k)] = true
+	}
+	return c
+}
+
+// sample determines whether the request should be captured
+func (c *capturer) sample(r *http.Request) bool {
+	if len(c.paths) > 0 {
+		var match bool
+		for _, p := range c.paths {
+			if strings.HasPrefix(r.URL.Path, p) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+	return rand.Float64() < c.ratio
+}
+
+// redactRequest strips sensitive data from a captured request
+func (c *capturer) redactRequest(cr *capturedRequest, u string) {
+	for k := range cr.Header {
+		if c.redact[strings.ToLower(k)] {
+			cr.Header.Set(k, redacted)
+		}
+	}
+
+	if i := strings.Index(u, "?"); i >= 0 {
+		q, err := url.ParseQuery(u[i+1:])
+		if err == nil {
+			for k := range q {
+				if c.redact[strings.ToLower(k)] {
+					q.Set(k, redacted)
+				}
+			}
+			u = u[:i] + "?" + q.Encode()
+		}
+	}
+	cr.URL = u
+
+	// redact json fields at any depth
+	var body interface{}
+	if err := json.Unmarshal(cr.Body, &body); err != nil {
+		return
+	}
+	if !c.redactValue(body) {
+		return
+	}
+	if b, err := json.Marshal(body); err == nil {
+		cr.Body = b
+	}
+}
+
+// redactValue redacts the fields of json objects nested in the value,
+// reporting whether anything was redacted
+func (c *capturer) redactValue(v interface{}) bool {
+	var changed bool
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, f := range v {
+			if c.redact[strings.ToLower(k)] {
+				v[k] = redacted
+				changed = true
+			} else if c.redactValue(f) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, f := range v {
+			if c.redactValue(f) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func (c *capturer) capture(r *http.Request) {
+	var body []byte
+	var truncated bool
+	if r.Body != nil {
+		// read a byte past the max to know whether the body is truncated
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, c.maxBody+1))
+		if err != nil {
+			log.Debugf("Failed to capture request body: %v", err)
+		}
+		// put back what we read for the handler
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+		// part of a body can't be redacted or replayed so it isn't kept
+		if int64(len(b)) > c.maxBody {
+			truncated = true
+		} else {
+			body = b
+		}
+	}
+
+	cr := &capturedRequest{
+		Method:    r.Method,
+		Header:    r.Header.Clone(),
+		Body:      append([]byte(nil), body...),
+		Timestamp: time.Now().UnixNano(),
+		Truncated: truncated,
+	}
+	c.redactRequest(cr, r.URL.RequestURI())
+
+	go func() {
+		b, err := json.Marshal(cr)
+		if err != nil {
+			log.Debugf("Failed to marshal captured request: %v", err)
+			return
+		}
+		if err := c.store.Write(&store.Record{
+			Key:    fmt.Sprintf("%s%020d-%d", capturePrefix, cr.Timestamp, rand.Int63()),
+			Value:  b,
+			Expiry: captureExpiry,
+		}); err != nil {
+			log.Debugf("Failed to write captured request: %v", err)
+		}
+	}()
+}
+
+func (c *capturer) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.sample(r) {
+			c.capture(r)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// readCloser reads from one reader and closes another
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// replay sends the captured requests to the target in the order they were captured
+func replay(ctx *cli.Context) error {
+	target := strings.TrimSuffix(ctx.String("target"), "/")
+	if len(target) == 0 {
+		return fmt.Errorf("target is required e.g http://localhost:8080")
+	}
+
+	records, err := store.DefaultStore.Read(capturePrefix, store.ReadPrefix())
+	if err != nil {
+		return err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+
+	replayRecords(target, records)
+	return nil
+}
+
+// replayRecords sends the captured requests in the records to the target
+func replayRecords(target string, records []*store.Record) {
+	for _, rec := range records {
+		var cr capturedRequest
+		if err := json.Unmarshal(rec.Value, &cr); err != nil {
+			fmt.Printf("%s: %v\n", rec.Key, err)
+			continue
+		}
+
+		// the body of the request wasn't captured in full
+		if cr.Truncated {
+			fmt.Printf("%s %s: skipped, the body was truncated\n", cr.Method, cr.URL)
+			continue
+		}
+
+		req, err := http.NewRequest(cr.Method, target+cr.URL, bytes.NewReader(cr.Body))
+		if err != nil {
+			fmt.Printf("%s: %v\n", rec.Key, err)
+			continue
+		}
+		req.Header = cr.Header
+		// don't send redacted credentials to the target
+		for k, v := range req.Header {
+			if len(v) == 1 && v[0] == redacted {
+				req.Header.Del(k)
+			}
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			fmt.Printf("%s %s: %v\n", cr.Method, cr.URL, err)
+			continue
+		}
+		io.Copy(ioutil.Discard, rsp.Body)
+		rsp.Body.Close()
+
+		fmt.Printf("%s %s: %s\n", cr.Method, cr.URL, rsp.Status)
+	}
+}
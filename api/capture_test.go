@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/micro/go-micro/v2/store"
+)
+
+// testStore keeps the records written to it
+type testStore struct {
+	store.Store
+
+	sync.Mutex
+	records []*store.Record
+	written chan struct{}
+}
+
+func (s *testStore) Write(r *store.Record, opts ...store.WriteOption) error {
+	s.Lock()
+	s.records = append(s.records, r)
+	s.Unlock()
+	s.written <- struct{}{}
+	return nil
+}
+
+func TestCaptureRedact(t *testing.T) {
+	c := newCapturer(nil, 1, nil, []string{"password", "token"}, 1024)
+
+	cr := &capturedRequest{
+		Header: http.Header{"Authorization": {"Bearer secret"}, "Accept": {"*/*"}},
+		Body:   []byte(`{"user": {"name": "bob", "password": "secret"}, "sessions": [{"token": "secret"}, {"id": 1}]}`),
+	}
+	c.redactRequest(cr, "/foo?token=secret&page=1")
+
+	if cr.Header.Get("Authorization") != redacted || cr.Header.Get("Accept") != "*/*" {
+		t.Fatalf("Unexpected headers %v", cr.Header)
+	}
+	if strings.Contains(cr.URL, "secret") || !strings.Contains(cr.URL, "page=1") {
+		t.Fatalf("Unexpected url %s", cr.URL)
+	}
+	if strings.Contains(string(cr.Body), "secret") {
+		t.Fatalf("Expected nested fields to be redacted got %s", cr.Body)
+	}
+
+	var body struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+		Sessions []map[string]interface{} `json:"sessions"`
+	}
+	if err := json.Unmarshal(cr.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.User.Name != "bob" || len(body.Sessions) != 2 || body.Sessions[1]["id"] != float64(1) {
+		t.Fatalf("Expected other fields to be kept got %s", cr.Body)
+	}
+}
+
+func TestCaptureTruncated(t *testing.T) {
+	st := &testStore{written: make(chan struct{}, 2)}
+	c := newCapturer(st, 1, nil, nil, 8)
+
+	var received []string
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(b))
+	}))
+
+	for _, body := range []string{`{"a": 1}`, `{"a": 1, "b": 2}`} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/foo", strings.NewReader(body)))
+		<-st.written
+	}
+
+	// the handler always gets the whole body
+	if received[0] != `{"a": 1}` || received[1] != `{"a": 1, "b": 2}` {
+		t.Fatalf("Unexpected bodies received %q", received)
+	}
+
+	var captured []capturedRequest
+	for _, rec := range st.records {
+		var cr capturedRequest
+		if err := json.Unmarshal(rec.Value, &cr); err != nil {
+			t.Fatal(err)
+		}
+		captured = append(captured, cr)
+	}
+	if captured[0].Truncated || string(captured[0].Body) != `{"a": 1}` {
+		t.Fatalf("Expected body within the max to be captured got %+v", captured[0])
+	}
+	if !captured[1].Truncated || len(captured[1].Body) > 0 {
+		t.Fatalf("Expected body over the max to be marked truncated got %+v", captured[1])
+	}
+
+	// truncated requests aren't replayed
+	var replayed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		replayed = append(replayed, string(b))
+	}))
+	defer srv.Close()
+
+	replayRecords(srv.URL, st.records)
+
+	if len(replayed) != 1 || replayed[0] != `{"a": 1}` {
+		t.Fatalf("Expected only the complete request to be replayed got %q", replayed)
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// chaos injects latency and errors into a fraction of requests to
+// test how clients cope with timeouts and failures
+type chaos struct {
+	// fraction of requests delayed
+	latencyRatio float64
+	// base latency injected
+	latency time.Duration
+	// random latency added on top of the base
+	jitter time.Duration
+	// fraction of requests failed
+	errorRatio float64
+	// status code of injected errors
+	errorCode int
+	// only inject faults into requests with these path prefixes
+	paths []string
+	// called for every fault injected e.g to record stats
+	record func(fault string)
+
+	// number of faults injected
+	delayed uint64
+	failed  uint64
+}
+
+// validate returns an error if the faults can't be injected
+func (c *chaos) validate() error {
+	if c.errorCode < 100 || c.errorCode > 599 {
+		return fmt.Errorf("%d is not a valid chaos error code between 100 and 599", c.errorCode)
+	}
+	for _, ratio := range []float64{c.latencyRatio, c.errorRatio} {
+		if ratio < 0 || ratio > 1 {
+			return fmt.Errorf("%v is not a valid chaos ratio between 0 and 1", ratio)
+		}
+	}
+	return nil
+}
+
+// target determines whether faults may be injected into the request
+func (c *chaos) target(r *http.Request) bool {
+	if len(c.paths) == 0 {
+		return true
+	}
+	for _, p := range c.paths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the latency to inject
+func (c *chaos) delay() time.Duration {
+	d := c.latency
+	if c.jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(c.jitter)))
+	}
+	return d
+}
+
+func (c *chaos) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.target(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if c.latencyRatio > 0 && rand.Float64() < c.latencyRatio {
+			atomic.AddUint64(&c.delayed, 1)
+			c.record("chaos_latency")
+
+			select {
+			case <-time.After(c.delay()):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if c.errorRatio > 0 && rand.Float64() < c.errorRatio {
+			atomic.AddUint64(&c.failed, 1)
+			c.record("chaos_error")
+			writeError(w, "Injected fault", c.errorCode)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChaos(t *testing.T) {
+	testData := []struct {
+		code  int
+		ratio float64
+		valid bool
+	}{
+		{http.StatusServiceUnavailable, 0.5, true},
+		{http.StatusTeapot, 1, true},
+		{0, 0.5, false},
+		{99, 0.5, false},
+		{600, 0.5, false},
+		{http.StatusServiceUnavailable, 1.5, false},
+		{http.StatusServiceUnavailable, -1, false},
+	}
+
+	for _, d := range testData {
+		c := &chaos{errorCode: d.code, errorRatio: d.ratio}
+		if err := c.validate(); (err == nil) != d.valid {
+			t.Fatalf("Expected code %d ratio %v valid %v got %v", d.code, d.ratio, d.valid, err)
+		}
+	}
+
+	var faults []string
+	c := &chaos{
+		errorRatio: 1,
+		errorCode:  http.StatusTeapot,
+		paths:      []string{"/flaky"},
+		record:     func(fault string) { faults = append(faults, fault) },
+	}
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for path, code := range map[string]int{"/flaky/foo": http.StatusTeapot, "/stable": http.StatusOK} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != code {
+			t.Fatalf("Expected status %d for %s got %d", code, path, w.Code)
+		}
+	}
+	if len(faults) != 1 || faults[0] != "chaos_error" {
+		t.Fatalf("Expected one injected error got %v", faults)
+	}
+}
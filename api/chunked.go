@@ -0,0 +1,159 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseChunkedPolicies parses policies in the format prefix=policy where
+// the policy is buffer or chunked
+func parseChunkedPolicies(list []string) (map[string]string, error) {
+	policies := make(map[string]string)
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") {
+			return nil, fmt.Errorf("%s is not a valid chunked policy, expected prefix=policy", s)
+		}
+		switch parts[1] {
+		case "buffer", "chunked":
+		default:
+			return nil, fmt.Errorf("%s is not a valid chunked policy", parts[1])
+		}
+		policies[parts[0]] = parts[1]
+	}
+	return policies, nil
+}
+
+// bufferedWriter holds back the response to send it with a content length
+// rather than chunked. Responses growing over max are sent chunked from
+// then on.
+type bufferedWriter struct {
+	http.ResponseWriter
+	max int
+
+	code int
+	buf  []byte
+	// passing writes through, the response being over max
+	through bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.through {
+		return w.ResponseWriter.Write(b)
+	}
+	if len(w.buf)+len(b) <= w.max {
+		w.buf = append(w.buf, b...)
+		return len(b), nil
+	}
+
+	w.through = true
+	w.ResponseWriter.WriteHeader(w.code)
+	if _, err := w.ResponseWriter.Write(w.buf); err != nil {
+		return 0, err
+	}
+	w.buf = nil
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush is a no-op while buffering as flushing would send the response
+// chunked
+func (w *bufferedWriter) Flush() {
+	if !w.through {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close sends the buffered response with its content length
+func (w *bufferedWriter) close() {
+	if w.through {
+		return
+	}
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	switch w.code {
+	case http.StatusNoContent, http.StatusNotModified:
+	default:
+		if len(w.Header().Get("Content-Length")) == 0 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+	w.ResponseWriter.Write(w.buf)
+}
+
+// streamingWriter sends the response chunked, flushing every write so it
+// streams to the client as the backend produces it
+type streamingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *streamingWriter) WriteHeader(code int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *streamingWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.Flush()
+	return n, err
+}
+
+func (w *streamingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// chunkedHandler applies the transfer encoding policy of the longest
+// prefix of the path. With buffer responses are sent with a content length
+// for clients mishandling chunked encoding, up to max bytes, while with
+// chunked they're streamed without one.
+func chunkedHandler(policies map[string]string, max int, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var match string
+		for prefix := range policies {
+			if len(prefix) > len(match) && hasPathPrefix(r.URL.Path, prefix) {
+				match = prefix
+			}
+		}
+
+		// upgraded connections have no transfer encoding to apply
+		if upgrading(r) {
+			match = ""
+		}
+
+		switch policies[match] {
+		case "buffer":
+			bw := &bufferedWriter{ResponseWriter: w, max: max}
+			h.ServeHTTP(bw, r)
+			bw.close()
+		case "chunked":
+			h.ServeHTTP(&streamingWriter{ResponseWriter: w}, r)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
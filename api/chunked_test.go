@@ -0,0 +1,65 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChunkedHandler(t *testing.T) {
+	policies, err := parseChunkedPolicies([]string{"/downloads=buffer", "/events=chunked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(chunkedHandler(policies, 16, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := 8
+		if strings.HasSuffix(r.URL.Path, "/large") {
+			size = 32
+		}
+		if strings.HasSuffix(r.URL.Path, "/length") {
+			w.Header().Set("Content-Length", "8")
+		}
+		// flushing sends the response chunked unless buffered
+		for i := 0; i < size/4; i++ {
+			w.Write([]byte("abcd"))
+			w.(http.Flusher).Flush()
+		}
+	})))
+	defer srv.Close()
+
+	testData := []struct {
+		path    string
+		length  int64
+		chunked bool
+	}{
+		{"/downloads/file", 8, false},
+		// responses over the max are sent chunked
+		{"/downloads/large", -1, true},
+		{"/events/length", -1, true},
+		{"/other", -1, true},
+	}
+
+	for _, d := range testData {
+		rsp, err := http.Get(srv.URL + d.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		chunked := len(rsp.TransferEncoding) > 0 && rsp.TransferEncoding[0] == "chunked"
+		if rsp.ContentLength != d.length || chunked != d.chunked {
+			t.Fatalf("Expected length %d and chunked %v for %s got %d and %v", d.length, d.chunked, d.path, rsp.ContentLength, chunked)
+		}
+		if len(b) == 0 || len(b)%4 != 0 {
+			t.Fatalf("Expected the whole body for %s got %q", d.path, b)
+		}
+	}
+
+	if _, err := parseChunkedPolicies([]string{"/downloads=gzip"}); err == nil {
+		t.Fatal("Expected an invalid policy to be rejected")
+	}
+}
@@ -0,0 +1,23 @@
+package api
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestParseCipherSuites(t *testing.T) {
+	suites, err := parseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suites) != 2 || suites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || suites[1] != tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305 {
+		t.Fatalf("Unexpected cipher suites %v", suites)
+	}
+
+	// tls 1.3 suites can't be configured
+	for _, n := range []string{"TLS_AES_128_GCM_SHA256", "rsa"} {
+		if _, err := parseCipherSuites([]string{n}); err == nil {
+			t.Fatalf("Expected an error parsing %s", n)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// loadClientCAs loads the pem certificates client certificates are verified
+// against
+func loadClientCAs(file string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// clientCertHandler passes the common name of the client certificate
+// verified during the tls handshake in the header, for the auth wrapper and
+// backends. Values sent by the client are dropped.
+func clientCertHandler(header string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(header)
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			if cn := r.TLS.VerifiedChains[0][0].Subject.CommonName; len(cn) > 0 {
+				r.Header.Set(header, cn)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCertHandler(t *testing.T) {
+	ca, caKey := testCert(t, 1, nil, nil)
+	client, clientKey := testCert(t, 2, ca, caKey)
+	other, otherKey := testCert(t, 3, nil, nil)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	srv := httptest.NewUnstartedServer(clientCertHandler("X-Micro-Client-Cert-CN", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Micro-Client-Cert-CN")))
+	})))
+	srv.TLS = &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	get := func(cert *x509.Certificate, key *ecdsa.PrivateKey) (string, error) {
+		// a new transport so connections aren't reused
+		tr := srv.Client().Transport.(*http.Transport).Clone()
+		if cert != nil {
+			tr.TLSClientConfig.Certificates = []tls.Certificate{{
+				Certificate: [][]byte{cert.Raw},
+				PrivateKey:  key,
+			}}
+		}
+		defer tr.CloseIdleConnections()
+		r, _ := http.NewRequest("GET", srv.URL, nil)
+		r.Header.Set("X-Micro-Client-Cert-CN", "spoofed")
+		rsp, err := (&http.Client{Transport: tr}).Do(r)
+		if err != nil {
+			return "", err
+		}
+		defer rsp.Body.Close()
+		b, err := ioutil.ReadAll(rsp.Body)
+		return string(b), err
+	}
+
+	if cn, err := get(client, clientKey); err != nil || cn != "test" {
+		t.Fatalf("Expected the common name of the verified certificate got %q %v", cn, err)
+	}
+	// the header is only set from a verified certificate
+	if cn, err := get(nil, nil); err != nil || len(cn) > 0 {
+		t.Fatalf("Expected no common name without a certificate got %q %v", cn, err)
+	}
+	// certificates which don't verify fail the handshake
+	if _, err := get(other, otherKey); err == nil {
+		t.Fatal("Expected the handshake with an unknown certificate to fail")
+	}
+}
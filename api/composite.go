@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/metadata"
+)
+
+// key of the per call errors in composite responses
+var compositeErrorsKey = "errors"
+
+// compositeCall is a backend call whose response is merged under its key
+type compositeCall struct {
+	Service  string `json:"service"`
+	Endpoint string `json:"endpoint"`
+}
+
+// loadComposites reads the composite routes from a json file mapping paths
+// to response keys and the calls made for them e.g
+//
+//	{"/dashboard": {"user": {"service": "go.micro.srv.user", "endpoint": "User.Read"}}}
+func loadComposites(file string) (map[string]map[string]compositeCall, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes map[string]map[string]compositeCall
+	if err := json.Unmarshal(b, &routes); err != nil {
+		return nil, err
+	}
+
+	for path, calls := range routes {
+		for key, call := range calls {
+			if key == compositeErrorsKey {
+				return nil, fmt.Errorf("%s uses reserved key %s", path, key)
+			}
+			if len(call.Service) == 0 || len(call.Endpoint) == 0 {
+				return nil, fmt.Errorf("%s call %s requires a service and endpoint", path, key)
+			}
+		}
+	}
+
+	return routes, nil
+}
+
+// compositeEndpoints returns the endpoints of the calls ordered by key
+func compositeEndpoints(calls map[string]compositeCall) []*resolver.Endpoint {
+	keys := make([]string, 0, len(calls))
+	for key := range calls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	endpoints := make([]*resolver.Endpoint, len(keys))
+	for i, key := range keys {
+		endpoints[i] = &resolver.Endpoint{Name: calls[key].Service, Method: calls[key].Endpoint}
+	}
+	return endpoints
+}
+
+// composite fans a request out to multiple backends concurrently and
+// merges their json responses into one object keyed by call. Calls which
+// fail are reported under the errors key so partial results are returned.
+type composite struct {
+	client client.Client
+	calls  map[string]compositeCall
+}
+
+func newComposite(c client.Client, calls map[string]compositeCall) *composite {
+	return &composite{
+		client: c,
+		calls:  calls,
+	}
+}
+
+// payload returns the request sent to every backend, the body or
+// for requests without one the query params
+func payload(r *http.Request) (json.RawMessage, error) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 0 {
+			return json.RawMessage(b), nil
+		}
+	}
+
+	vals := make(map[string]string)
+	for k, v := range r.URL.Query() {
+		vals[k] = strings.Join(v, ",")
+	}
+	return json.Marshal(vals)
+}
+
+func (c *composite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request, err := payload(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := requestContext(r)
+
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	rsp := make(map[string]json.RawMessage)
+	errs := make(map[string]*errors.Error)
+
+	for key, call := range c.calls {
+		wg.Add(1)
+		go func(key string, call compositeCall) {
+			defer wg.Done()
+
+			res, err := callJSON(ctx, c.client, call.Service, call.Endpoint, request)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs[key] = errors.Parse(err.Error())
+				return
+			}
+			rsp[key] = res
+		}(key, call)
+	}
+
+	wg.Wait()
+
+	code := http.StatusOK
+	if len(errs) > 0 {
+		b, _ := json.Marshal(errs)
+		rsp[compositeErrorsKey] = b
+		// nothing to merge
+		if len(errs) == len(c.calls) {
+			code = http.StatusBadGateway
+		}
+	}
+
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// requestContext passes the request headers on as metadata as the other
+// handlers do
+func requestContext(r *http.Request) context.Context {
+	md := make(metadata.Metadata)
+	for k, v := range r.Header {
+		md[k] = strings.Join(v, ",")
+	}
+	return metadata.NewContext(r.Context(), md)
+}
+
+// callJSON makes a backend call with a json request returning the raw
+// json response
+func callJSON(ctx context.Context, c client.Client, service, endpoint string, request json.RawMessage) (json.RawMessage, error) {
+	req := c.NewRequest(
+		service,
+		endpoint,
+		&request,
+		client.WithContentType("application/json"),
+	)
+
+	var response json.RawMessage
+	if err := c.Call(ctx, req, &response); err != nil {
+		return nil, err
+	}
+	if len(response) == 0 {
+		response = json.RawMessage("{}")
+	}
+	return response, nil
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+)
+
+type testRequest struct {
+	client.Request
+	service  string
+	endpoint string
+}
+
+func (r *testRequest) Service() string  { return r.service }
+func (r *testRequest) Endpoint() string { return r.endpoint }
+
+// testClient responds with the endpoint name or fails for unknown services
+type testClient struct {
+	client.Client
+}
+
+func (c *testClient) NewRequest(service, endpoint string, req interface{}, opts ...client.RequestOption) client.Request {
+	return &testRequest{service: service, endpoint: endpoint}
+}
+
+func (c *testClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	if req.Service() == "go.micro.srv.missing" {
+		return &errors.Error{Id: "go.micro.client", Code: 500, Detail: "not found"}
+	}
+	*rsp.(*json.RawMessage) = json.RawMessage(`{"endpoint":"` + req.Endpoint() + `"}`)
+	return nil
+}
+
+func TestComposite(t *testing.T) {
+	testData := []struct {
+		calls map[string]compositeCall
+		code  int
+		keys  []string
+	}{
+		{
+			map[string]compositeCall{
+				"user":   {Service: "go.micro.srv.user", Endpoint: "User.Read"},
+				"orders": {Service: "go.micro.srv.orders", Endpoint: "Orders.List"},
+			},
+			http.StatusOK,
+			[]string{"user", "orders"},
+		},
+		{
+			map[string]compositeCall{
+				"user":   {Service: "go.micro.srv.user", Endpoint: "User.Read"},
+				"orders": {Service: "go.micro.srv.missing", Endpoint: "Orders.List"},
+			},
+			http.StatusOK,
+			[]string{"user", "errors"},
+		},
+		{
+			map[string]compositeCall{
+				"orders": {Service: "go.micro.srv.missing", Endpoint: "Orders.List"},
+			},
+			http.StatusBadGateway,
+			[]string{"errors"},
+		},
+	}
+
+	for _, d := range testData {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/dashboard", strings.NewReader(`{"id": "1"}`))
+		newComposite(new(testClient), d.calls).ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d got %d", d.code, w.Code)
+		}
+
+		var rsp map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		if len(rsp) != len(d.keys) {
+			t.Fatalf("Expected keys %v got %s", d.keys, w.Body.String())
+		}
+		for _, k := range d.keys {
+			if _, ok := rsp[k]; !ok {
+				t.Fatalf("Expected key %s in %s", k, w.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressWriter compresses the response unless the content type is
+// skipped. Responses are held back until they reach the min size so small
+// ones which grow when compressed are sent as is.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding  string
+	level     int
+	minSize   int
+	skipTypes []string
+
+	w    io.WriteCloser
+	code int
+	// body held back until the min size is reached
+	buf     []byte
+	decided bool
+}
+
+// skip determines whether the response is never compressed
+func (w *compressWriter) skip() bool {
+	hdr := w.Header()
+	if len(hdr.Get("Content-Encoding")) > 0 {
+		return true
+	}
+
+	// partial content is a range of the encoded body
+	switch w.code {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return true
+	}
+
+	ct := hdr.Get("Content-Type")
+	for _, t := range w.skipTypes {
+		if strings.HasPrefix(ct, t) {
+			return true
+		}
+	}
+
+	if cl, err := strconv.Atoi(hdr.Get("Content-Length")); err == nil && cl < w.minSize {
+		return true
+	}
+	return false
+}
+
+// decide writes the header, compressing the body from now on if asked to
+func (w *compressWriter) decide(compress bool) {
+	w.decided = true
+
+	if compress {
+		switch w.encoding {
+		case "gzip":
+			if gw, err := gzip.NewWriterLevel(w.ResponseWriter, w.level); err == nil {
+				w.w = gw
+			}
+		case "deflate":
+			if fw, err := flate.NewWriter(w.ResponseWriter, w.level); err == nil {
+				w.w = fw
+			}
+		}
+	}
+
+	if w.w != nil {
+		hdr := w.Header()
+		hdr.Set("Content-Encoding", w.encoding)
+		hdr.Del("Content-Length")
+		// ranges and strong validators apply to the uncompressed body
+		hdr.Del("Accept-Ranges")
+		if etag := hdr.Get("ETag"); len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+			hdr.Set("ETag", "W/"+etag)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		w.write(buf)
+	}
+}
+
+func (w *compressWriter) write(b []byte) (int, error) {
+	if w.w != nil {
+		return w.w.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.code != 0 {
+		return
+	}
+	// informational responses precede the final one e.g 100 continue
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.code = code
+	if w.skip() {
+		w.decide(false)
+	} else if w.minSize <= 0 {
+		w.decide(true)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		// sniff the content type before it's hidden by compression
+		if len(w.Header().Get("Content-Type")) == 0 {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		return w.write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		w.decide(true)
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) Flush() {
+	// a response streamed before reaching the min size is compressed
+	if w.code != 0 && !w.decided {
+		w.decide(true)
+	}
+
+	type flusher interface {
+		Flush() error
+	}
+	if f, ok := w.w.(flusher); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// Close sends any response held back and flushes any compressed data
+func (w *compressWriter) Close() error {
+	if w.code != 0 && !w.decided {
+		w.decide(false)
+	}
+	if w.w != nil {
+		return w.w.Close()
+	}
+	return nil
+}
+
+// compressor compresses responses for clients which accept it, skipping
+// routes and content types which gain nothing from it e.g images
+type compressor struct {
+	level int
+	// size responses are compressed from
+	minSize int
+	// path prefixes not compressed
+	skipPaths []string
+	// content type prefixes not compressed
+	skipTypes []string
+}
+
+// validLevel returns an error if the level isn't a valid compression level
+func validLevel(level int) error {
+	_, err := gzip.NewWriterLevel(ioutil.Discard, level)
+	return err
+}
+
+func newCompressor(level, minSize int, skipPaths, skipTypes []string) *compressor {
+	return &compressor{
+		level:     level,
+		minSize:   minSize,
+		skipPaths: skipPaths,
+		skipTypes: skipTypes,
+	}
+}
+
+// encoding returns the encoding to use based on the Accept-Encoding header
+func encoding(r *http.Request) string {
+	var deflate bool
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(strings.TrimSpace(v), ";")
+		if len(parts) > 1 && strings.Replace(parts[1], " ", "", -1) == "q=0" {
+			continue
+		}
+		switch strings.ToLower(parts[0]) {
+		case "gzip":
+			return "gzip"
+		case "deflate":
+			deflate = true
+		}
+	}
+	if deflate {
+		return "deflate"
+	}
+	return ""
+}
+
+func (c *compressor) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the response differs with the encoding accepted
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		enc := encoding(r)
+		if len(enc) == 0 || r.Method == "HEAD" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		for _, p := range c.skipPaths {
+			if strings.HasPrefix(r.URL.Path, p) {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       enc,
+			level:          c.level,
+			minSize:        c.minSize,
+			skipTypes:      c.skipTypes,
+		}
+		defer cw.Close()
+
+		h.ServeHTTP(cw, r)
+	})
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressor(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image" {
+			w.Header().Set("Content-Type", "image/png")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.Write([]byte(`{"hello": "world"}`))
+	})
+
+	c := newCompressor(gzip.DefaultCompression, 0, []string{"/files"}, []string{"image/"})
+
+	testData := []struct {
+		path     string
+		encoding string
+	}{
+		{"/foo", "gzip"},
+		{"/files/foo", ""},
+		{"/image", ""},
+	}
+
+	for _, d := range testData {
+		req := httptest.NewRequest("GET", d.path, nil)
+		req.Header.Set("Accept-Encoding", "deflate, gzip;q=1.0")
+		w := httptest.NewRecorder()
+		c.Handler(h).ServeHTTP(w, req)
+
+		if enc := w.Header().Get("Content-Encoding"); enc != d.encoding {
+			t.Fatalf("Expected encoding %q for %s got %q", d.encoding, d.path, enc)
+		}
+		if len(d.encoding) == 0 {
+			continue
+		}
+
+		gr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != `{"hello": "world"}` {
+			t.Fatalf("Unexpected body %s", string(b))
+		}
+	}
+}
+
+func TestCompressorMinSize(t *testing.T) {
+	small := `{"hello": "world"}`
+	large := strings.Repeat(small, 100)
+
+	testData := []struct {
+		path     string
+		code     int
+		body     []string
+		encoding string
+	}{
+		// responses below the min size are sent as is
+		{"/small", 200, []string{small}, ""},
+		// the min size is reached over several writes
+		{"/large", 200, []string{large[:100], large[100:]}, "gzip"},
+		// ranges of the body aren't compressed
+		{"/partial", 206, []string{large}, ""},
+	}
+
+	for _, d := range testData {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(d.code)
+			for _, b := range d.body {
+				w.Write([]byte(b))
+			}
+		})
+
+		req := httptest.NewRequest("GET", d.path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		newCompressor(gzip.DefaultCompression, 1024, nil, nil).Handler(h).ServeHTTP(w, req)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %s got %d", d.code, d.path, w.Code)
+		}
+		if enc := w.Header().Get("Content-Encoding"); enc != d.encoding {
+			t.Fatalf("Expected encoding %q for %s got %q", d.encoding, d.path, enc)
+		}
+
+		body := w.Body.String()
+		if len(d.encoding) > 0 {
+			gr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ioutil.ReadAll(gr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = string(b)
+		}
+		if body != strings.Join(d.body, "") {
+			t.Fatalf("Unexpected body for %s", d.path)
+		}
+	}
+
+	if err := validLevel(10); err == nil {
+		t.Fatal("Expected compression level 10 to be invalid")
+	}
+	if err := validLevel(-1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompressorHeaders(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the backend continues before responding
+		w.WriteHeader(http.StatusContinue)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"hello": "world"}`))
+	})
+
+	srv := httptest.NewServer(newCompressor(gzip.DefaultCompression, 0, nil, nil).Handler(h))
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a compressed 200 got %d %v", rsp.StatusCode, rsp.Header)
+	}
+	if etag := rsp.Header.Get("ETag"); etag != `W/"v1"` {
+		t.Fatalf("Expected a weak etag got %q", etag)
+	}
+	if len(rsp.Header.Get("Accept-Ranges")) > 0 {
+		t.Fatal("Expected ranges not to be accepted on compressed responses")
+	}
+	if vary := rsp.Header.Get("Vary"); vary != "Accept-Encoding" {
+		t.Fatalf("Expected the response to vary by encoding got %q", vary)
+	}
+}
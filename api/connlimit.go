@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// connState counts the requests of a connection in the current second
+type connState struct {
+	conn     net.Conn
+	window   time.Time
+	requests int
+	resets   int
+	closed   bool
+}
+
+// connLimiter closes connections starting or resetting requests faster than
+// allowed per second. It stops http2 floods such as rapid reset, where
+// clients open streams and cancel them straight away so the max concurrent
+// streams never applies. Connections are tracked by their remote address,
+// which is the same for every stream of a http2 connection.
+type connLimiter struct {
+	// max requests started per second, unlimited if 0
+	maxRequests int
+	// max requests cancelled by the client per second, unlimited if 0
+	maxResets int
+	// called when a connection is closed e.g to record stats
+	record func(event string)
+
+	sync.Mutex
+	conns map[string]*connState
+}
+
+func newConnLimiter(maxRequests, maxResets int, record func(string)) *connLimiter {
+	return &connLimiter{
+		maxRequests: maxRequests,
+		maxResets:   maxResets,
+		record:      record,
+		conns:       make(map[string]*connState),
+	}
+}
+
+// limitedConn forgets the connection once closed
+type limitedConn struct {
+	net.Conn
+	once sync.Once
+	l    *connLimiter
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(func() {
+		c.l.Lock()
+		delete(c.l.conns, c.RemoteAddr().String())
+		c.l.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// limitListener tracks accepted connections so they can be closed
+type limitListener struct {
+	net.Listener
+	l *connLimiter
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.l.Lock()
+	l.l.conns[c.RemoteAddr().String()] = &connState{conn: c, window: time.Now()}
+	l.l.Unlock()
+
+	return &limitedConn{Conn: c, l: l.l}, nil
+}
+
+// Listener returns the listener tracking the connections limited
+func (c *connLimiter) Listener(l net.Listener) net.Listener {
+	return &limitListener{Listener: l, l: c}
+}
+
+// count counts a request or reset of the connection, closing it once over
+// the limit. It returns false if the connection was closed.
+func (c *connLimiter) count(addr string, reset bool) bool {
+	c.Lock()
+	st, ok := c.conns[addr]
+	if !ok {
+		c.Unlock()
+		return true
+	}
+	if st.closed {
+		c.Unlock()
+		return false
+	}
+
+	if time.Since(st.window) >= time.Second {
+		st.window = time.Now()
+		st.requests = 0
+		st.resets = 0
+	}
+
+	var over bool
+	if reset {
+		st.resets++
+		over = c.maxResets > 0 && st.resets > c.maxResets
+	} else {
+		st.requests++
+		over = c.maxRequests > 0 && st.requests > c.maxRequests
+	}
+	if over {
+		st.closed = true
+	}
+	requests, resets := st.requests, st.resets
+	c.Unlock()
+
+	if !over {
+		return true
+	}
+
+	log.Warnf("Closing connection from %s after %d requests and %d resets in a second", addr, requests, resets)
+	c.record("conn_limited")
+	st.conn.Close()
+	return false
+}
+
+func (c *connLimiter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.count(r.RemoteAddr, false) {
+			return
+		}
+
+		h.ServeHTTP(w, r)
+
+		// the client reset the stream or went away before the response
+		if r.Context().Err() != nil {
+			c.count(r.RemoteAddr, true)
+		}
+	})
+}
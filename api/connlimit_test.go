@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// pipeListener accepts a single end of a pipe
+type pipeListener struct {
+	net.Listener
+	conn net.Conn
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	return l.conn, nil
+}
+
+func TestConnLimiter(t *testing.T) {
+	testData := []struct {
+		maxRequests int
+		maxResets   int
+		// whether the requests are cancelled by the client
+		cancelled bool
+		served    int
+	}{
+		{3, 0, false, 3},
+		// resets are counted once the request is served
+		{0, 3, true, 4},
+	}
+
+	for _, d := range testData {
+		server, client := net.Pipe()
+		var closed int
+		c := newConnLimiter(d.maxRequests, d.maxResets, func(string) { closed++ })
+
+		conn, err := c.Listener(&pipeListener{conn: server}).Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var served int
+		h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			served++
+		}))
+
+		for i := 0; i < 5; i++ {
+			r := httptest.NewRequest("GET", "/foo", nil)
+			r.RemoteAddr = conn.RemoteAddr().String()
+			if d.cancelled {
+				ctx, cancel := context.WithCancel(r.Context())
+				cancel()
+				r = r.WithContext(ctx)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+		}
+
+		// the request over the limit closes the connection
+		if served != d.served {
+			t.Fatalf("Expected %d requests served got %d for %+v", d.served, served, d)
+		}
+		if closed != 1 {
+			t.Fatalf("Expected the connection to be closed once got %d", closed)
+		}
+		if _, err := client.Read(make([]byte, 1)); err != io.EOF {
+			t.Fatalf("Expected the connection to be closed got %v", err)
+		}
+
+		// closed connections are forgotten
+		conn.Close()
+		if len(c.conns) != 0 {
+			t.Fatalf("Expected closed connections to be forgotten got %d", len(c.conns))
+		}
+	}
+}
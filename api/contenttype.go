@@ -0,0 +1,60 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// contentTypeWriter sets a default content type on responses which don't set one
+type contentTypeWriter struct {
+	http.ResponseWriter
+	contentType string
+	wroteHeader bool
+}
+
+func (w *contentTypeWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if len(w.Header().Get("Content-Type")) == 0 {
+			w.Header().Set("Content-Type", w.contentType)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *contentTypeWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *contentTypeWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *contentTypeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// contentTypeHandler applies the default content type to responses and
+// optionally stops clients from sniffing the content type
+func contentTypeHandler(contentType string, nosniff bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if nosniff {
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+		}
+		if len(contentType) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(&contentTypeWriter{ResponseWriter: w, contentType: contentType}, r)
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContentType(t *testing.T) {
+	testData := []struct {
+		defaultType string
+		nosniff     bool
+		// content type set by the backend
+		backendType string
+		expect      string
+	}{
+		{"application/json", false, "", "application/json"},
+		{"application/json", false, "text/html", "text/html"},
+		{"application/json", true, "", "application/json"},
+		// without a default the server sniffs the body
+		{"", false, "", "text/plain; charset=utf-8"},
+	}
+
+	for _, d := range testData {
+		h := contentTypeHandler(d.defaultType, d.nosniff, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(d.backendType) > 0 {
+				w.Header().Set("Content-Type", d.backendType)
+			}
+			w.Write([]byte("hello"))
+		}))
+
+		srv := httptest.NewServer(h)
+		rsp, err := http.Get(srv.URL)
+		srv.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if ct := rsp.Header.Get("Content-Type"); ct != d.expect {
+			t.Fatalf("Expected content type %q got %q", d.expect, ct)
+		}
+		if nosniff := rsp.Header.Get("X-Content-Type-Options") == "nosniff"; nosniff != d.nosniff {
+			t.Fatalf("Expected nosniff to be %v", d.nosniff)
+		}
+	}
+
+	// responses without a body still get the default
+	w := httptest.NewRecorder()
+	contentTypeHandler("application/json", false, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})).ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Expected default content type without a body got %q", ct)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// headers browsers may send cross origin, including the Timeout read by
+// the rpc handler
+const corsAllowHeaders = "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Timeout"
+
+// methods allowed cross origin by default
+const corsAllowMethods = "POST, PATCH, GET, OPTIONS, PUT, DELETE"
+
+// corsOptions restricts the cross origin requests allowed. Empty lists
+// allow any origin and the default methods and headers.
+type corsOptions struct {
+	origins []string
+	methods []string
+	headers []string
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin of the request origin,
+// false if the origin isn't allowed
+func (o corsOptions) allowOrigin(origin string) (string, bool) {
+	if len(o.origins) == 0 {
+		if len(origin) == 0 {
+			return "*", true
+		}
+		return origin, true
+	}
+	for _, allowed := range o.origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return origin, len(origin) > 0
+		}
+	}
+	return "", false
+}
+
+// corsHandler sets the cors headers of responses from allowed origins and
+// answers preflight requests without passing them on to h
+func corsHandler(opts corsOptions, h http.Handler) http.Handler {
+	methods := corsAllowMethods
+	if len(opts.methods) > 0 {
+		methods = strings.Join(opts.methods, ", ")
+	}
+	headers := corsAllowHeaders
+	if len(opts.headers) > 0 {
+		headers = strings.Join(opts.headers, ", ")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the response depends on the origin once restricted
+		if len(opts.origins) > 0 {
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if origin, ok := opts.allowOrigin(r.Header.Get("Origin")); ok {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+		}
+
+		// without the headers browsers reject preflights of other origins
+		if r.Method == "OPTIONS" {
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// sameOrigin determines whether the request comes from a page served by
+// the gateway itself, in which case the browser doesn't need cors headers
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	} else if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+		scheme = strings.ToLower(proto)
+	}
+
+	return strings.EqualFold(u.Scheme, scheme) && strings.EqualFold(u.Host, r.Host)
+}
+
+// sameOriginHandler serves same origin requests with h directly, skipping
+// the cors handling applied to cross origin requests
+func sameOriginHandler(cors, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sameOrigin(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		cors.ServeHTTP(w, r)
+	})
+}
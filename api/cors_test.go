@@ -0,0 +1,125 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSameOrigin(t *testing.T) {
+	testData := []struct {
+		origin    string
+		host      string
+		tls       bool
+		forwarded string
+		same      bool
+	}{
+		{"", "example.com", false, "", false},
+		{"http://example.com", "example.com", false, "", true},
+		{"http://example.com:8080", "example.com:8080", false, "", true},
+		{"http://example.com", "example.com:8080", false, "", false},
+		{"http://other.com", "example.com", false, "", false},
+		{"https://example.com", "example.com", false, "", false},
+		{"https://example.com", "example.com", true, "", true},
+		{"https://example.com", "example.com", false, "https", true},
+		{"null", "example.com", false, "", false},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("OPTIONS", "/foo", nil)
+		r.Host = d.host
+		if len(d.origin) > 0 {
+			r.Header.Set("Origin", d.origin)
+		}
+		if d.tls {
+			r.TLS = &tls.ConnectionState{}
+		} else {
+			r.TLS = nil
+		}
+		if len(d.forwarded) > 0 {
+			r.Header.Set("X-Forwarded-Proto", d.forwarded)
+		}
+
+		if same := sameOrigin(r); same != d.same {
+			t.Fatalf("Expected same origin %v for %s on %s got %v", d.same, d.origin, d.host, same)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	s := newServer("127.0.0.1:0")
+	s.opts.EnableCORS = true
+	s.Handle("/rpc", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			t.Fatal("Expected preflight not to be processed as an rpc call")
+		}
+	}))
+
+	// a browser calling /rpc with a timeout preflights the request
+	r := httptest.NewRequest("OPTIONS", "/rpc", nil)
+	r.Header.Set("Origin", "http://localhost:3000")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "content-type,timeout")
+
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 got %d", w.Code)
+	}
+	if origin := w.Header().Get("Access-Control-Allow-Origin"); origin != "http://localhost:3000" {
+		t.Fatalf("Expected the origin to be allowed got %q", origin)
+	}
+	if headers := w.Header().Get("Access-Control-Allow-Headers"); headers != corsAllowHeaders {
+		t.Fatalf("Expected allowed headers %q got %q", corsAllowHeaders, headers)
+	}
+}
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	s := newServer("127.0.0.1:0")
+	s.opts.EnableCORS = true
+	s.Configure(withCORS(corsOptions{
+		origins: []string{"https://app.example.com"},
+		methods: []string{"GET", "POST"},
+		headers: []string{"Content-Type"},
+	}))
+	s.Handle("/rpc", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	testData := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://evil.example.com", false},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("OPTIONS", "/rpc", nil)
+		r.Header.Set("Origin", d.origin)
+		r.Header.Set("Access-Control-Request-Method", "POST")
+
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, r)
+
+		origin := w.Header().Get("Access-Control-Allow-Origin")
+		if !d.allowed {
+			if len(origin) > 0 || len(w.Header().Get("Access-Control-Allow-Methods")) > 0 {
+				t.Fatalf("Expected origin %s not to be allowed got %v", d.origin, w.Header())
+			}
+			continue
+		}
+		if origin != d.origin {
+			t.Fatalf("Expected origin %s to be allowed got %q", d.origin, origin)
+		}
+		if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "GET, POST" {
+			t.Fatalf("Expected the configured methods got %q", methods)
+		}
+		if headers := w.Header().Get("Access-Control-Allow-Headers"); headers != "Content-Type" {
+			t.Fatalf("Expected the configured headers got %q", headers)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Origin" {
+			t.Fatalf("Expected the response to vary by origin got %q", vary)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// decoders of the request encodings the gateway can decompress
+var decoders = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip": func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"deflate": func(r io.Reader) (io.ReadCloser, error) {
+		return flate.NewReader(r), nil
+	},
+}
+
+// errBodyTooLarge is returned reading a decompressed body over the max size
+var errBodyTooLarge = errors.New("decompressed request body too large")
+
+// decodedBody is a decompressed request body failing reads past the max size
+type decodedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+	// read a byte past the max to detect bodies exceeding it
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		b.exceeded = true
+		return int(b.remaining), errBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// decompressor checks the Content-Encoding of requests, rejecting those the
+// gateway doesn't support with a 415 rather than forwarding a body backends
+// can't process. Supported encodings the gateway can decode are optionally
+// decompressed, the rest are forwarded as is. Decompressed bodies larger
+// than the max size are rejected with a 413.
+type decompressor struct {
+	// supported encodings, any if empty
+	encodings map[string]bool
+	// decompress gzip and deflate bodies
+	decode bool
+	// max size of a decompressed body
+	maxSize int64
+	// list returned to the client in Accept-Encoding
+	accept string
+}
+
+func newDecompressor(encodings []string, decode bool, maxSize int64) *decompressor {
+	d := &decompressor{
+		encodings: make(map[string]bool),
+		decode:    decode,
+		maxSize:   maxSize,
+	}
+	var accept []string
+	for _, e := range encodings {
+		e = strings.ToLower(e)
+		d.encodings[e] = true
+		accept = append(accept, e)
+	}
+	d.accept = strings.Join(accept, ", ")
+	return d
+}
+
+// unsupported returns the first encoding of the request which isn't supported
+func (d *decompressor) unsupported(r *http.Request) string {
+	if len(d.encodings) == 0 {
+		return ""
+	}
+	for _, v := range strings.Split(r.Header.Get("Content-Encoding"), ",") {
+		e := strings.ToLower(strings.TrimSpace(v))
+		if len(e) == 0 || e == "identity" {
+			continue
+		}
+		if !d.encodings[e] {
+			return e
+		}
+	}
+	return ""
+}
+
+func (d *decompressor) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("Content-Encoding")) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if e := d.unsupported(r); len(e) > 0 {
+			// tell the client which encodings it can use instead
+			w.Header().Set("Accept-Encoding", d.accept)
+			msg := fmt.Sprintf("Unsupported Content-Encoding %s, supported encodings are %s", e, d.accept)
+			writeError(w, msg, http.StatusUnsupportedMediaType)
+			return
+		}
+
+		// only single encodings are decompressed
+		enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		decoder, ok := decoders[enc]
+		if !d.decode || !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := decoder(r.Body)
+		if err != nil {
+			writeError(w, "Invalid "+enc+" request body", http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+
+		db := &decodedBody{ReadCloser: body, remaining: d.maxSize}
+		r.Body = readCloser{db, r.Body}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+
+		dw := &tooLargeWriter{
+			ResponseWriter: w,
+			msg:            errBodyTooLarge.Error(),
+			tooLarge:       func() bool { return db.exceeded },
+		}
+		h.ServeHTTP(dw, r)
+		// the handler may have stopped reading without responding
+		if db.exceeded && !dw.wrote {
+			dw.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	})
+}
+
+// accepts determines whether the client accepts the content encoding
+func accepts(r *http.Request, enc string) bool {
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(strings.TrimSpace(v), ";")
+		if len(parts) > 1 && strings.Replace(parts[1], " ", "", -1) == "q=0" {
+			continue
+		}
+		if e := strings.ToLower(parts[0]); e == enc || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeWriter decompresses a backend response the client doesn't accept
+type decodeWriter struct {
+	http.ResponseWriter
+	r *http.Request
+
+	wroteHeader bool
+	pw          *io.PipeWriter
+	done        chan error
+}
+
+func (w *decodeWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	hdr := w.Header()
+	enc := strings.ToLower(strings.TrimSpace(hdr.Get("Content-Encoding")))
+	decoder, ok := decoders[enc]
+	if !ok || accepts(w.r, enc) {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	hdr.Del("Content-Encoding")
+	hdr.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+
+	// decode the body as it's written
+	pr, pw := io.Pipe()
+	w.pw = pw
+	w.done = make(chan error, 1)
+	go func() {
+		body, err := decoder(pr)
+		if err == nil {
+			_, err = io.Copy(w.ResponseWriter, body)
+			body.Close()
+		}
+		// fail any further writes rather than blocking them
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+}
+
+func (w *decodeWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.pw != nil {
+		return w.pw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *decodeWriter) Flush() {
+	// decoded data is flushed once the decoder writes it
+	if w.pw != nil {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close waits for the decoded body to be written
+func (w *decodeWriter) Close() error {
+	if w.pw == nil {
+		return nil
+	}
+	w.pw.Close()
+	return <-w.done
+}
+
+// decodeHandler decompresses backend responses whose Content-Encoding the
+// client doesn't accept before forwarding them
+func decodeHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if upgrading(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		dw := &decodeWriter{ResponseWriter: w, r: r}
+		h.ServeHTTP(dw, r)
+		if err := dw.Close(); err != nil {
+			log.Debugf("Failed to decode %s response: %v", r.URL.Path, err)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecompressor(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`{"name": "john"}`))
+	w.Close()
+
+	testData := []struct {
+		encoding string
+		body     []byte
+		decode   bool
+		code     int
+		received string
+	}{
+		{"", []byte(`{"name": "john"}`), true, 200, `{"name": "john"}`},
+		{"identity", []byte(`{"name": "john"}`), true, 200, `{"name": "john"}`},
+		{"gzip", gz.Bytes(), true, 200, `{"name": "john"}`},
+		{"gzip", gz.Bytes(), false, 200, gz.String()},
+		{"gzip", []byte("not gzip"), true, 400, ""},
+		{"br", []byte("brotli"), true, 415, ""},
+		{"gzip, br", gz.Bytes(), true, 415, ""},
+	}
+
+	for _, d := range testData {
+		var received string
+		h := newDecompressor([]string{"gzip", "deflate"}, d.decode, 1<<20).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			received = string(b)
+		}))
+
+		r := httptest.NewRequest("POST", "/foo", bytes.NewReader(d.body))
+		if len(d.encoding) > 0 {
+			r.Header.Set("Content-Encoding", d.encoding)
+		}
+		rsp := httptest.NewRecorder()
+		h.ServeHTTP(rsp, r)
+
+		if rsp.Code != d.code {
+			t.Fatalf("Expected status %d for %q got %d", d.code, d.encoding, rsp.Code)
+		}
+		if received != d.received {
+			t.Fatalf("Expected body %q for %q got %q", d.received, d.encoding, received)
+		}
+		if d.code == 415 && rsp.Header().Get("Accept-Encoding") != "gzip, deflate" {
+			t.Fatalf("Expected Accept-Encoding of supported encodings got %q", rsp.Header().Get("Accept-Encoding"))
+		}
+	}
+}
+
+func TestDecompressMaxSize(t *testing.T) {
+	// a small body decompressing to 1MB
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(make([]byte, 1<<20))
+	w.Close()
+
+	testData := []struct {
+		maxSize int64
+		code    int
+	}{
+		{2 << 20, http.StatusOK},
+		{1 << 20, http.StatusOK},
+		{1 << 10, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, d := range testData {
+		var received int
+		h := newDecompressor(nil, true, d.maxSize).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			received = len(b)
+		}))
+
+		r := httptest.NewRequest("POST", "/foo", bytes.NewReader(gz.Bytes()))
+		r.Header.Set("Content-Encoding", "gzip")
+		rsp := httptest.NewRecorder()
+		h.ServeHTTP(rsp, r)
+
+		if rsp.Code != d.code {
+			t.Fatalf("Expected status %d for max size %d got %d", d.code, d.maxSize, rsp.Code)
+		}
+		if d.code == http.StatusOK && received != 1<<20 {
+			t.Fatalf("Expected the whole body to be received got %d bytes", received)
+		}
+		if d.code == http.StatusRequestEntityTooLarge && received > 0 {
+			t.Fatal("Expected the handler to fail reading the body")
+		}
+	}
+}
+
+func TestDecodeHandler(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`{"name": "john"}`))
+	w.Close()
+
+	h := decodeHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", "1000")
+		w.Write(gz.Bytes())
+	}))
+
+	testData := []struct {
+		accept   string
+		encoding string
+		body     string
+	}{
+		{"gzip", "gzip", gz.String()},
+		{"gzip;q=1, deflate", "gzip", gz.String()},
+		{"*", "gzip", gz.String()},
+		{"identity", "", `{"name": "john"}`},
+		{"gzip;q=0", "", `{"name": "john"}`},
+		{"br", "", `{"name": "john"}`},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.Header.Set("Accept-Encoding", d.accept)
+		rsp := httptest.NewRecorder()
+		h.ServeHTTP(rsp, r)
+
+		if enc := rsp.Header().Get("Content-Encoding"); enc != d.encoding {
+			t.Fatalf("Expected encoding %q for %q got %q", d.encoding, d.accept, enc)
+		}
+		if rsp.Body.String() != d.body {
+			t.Fatalf("Expected body %q for %q got %q", d.body, d.accept, rsp.Body.String())
+		}
+	}
+}
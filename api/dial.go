@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// clientDialTimedOut determines whether the error of a call is the go-micro
+// client timing out connecting to the node, which has no typed error
+func clientDialTimedOut(err error) bool {
+	e := errors.Parse(err.Error())
+	return e.Id == clientErrorID && strings.HasPrefix(e.Detail, "connection error") && strings.Contains(e.Detail, "i/o timeout")
+}
+
+// backendDialer caps the time taken to connect to backends so unreachable
+// nodes fail fast rather than taking the time of the request, counting the
+// connections which timed out
+type backendDialer struct {
+	// 0 keeps the timeouts of the transport and the client
+	timeout  time.Duration
+	timeouts uint64
+}
+
+func newBackendDialer(timeout time.Duration) *backendDialer {
+	return &backendDialer{timeout: timeout}
+}
+
+// DialContext connects to backends of requests proxied over http and
+// websockets
+func (d *backendDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	timeout := d.timeout
+	if timeout <= 0 {
+		// the timeout of the default transport
+		timeout = 30 * time.Second
+	}
+	nd := net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	conn, err := nd.DialContext(ctx, network, addr)
+	// the request running out of time isn't the dial timing out
+	if err != nil && ctx.Err() == nil && dialTimedOut(err) {
+		atomic.AddUint64(&d.timeouts, 1)
+	}
+	return conn, err
+}
+
+// Timeouts returns the number of connections to backends which timed out
+func (d *backendDialer) Timeouts() uint64 {
+	return atomic.LoadUint64(&d.timeouts)
+}
+
+// dialTimeoutClient sets the dial timeout of calls, counting each attempt
+// at a node which timed out connecting. Calls failing as the last node
+// couldn't be connected to return a 503.
+type dialTimeoutClient struct {
+	client.Client
+	dialer *backendDialer
+}
+
+func (c *dialTimeoutClient) options(opts []client.CallOption) []client.CallOption {
+	opts = opts[:len(opts):len(opts)]
+	if c.dialer.timeout > 0 {
+		opts = append(opts, client.WithDialTimeout(c.dialer.timeout))
+	}
+	return opts
+}
+
+func (c *dialTimeoutClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	opts = append(c.options(opts), client.WithCallWrapper(func(next client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+			err := next(ctx, node, req, rsp, opts)
+			if err != nil && clientDialTimedOut(err) {
+				atomic.AddUint64(&c.dialer.timeouts, 1)
+			}
+			return err
+		}
+	}))
+
+	err := c.Client.Call(ctx, req, rsp, opts...)
+	if err != nil && clientDialTimedOut(err) {
+		return errors.New("go.micro.api", http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+	return err
+}
+
+func (c *dialTimeoutClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return c.Client.Stream(ctx, req, c.options(opts)...)
+}
+
+// dialTimeoutWrapper returns a client wrapper capping the time calls take
+// to connect to a node
+func dialTimeoutWrapper(d *backendDialer) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &dialTimeoutClient{Client: c, dialer: d}
+	}
+}
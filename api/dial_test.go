@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+func TestDialTimeoutProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+
+	service := &api.Service{
+		Name: "go.micro.api.greeter",
+		Services: []*registry.Service{{
+			Name:  "go.micro.api.greeter",
+			Nodes: []*registry.Node{{Id: "greeter-1", Address: strings.TrimPrefix(backend.URL, "http://")}},
+		}},
+	}
+
+	// connections time out before they're established
+	dialer := newBackendDialer(time.Nanosecond)
+	h := proxyHandler(&testRouter{service: service}, newProxyBufferPool(1024), 1<<20, dialer, randomStrategy)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/greeter", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected a 503 got %d", w.Code)
+	}
+	if n := dialer.Timeouts(); n != 1 {
+		t.Fatalf("Expected 1 dial timeout got %d", n)
+	}
+
+	// the request running out of time isn't counted
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, err := dialer.DialContext(ctx, "tcp", strings.TrimPrefix(backend.URL, "http://")); err == nil {
+		t.Fatal("Expected the dial to fail")
+	}
+	if n := dialer.Timeouts(); n != 1 {
+		t.Fatalf("Expected the cancelled dial not to be counted got %d", n)
+	}
+
+	w = httptest.NewRecorder()
+	h = proxyHandler(&testRouter{service: service}, newProxyBufferPool(1024), 1<<20, newBackendDialer(time.Second), randomStrategy)
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/greeter", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 got %d", w.Code)
+	}
+}
+
+// dialClient fails calls with the error of connecting to the node
+type dialClient struct {
+	client.Client
+	err     error
+	options client.CallOptions
+}
+
+func (c *dialClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.options = client.CallOptions{}
+	for _, o := range opts {
+		o(&c.options)
+	}
+	call := func(context.Context, *registry.Node, client.Request, interface{}, client.CallOptions) error {
+		return c.err
+	}
+	for i := len(c.options.CallWrappers); i > 0; i-- {
+		call = c.options.CallWrappers[i-1](call)
+	}
+	return call(ctx, &registry.Node{Id: "greeter-1"}, req, rsp, c.options)
+}
+
+func TestDialTimeoutClient(t *testing.T) {
+	testData := []struct {
+		err      error
+		code     int32
+		timeouts uint64
+	}{
+		{errors.InternalServerError(clientErrorID, "connection error: dial tcp 10.0.0.1:8080: i/o timeout"), http.StatusServiceUnavailable, 1},
+		{errors.InternalServerError(clientErrorID, "connection error: dial tcp 10.0.0.1:8080: connect: connection refused"), http.StatusInternalServerError, 0},
+		{errors.InternalServerError("go.micro.srv.greeter", "i/o timeout"), http.StatusInternalServerError, 0},
+	}
+
+	for _, d := range testData {
+		dialer := newBackendDialer(time.Second)
+		dc := &dialClient{err: d.err}
+		err := dialTimeoutWrapper(dialer)(dc).Call(context.Background(), &testRequest{service: "go.micro.srv.greeter"}, nil)
+
+		if e := errors.Parse(err.Error()); e.Code != d.code {
+			t.Fatalf("Expected %d for %v got %v", d.code, d.err, err)
+		}
+		if n := dialer.Timeouts(); n != d.timeouts {
+			t.Fatalf("Expected %d dial timeouts for %v got %d", d.timeouts, d.err, n)
+		}
+		if dc.options.DialTimeout != time.Second {
+			t.Fatalf("Expected the dial timeout to be set got %v", dc.options.DialTimeout)
+		}
+	}
+}
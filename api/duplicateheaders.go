@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/textproto"
+)
+
+// duplicateHeaderHandler applies the policy to requests carrying any of the
+// headers more than once, so the gateway and the backend can't disagree on
+// e.g which Authorization header authenticates the request.
+//
+// reject: fail the request with a 400
+// first: keep the first value
+// last: keep the last value
+func duplicateHeaderHandler(policy string, headers []string, h http.Handler) http.Handler {
+	names := make([]string, len(headers))
+	for i, name := range headers {
+		names[i] = textproto.CanonicalMIMEHeaderKey(name)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range names {
+			values := r.Header[name]
+			if len(values) < 2 {
+				continue
+			}
+
+			switch policy {
+			case "first":
+				r.Header[name] = values[:1]
+			case "last":
+				r.Header[name] = values[len(values)-1:]
+			default:
+				writeError(w, fmt.Sprintf("Duplicate %s header", name), http.StatusBadRequest)
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDuplicateHeaders(t *testing.T) {
+	testData := []struct {
+		policy string
+		values []string
+		code   int
+		want   []string
+	}{
+		{"reject", []string{"Bearer a"}, http.StatusOK, []string{"Bearer a"}},
+		{"reject", []string{"Bearer a", "Bearer b"}, http.StatusBadRequest, nil},
+		{"first", []string{"Bearer a", "Bearer b"}, http.StatusOK, []string{"Bearer a"}},
+		{"last", []string{"Bearer a", "Bearer b"}, http.StatusOK, []string{"Bearer b"}},
+	}
+
+	for _, d := range testData {
+		var got []string
+		h := duplicateHeaderHandler(d.policy, []string{"authorization"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header["Authorization"]
+		}))
+
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.Header["Authorization"] = d.values
+		// headers not checked may repeat
+		r.Header["Accept"] = []string{"text/html", "application/json"}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %s of %v got %d", d.code, d.policy, d.values, w.Code)
+		}
+		if len(got) != len(d.want) || (len(got) > 0 && got[0] != d.want[0]) {
+			t.Fatalf("Expected %v for %s of %v got %v", d.want, d.policy, d.values, got)
+		}
+		if d.code == http.StatusOK && len(r.Header["Accept"]) != 2 {
+			t.Fatalf("Expected unchecked headers to be kept got %v", r.Header["Accept"])
+		}
+	}
+}
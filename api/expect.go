@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// expectHandler applies the Expect: 100-continue policy. The server only
+// sends the 100 response once the body is first read so anything rejected
+// before that point, e.g by the auth wrapper or size check, never continues.
+//
+// gateway: validate the request and answer the expectation at the gateway
+// forward: pass the expectation to the backend and relay its response
+// reject: fail the expectation with a 417
+func expectHandler(policy string, maxSize int64, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		switch policy {
+		case "reject":
+			writeError(w, "Expectation failed", http.StatusExpectationFailed)
+			return
+		case "forward":
+			// the backend decides whether the body is sent
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if maxSize > 0 && r.ContentLength > maxSize {
+			writeError(w, "Request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		// the expectation is answered here so don't ask the backend
+		r.Header.Del("Expect")
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sendExpect sends a request expecting a 100-continue, sending the body
+// once the server continues, and returns the status lines received
+func sendExpect(t *testing.T, addr string, size int) []string {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "POST /greeter HTTP/1.1\r\nHost: localhost\r\nExpect: 100-continue\r\nContent-Length: %d\r\n\r\n", size)
+
+	var lines []string
+	rd := bufio.NewReader(conn)
+	for {
+		rsp, err := http.ReadResponse(rd, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, rsp.Status)
+		if rsp.StatusCode != http.StatusContinue {
+			rsp.Body.Close()
+			return lines
+		}
+		conn.Write([]byte(strings.Repeat("a", size)))
+	}
+}
+
+func TestExpect(t *testing.T) {
+	testData := []struct {
+		policy string
+		size   int
+		// the backend reads the body
+		read   bool
+		expect []string
+		// the backend saw the expectation
+		forwarded bool
+	}{
+		// the backend reading the body makes the server continue
+		{"forward", 10, true, []string{"100 Continue", "200 OK"}, true},
+		// the backend rejecting the request never gets the body
+		{"forward", 10, false, []string{"403 Forbidden"}, true},
+		{"gateway", 10, true, []string{"100 Continue", "200 OK"}, false},
+		{"gateway", 100, true, []string{"413 Request Entity Too Large"}, false},
+		{"reject", 10, true, []string{"417 Expectation Failed"}, false},
+	}
+
+	for _, d := range testData {
+		var forwarded bool
+		srv := httptest.NewServer(expectHandler(d.policy, 50, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			forwarded = len(r.Header.Get("Expect")) > 0
+			if !d.read {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			if len(b) != d.size {
+				t.Errorf("Expected body of %d bytes got %d", d.size, len(b))
+			}
+		})))
+
+		lines := sendExpect(t, srv.Listener.Addr().String(), d.size)
+		srv.Close()
+
+		if strings.Join(lines, ",") != strings.Join(d.expect, ",") {
+			t.Fatalf("Expected %s policy to respond %v got %v", d.policy, d.expect, lines)
+		}
+		if forwarded != d.forwarded {
+			t.Fatalf("Expected %s policy forwarding the expectation to be %v", d.policy, d.forwarded)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/errors"
+	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+var (
+	// node metadata key identifying the region of a backend
+	regionKey = "region"
+	// id of errors returned when the client can't reach a backend
+	clientErrorID = "go.micro.client"
+	// header the gateway passes the http method of a request to client
+	// wrappers in, handlers copy headers into the call metadata
+	methodKey = "Micro-Api-Method"
+	// http methods which are safe to fail over
+	idempotentMethods = map[string]bool{
+		"GET":     true,
+		"HEAD":    true,
+		"OPTIONS": true,
+		"PUT":     true,
+		"DELETE":  true,
+	}
+)
+
+// failoverClient prefers backends in the local region and, when none of
+// them can be reached, fails over to the other regions in priority order.
+// Backends without a region are treated as local so they're still served
+// while being labelled. Failover only applies to idempotent requests. The http handler proxies
+// requests itself so it isn't covered.
+type failoverClient struct {
+	client.Client
+	// local region
+	region string
+	// regions failed over to in priority order
+	regions []string
+}
+
+// methodHandler passes the http method of the request to the client
+// wrappers, replacing any value sent by the client so it can't mark a
+// request idempotent
+func methodHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set(methodKey, r.Method)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// idempotent determines whether the request being made is safe to
+// retry based on the http method passed by the gateway or an
+// idempotency key set by the client
+func idempotent(ctx context.Context) bool {
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	if len(md["Idempotency-Key"]) > 0 || len(md["X-Idempotency-Key"]) > 0 {
+		return true
+	}
+	return idempotentMethods[md[methodKey]]
+}
+
+// unreachable determines whether the error means the backends couldn't
+// be selected or reached, as opposed to an error they returned
+func unreachable(err error) bool {
+	if err == selector.ErrNotFound || err == selector.ErrNoneAvailable {
+		return true
+	}
+	return errors.Parse(err.Error()).Id == clientErrorID
+}
+
+// regionFilter returns a selector filter keeping the nodes in the region,
+// and those without a region when local
+func regionFilter(region string, local bool) selector.Filter {
+	return func(old []*registry.Service) []*registry.Service {
+		var services []*registry.Service
+		for _, s := range old {
+			var nodes []*registry.Node
+			for _, n := range s.Nodes {
+				if r := n.Metadata[regionKey]; r == region || (local && len(r) == 0) {
+					nodes = append(nodes, n)
+				}
+			}
+			if len(nodes) == 0 {
+				continue
+			}
+			service := new(registry.Service)
+			*service = *s
+			service.Nodes = nodes
+			services = append(services, service)
+		}
+		return services
+	}
+}
+
+// inRegion returns the call options to select a backend in the region, or
+// without a region when local
+func inRegion(region string, local bool, opts []client.CallOption) []client.CallOption {
+	filter := selector.WithFilter(regionFilter(region, local))
+	return append(opts[:len(opts):len(opts)], client.WithSelectOption(filter))
+}
+
+func (f *failoverClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	err := f.Client.Call(ctx, req, rsp, inRegion(f.region, true, opts)...)
+	if err == nil || !unreachable(err) || !idempotent(ctx) {
+		return err
+	}
+
+	for _, region := range f.regions {
+		log.Debugf("Failing over %s to region %s: %v", req.Service(), region, err)
+		err = f.Client.Call(ctx, req, rsp, inRegion(region, false, opts)...)
+		if err == nil || !unreachable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (f *failoverClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	stream, err := f.Client.Stream(ctx, req, inRegion(f.region, true, opts)...)
+	if err == nil || !unreachable(err) || !idempotent(ctx) {
+		return stream, err
+	}
+
+	for _, region := range f.regions {
+		log.Debugf("Failing over %s stream to region %s: %v", req.Service(), region, err)
+		stream, err = f.Client.Stream(ctx, req, inRegion(region, false, opts)...)
+		if err == nil || !unreachable(err) {
+			return stream, err
+		}
+	}
+
+	return stream, err
+}
+
+// failoverWrapper returns a client wrapper failing over between regions
+func failoverWrapper(region string, regions []string) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &failoverClient{
+			Client:  c,
+			region:  region,
+			regions: regions,
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// unreachableClient fails every call as if the backends can't be reached
+type unreachableClient struct {
+	client.Client
+	calls int
+}
+
+func (c *unreachableClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.calls++
+	return errors.InternalServerError(clientErrorID, "connection refused")
+}
+
+func TestFailover(t *testing.T) {
+	testData := []struct {
+		method string
+		header map[string]string
+		calls  int
+	}{
+		{"GET", nil, 3},
+		{"POST", nil, 1},
+		// the client can't claim a write is idempotent by the method
+		{"POST", map[string]string{methodKey: "GET"}, 1},
+		{"POST", map[string]string{"Method": "GET"}, 1},
+		{"POST", map[string]string{"Idempotency-Key": "123"}, 3},
+	}
+
+	for _, d := range testData {
+		c := &unreachableClient{}
+		fc := failoverWrapper("eu", []string{"us", "ap"})(c)
+
+		h := methodHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// handlers build the call metadata from the headers
+			md := make(metadata.Metadata)
+			for k, v := range r.Header {
+				md[k] = strings.Join(v, ",")
+			}
+			fc.Call(metadata.NewContext(context.Background(), md), &testRequest{service: "go.micro.srv.greeter"}, nil)
+		}))
+
+		r := httptest.NewRequest(d.method, "/greeter", nil)
+		for k, v := range d.header {
+			r.Header.Set(k, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if c.calls != d.calls {
+			t.Fatalf("Expected %d calls for %s %v got %d", d.calls, d.method, d.header, c.calls)
+		}
+	}
+}
+
+func TestRegionFilter(t *testing.T) {
+	services := []*registry.Service{{
+		Name: "go.micro.srv.greeter",
+		Nodes: []*registry.Node{
+			{Id: "greeter-1", Metadata: map[string]string{regionKey: "eu"}},
+			{Id: "greeter-2", Metadata: map[string]string{regionKey: "us"}},
+			// nodes not yet labelled with a region
+			{Id: "greeter-3"},
+			{Id: "greeter-4", Metadata: map[string]string{"zone": "a"}},
+		},
+	}}
+
+	ids := func(services []*registry.Service) string {
+		var ids []string
+		for _, s := range services {
+			for _, n := range s.Nodes {
+				ids = append(ids, n.Id)
+			}
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if local := ids(regionFilter("eu", true)(services)); local != "greeter-1,greeter-3,greeter-4" {
+		t.Fatalf("Expected greeter-1,greeter-3,greeter-4 in the local region got %s", local)
+	}
+	if other := ids(regionFilter("us", false)(services)); other != "greeter-2" {
+		t.Fatalf("Expected greeter-2 in the region failed over to got %s", other)
+	}
+	if len(regionFilter("ap", false)(services)) != 0 {
+		t.Fatal("Expected no services without nodes in the region")
+	}
+}
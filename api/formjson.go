@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// max size of the fields of a multipart form kept in memory
+	formMaxMemory int64 = 10 << 20
+	// json numbers without leading zeros, so values such as zip codes are
+	// kept as strings
+	formNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+// formKey splits a form field name into its path, e.g user[address][city]
+// into user, address and city, and whether it's a list ending in [].
+// Malformed names are kept whole.
+func formKey(k string) ([]string, bool) {
+	list := len(k) > 2 && strings.HasSuffix(k, "[]")
+	if list {
+		k = k[:len(k)-2]
+	}
+
+	i := strings.IndexByte(k, '[')
+	if i <= 0 {
+		return []string{k}, list
+	}
+
+	path := []string{k[:i]}
+	for rest := k[i:]; len(rest) > 0; {
+		j := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || j < 2 {
+			return []string{k}, list
+		}
+		path = append(path, rest[1:j])
+		rest = rest[j+1:]
+	}
+	return path, list
+}
+
+// formValue converts true and false to booleans and numbers to json
+// numbers, anything else is a string
+func formValue(v string) interface{} {
+	switch {
+	case v == "true":
+		return true
+	case v == "false":
+		return false
+	case formNumber.MatchString(v):
+		return json.Number(v)
+	}
+	return v
+}
+
+// formToJSON converts the form to a json object. Fields with brackets are
+// nested in objects and fields ending in [] or repeated become lists.
+func formToJSON(form url.Values) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	root := make(map[string]interface{})
+	for _, k := range keys {
+		path, list := formKey(k)
+
+		var v interface{}
+		if values := form[k]; list || len(values) > 1 {
+			l := make([]interface{}, 0, len(values))
+			for _, s := range values {
+				l = append(l, formValue(s))
+			}
+			v = l
+		} else {
+			v = formValue(values[0])
+		}
+
+		node := root
+		for i, seg := range path[:len(path)-1] {
+			next, ok := node[seg]
+			if !ok {
+				m := make(map[string]interface{})
+				node[seg] = m
+				node = m
+				continue
+			}
+			m, ok := next.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("form field %s is both a value and an object", strings.Join(path[:i+1], "."))
+			}
+			node = m
+		}
+
+		last := path[len(path)-1]
+		if _, ok := node[last]; ok {
+			return nil, fmt.Errorf("form field %s is set more than once", strings.Join(path, "."))
+		}
+		node[last] = v
+	}
+
+	return root, nil
+}
+
+// formTransformer converts the url encoded and multipart form requests to
+// its paths to json, bridging form clients and json backends. Forms with
+// files can't be converted so they're rejected with a 415.
+type formTransformer struct {
+	// path prefixes of the requests converted
+	paths []string
+}
+
+func newFormTransformer(paths []string) *formTransformer {
+	return &formTransformer{paths: paths}
+}
+
+// matches determines whether requests to the path are converted
+func (f *formTransformer) matches(path string) bool {
+	for _, prefix := range f.paths {
+		if hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *formTransformer) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.matches(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		var form url.Values
+		switch ct {
+		case "application/x-www-form-urlencoded":
+			if err := r.ParseForm(); err != nil {
+				writeError(w, "Invalid form: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			form = r.PostForm
+		case "multipart/form-data":
+			if err := r.ParseMultipartForm(formMaxMemory); err != nil {
+				writeError(w, "Invalid form: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer r.MultipartForm.RemoveAll()
+			if len(r.MultipartForm.File) > 0 {
+				writeError(w, "Forms with files can't be converted to JSON", http.StatusUnsupportedMediaType)
+				return
+			}
+			form = r.MultipartForm.Value
+		default:
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		v, err := formToJSON(form)
+		if err != nil {
+			writeError(w, "Invalid form: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+		r.ContentLength = int64(len(b))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Content-Length", strconv.Itoa(len(b)))
+		// handlers would use the parsed form rather than the body
+		r.Form, r.PostForm, r.MultipartForm = nil, nil, nil
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormToJSON(t *testing.T) {
+	testData := []struct {
+		form   string
+		json   string
+		failed bool
+	}{
+		{"name=john&age=42&admin=true", `{"admin":true,"age":42,"name":"john"}`, false},
+		// leading zeros are kept as strings
+		{"zip=01234&price=-1.5e3", `{"price":-1.5e3,"zip":"01234"}`, false},
+		{"user[name]=john&user[address][city]=london", `{"user":{"address":{"city":"london"},"name":"john"}}`, false},
+		{"tags[]=a&tags[]=1", `{"tags":["a",1]}`, false},
+		{"tags[]=a", `{"tags":["a"]}`, false},
+		{"tag=a&tag=b", `{"tag":["a","b"]}`, false},
+		// malformed names are kept whole
+		{"a[b=1&c]d[=2", `{"a[b":1,"c]d[":2}`, false},
+		{"user=john&user[name]=john", "", true},
+		{"tags=a&tags[]=b", "", true},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("POST", "/form", strings.NewReader(d.form))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		var body string
+		h := newFormTransformer([]string{"/form"}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			body = string(b)
+			if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+				t.Fatalf("Expected content type application/json got %s", ct)
+			}
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if d.failed {
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected %s to be rejected got %d", d.form, w.Code)
+			}
+			continue
+		}
+		if body != d.json {
+			t.Fatalf("Expected %s for %s got %s", d.json, d.form, body)
+		}
+	}
+}
+
+func TestFormTransformer(t *testing.T) {
+	var body string
+	h := newFormTransformer([]string{"/form"}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+
+	// other paths are passed through
+	r := httptest.NewRequest("POST", "/other", strings.NewReader("a=1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if body != "a=1" {
+		t.Fatalf("Expected the body to be passed through got %s", body)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("user[name]", "john")
+	mw.WriteField("age", "42")
+	mw.Close()
+
+	r = httptest.NewRequest("POST", "/form", bytes.NewReader(buf.Bytes()))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if body != `{"age":42,"user":{"name":"john"}}` {
+		t.Fatalf("Expected the multipart form converted got %s", body)
+	}
+
+	// forms with files can't be converted
+	buf.Reset()
+	mw = multipart.NewWriter(&buf)
+	fw, _ := mw.CreateFormFile("avatar", "avatar.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+
+	r = httptest.NewRequest("POST", "/form", bytes.NewReader(buf.Bytes()))
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("Expected a form with files to be rejected with %d got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+}
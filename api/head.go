@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// headWriter records the response to a HEAD request, discarding the body
+type headWriter struct {
+	header http.Header
+	code   int
+	size   int64
+}
+
+func (w *headWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *headWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	w.size += int64(len(b))
+	return len(b), nil
+}
+
+// flush writes the recorded headers, setting the Content-Length from
+// the size of the body discarded when the response didn't set one
+func (w *headWriter) flush(rw http.ResponseWriter, length bool) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	dst := rw.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+
+	if length && len(dst.Get("Content-Length")) == 0 &&
+		w.code != http.StatusNoContent && w.code != http.StatusNotModified {
+		dst.Set("Content-Length", strconv.FormatInt(w.size, 10))
+	}
+
+	rw.WriteHeader(w.code)
+}
+
+// headHandler synthesizes responses to HEAD requests.
+//
+// forward: pass HEAD requests to the backend
+// get: make a GET request to the backend and discard the body
+// auto: forward HEAD requests and fall back to a GET when the backend doesn't implement HEAD
+//
+// Requests to the get paths always make a GET regardless of the mode.
+func headHandler(mode string, getPaths []string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		get := mode == "get"
+		for _, p := range getPaths {
+			if strings.HasPrefix(r.URL.Path, p) {
+				get = true
+				break
+			}
+		}
+
+		if !get {
+			if mode == "forward" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			hw := &headWriter{header: make(http.Header)}
+			h.ServeHTTP(hw, r)
+
+			if hw.code != http.StatusMethodNotAllowed && hw.code != http.StatusNotImplemented {
+				hw.flush(w, false)
+				return
+			}
+		}
+
+		req := r.Clone(r.Context())
+		req.Method = "GET"
+
+		hw := &headWriter{header: make(http.Header)}
+		h.ServeHTTP(hw, req)
+		hw.flush(w, true)
+	})
+}
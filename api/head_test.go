@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeadHandler(t *testing.T) {
+	// backend which doesn't implement HEAD
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hello": "world"}`))
+	})
+
+	testData := []struct {
+		mode     string
+		getPaths []string
+		path     string
+		code     int
+		length   string
+	}{
+		{"forward", nil, "/foo", http.StatusMethodNotAllowed, ""},
+		{"forward", []string{"/foo"}, "/foo", http.StatusOK, "18"},
+		{"get", nil, "/foo", http.StatusOK, "18"},
+		{"auto", nil, "/foo", http.StatusOK, "18"},
+	}
+
+	for _, d := range testData {
+		w := httptest.NewRecorder()
+		headHandler(d.mode, d.getPaths, h).ServeHTTP(w, httptest.NewRequest("HEAD", d.path, nil))
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for mode %s got %d", d.code, d.mode, w.Code)
+		}
+		if w.Body.Len() > 0 && d.code == http.StatusOK {
+			t.Fatalf("Expected no body for mode %s got %s", d.mode, w.Body.String())
+		}
+		if len(d.length) == 0 {
+			continue
+		}
+		if l := w.Header().Get("Content-Length"); l != d.length {
+			t.Fatalf("Expected Content-Length %s for mode %s got %s", d.length, d.mode, l)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("Expected GET headers for mode %s got content type %s", d.mode, ct)
+		}
+	}
+}
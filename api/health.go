@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// how long the readiness check waits for the registry
+var healthTimeout = 3 * time.Second
+
+// health reports the liveness and readiness of the gateway. It's live
+// once the process is up and ready once the service registered, while
+// the registry is reachable, until it starts draining so load balancers
+// stop routing to it.
+type health struct {
+	registry registry.Registry
+	draining func() bool
+	// set once the service registered
+	registered int32
+}
+
+func newHealth(reg registry.Registry, draining func() bool) *health {
+	return &health{
+		registry: reg,
+		draining: draining,
+	}
+}
+
+// setRegistered marks the service registered, called after it started
+func (h *health) setRegistered() {
+	atomic.StoreInt32(&h.registered, 1)
+}
+
+// writeHealth writes the status, with the reason it isn't ready if any
+func writeHealth(w http.ResponseWriter, code int, status, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if len(reason) > 0 {
+		fmt.Fprintf(w, `{"status": "%s", "reason": %q}`, status, reason)
+		return
+	}
+	fmt.Fprintf(w, `{"status": "%s"}`, status)
+}
+
+// Live reports the process is up
+func (h *health) Live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, "ok", "")
+}
+
+// Ready reports whether the gateway can serve requests
+func (h *health) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.draining() {
+		writeHealth(w, http.StatusServiceUnavailable, "draining", "")
+		return
+	}
+	if atomic.LoadInt32(&h.registered) == 0 {
+		writeHealth(w, http.StatusServiceUnavailable, "unavailable", "service not registered")
+		return
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := h.registry.ListServices()
+		errCh <- err
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-time.After(healthTimeout):
+		err = fmt.Errorf("timed out after %v", healthTimeout)
+	}
+	if err != nil {
+		writeHealth(w, http.StatusServiceUnavailable, "unavailable", "registry unavailable: "+err.Error())
+		return
+	}
+
+	writeHealth(w, http.StatusOK, "ready", "")
+}
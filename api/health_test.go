@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-micro/v2/registry/memory"
+)
+
+// failingRegistry can't list services
+type failingRegistry struct {
+	registry.Registry
+}
+
+func (r *failingRegistry) ListServices() ([]*registry.Service, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestHealth(t *testing.T) {
+	var draining bool
+	hc := newHealth(memory.NewRegistry(), func() bool { return draining })
+
+	check := func(fn http.HandlerFunc) (int, map[string]string) {
+		w := httptest.NewRecorder()
+		fn(w, httptest.NewRequest("GET", "/", nil))
+		rsp := make(map[string]string)
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, rsp
+	}
+
+	if code, _ := check(hc.Live); code != http.StatusOK {
+		t.Fatalf("Expected live got %d", code)
+	}
+	if code, rsp := check(hc.Ready); code != http.StatusServiceUnavailable || rsp["reason"] != "service not registered" {
+		t.Fatalf("Expected not ready before registering got %d %v", code, rsp)
+	}
+
+	hc.setRegistered()
+	if code, rsp := check(hc.Ready); code != http.StatusOK || rsp["status"] != "ready" {
+		t.Fatalf("Expected ready got %d %v", code, rsp)
+	}
+
+	hc.registry = &failingRegistry{}
+	if code, rsp := check(hc.Ready); code != http.StatusServiceUnavailable || rsp["reason"] != "registry unavailable: connection refused" {
+		t.Fatalf("Expected not ready without the registry got %d %v", code, rsp)
+	}
+	// still live
+	if code, _ := check(hc.Live); code != http.StatusOK {
+		t.Fatalf("Expected live got %d", code)
+	}
+
+	draining = true
+	if code, rsp := check(hc.Ready); code != http.StatusServiceUnavailable || rsp["status"] != "draining" {
+		t.Fatalf("Expected draining got %d %v", code, rsp)
+	}
+}
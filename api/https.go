@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseCIDRs parses a list of CIDRs or IPs
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("%s is not a valid IP or CIDR", s)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// requireHTTPS rejects requests which didn't arrive over https, redirecting
+// those which can safely be retried. The scheme is taken from the
+// X-Forwarded-Proto header of trusted proxies terminating tls upstream.
+type requireHTTPS struct {
+	// redirect GET and HEAD requests instead of rejecting them
+	redirect bool
+	// proxies whose forwarded headers are trusted
+	trusted []*net.IPNet
+}
+
+func newRequireHTTPS(redirect bool, trusted []*net.IPNet) *requireHTTPS {
+	return &requireHTTPS{
+		redirect: redirect,
+		trusted:  trusted,
+	}
+}
+
+// remoteIP returns the ip of the peer which sent the request
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// containsIP determines whether the ip is in any of the networks
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the ip of the client. The X-Forwarded-For header is
+// walked from the right, where the entries were appended by the proxies
+// in front of the gateway, skipping trusted proxies. The first address
+// which isn't a trusted proxy is the client, entries left of it may be
+// set by the client itself.
+func clientIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	ip := remoteIP(r)
+	if !containsIP(trusted, ip) {
+		return ip
+	}
+
+	var fwd []string
+	for _, v := range r.Header["X-Forwarded-For"] {
+		fwd = append(fwd, strings.Split(v, ",")...)
+	}
+	for i := len(fwd) - 1; i >= 0; i-- {
+		addr := net.ParseIP(strings.TrimSpace(fwd[i]))
+		if addr == nil {
+			// an address we can't parse can't be trusted
+			return nil
+		}
+		ip = addr
+		if !containsIP(trusted, ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// trustedProxy determines whether the request came from a trusted proxy
+func (p *requireHTTPS) trustedProxy(r *http.Request) bool {
+	return containsIP(p.trusted, remoteIP(r))
+}
+
+// scheme returns the scheme the client used to make the request
+func (p *requireHTTPS) scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) > 0 && p.trustedProxy(r) {
+		// the last entry is set by the trusted proxy in front of the
+		// gateway, the ones before it could be sent by the client
+		parts := strings.Split(proto, ",")
+		return strings.ToLower(strings.TrimSpace(parts[len(parts)-1]))
+	}
+	return "http"
+}
+
+func (p *requireHTTPS) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p.scheme(r) == "https" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// requests with bodies have already been sent in the clear
+		if p.redirect && (r.Method == "GET" || r.Method == "HEAD") {
+			http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+
+		writeError(w, "HTTPS required", http.StatusForbidden)
+	})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	trusted, err := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newRequireHTTPS(true, trusted).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	testData := []struct {
+		method    string
+		remote    string
+		forwarded string
+		tls       bool
+		code      int
+	}{
+		{"GET", "1.2.3.4:1234", "", true, http.StatusOK},
+		{"GET", "10.1.2.3:1234", "https", false, http.StatusOK},
+		{"GET", "192.168.1.1:1234", "http, https", false, http.StatusOK},
+		// only the entry set by the trusted proxy counts
+		{"GET", "192.168.1.1:1234", "https, http", false, http.StatusMovedPermanently},
+		{"GET", "1.2.3.4:1234", "https", false, http.StatusMovedPermanently},
+		{"GET", "10.1.2.3:1234", "http", false, http.StatusMovedPermanently},
+		{"GET", "1.2.3.4:1234", "", false, http.StatusMovedPermanently},
+		{"POST", "1.2.3.4:1234", "", false, http.StatusForbidden},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest(d.method, "http://example.com/foo?bar=baz", nil)
+		r.RemoteAddr = d.remote
+		r.TLS = nil
+		if d.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		if len(d.forwarded) > 0 {
+			r.Header.Set("X-Forwarded-Proto", d.forwarded)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %s from %s forwarded %q got %d", d.code, d.method, d.remote, d.forwarded, w.Code)
+		}
+		if d.code == http.StatusMovedPermanently {
+			if loc := w.Header().Get("Location"); loc != "https://example.com/foo?bar=baz" {
+				t.Fatalf("Expected redirect to https got %s", loc)
+			}
+		}
+	}
+
+	if _, err := parseCIDRs([]string{"not an ip"}); err == nil {
+		t.Fatal("Expected error parsing invalid CIDR")
+	}
+}
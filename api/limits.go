@@ -0,0 +1,148 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+)
+
+var (
+	// metadata key of the max request body size in bytes a backend accepts
+	maxBodySizeKey = "max_body_size"
+	// metadata key of the requests per second a backend accepts
+	rateLimitKey = "rate_limit"
+)
+
+// bucket is a token bucket refilled at the rate per second
+type bucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+	// max number of tokens, a second of requests if 0
+	size float64
+}
+
+// burst returns the max number of tokens, a second of requests by default
+// but at least one so rates below one per second still allow requests
+func (b *bucket) burst() float64 {
+	if b.size > 0 {
+		return b.size
+	}
+	return math.Max(b.rate, 1)
+}
+
+func (b *bucket) take(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if burst := b.burst(); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// reset returns the time until the bucket is full again
+func (b *bucket) reset() time.Duration {
+	return time.Duration((b.burst() - b.tokens) / b.rate * float64(time.Second))
+}
+
+// backendLimits rejects requests exceeding the limits a backend advertises
+// in its registry metadata before they're forwarded to it. Responses of
+// rate limited services carry the limit, the requests remaining and the
+// seconds until the quota is reset so clients can throttle themselves.
+type backendLimits struct {
+	registry  registry.Registry
+	namespace string
+	// names of the limit, remaining and reset headers
+	headers []string
+
+	sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newBackendLimits(reg registry.Registry, namespace string, headers []string) *backendLimits {
+	return &backendLimits{
+		registry:  reg,
+		namespace: namespace,
+		headers:   headers,
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// metadata returns the metadata of the service the request resolved to
+func (l *backendLimits) metadata(name string) map[string]string {
+	for _, n := range []string{name, l.namespace + "." + name} {
+		services, err := l.registry.GetService(n)
+		if err != nil || len(services) == 0 {
+			continue
+		}
+		return services[0].Metadata
+	}
+	return nil
+}
+
+// allow takes a token from the service bucket at the given rate, returning
+// the tokens remaining and the time until the bucket is full again
+func (l *backendLimits) allow(name string, rate float64) (bool, int, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[name]
+	if !ok || b.rate != rate {
+		b = &bucket{rate: rate, last: now}
+		b.tokens = b.burst()
+		l.buckets[name] = b
+	}
+	allowed := b.take(now)
+	return allowed, int(b.tokens), b.reset()
+}
+
+func (l *backendLimits) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := service(r)
+		if len(name) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		md := l.metadata(name)
+		if md == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if max, err := strconv.ParseInt(md[maxBodySizeKey], 10, 64); err == nil && max > 0 {
+			if r.ContentLength > max {
+				writeError(w, "Request entity too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			// enforce the limit on bodies of unknown length as they're read
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+		}
+
+		if rate, err := strconv.ParseFloat(md[rateLimitKey], 64); err == nil && rate > 0 {
+			ok, remaining, reset := l.allow(name, rate)
+			if len(l.headers) == 3 {
+				w.Header().Set(l.headers[0], strconv.FormatFloat(rate, 'f', -1, 64))
+				w.Header().Set(l.headers[1], strconv.Itoa(remaining))
+				w.Header().Set(l.headers[2], strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+			}
+			if !ok {
+				w.Header().Set("Retry-After", "1")
+				writeError(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
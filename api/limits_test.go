@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// testRegistry returns the services it holds
+type testRegistry struct {
+	registry.Registry
+	services map[string][]*registry.Service
+}
+
+func (r *testRegistry) GetService(name string) ([]*registry.Service, error) {
+	services, ok := r.services[name]
+	if !ok {
+		return nil, registry.ErrNotFound
+	}
+	return services, nil
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	reg := &testRegistry{services: map[string][]*registry.Service{
+		"go.micro.api.greeter": {{
+			Name:     "go.micro.api.greeter",
+			Metadata: map[string]string{rateLimitKey: "2"},
+		}},
+	}}
+
+	headers := []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"}
+	h := newBackendLimits(reg, "go.micro.api", headers).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(service string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		ep := &resolver.Endpoint{Name: service}
+		r = r.WithContext(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	testData := []struct {
+		code      int
+		remaining string
+	}{
+		{http.StatusOK, "1"},
+		{http.StatusOK, "0"},
+		{http.StatusTooManyRequests, "0"},
+	}
+
+	for _, d := range testData {
+		w := request("greeter")
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d got %d", d.code, w.Code)
+		}
+		if v := w.Header().Get("RateLimit-Limit"); v != "2" {
+			t.Fatalf("Expected limit 2 got %s", v)
+		}
+		if v := w.Header().Get("RateLimit-Remaining"); v != d.remaining {
+			t.Fatalf("Expected %s remaining got %s", d.remaining, v)
+		}
+		if v := w.Header().Get("RateLimit-Reset"); v != "1" {
+			t.Fatalf("Expected reset in 1s got %s", v)
+		}
+	}
+
+	// services without a rate limit don't get the headers
+	if w := request("other"); len(w.Header().Get("RateLimit-Limit")) > 0 {
+		t.Fatal("Expected no rate limit headers for a service without a rate limit")
+	}
+}
+
+func TestBucketBurst(t *testing.T) {
+	now := time.Now()
+
+	testData := []struct {
+		rate    float64
+		allowed int
+	}{
+		{5, 5},
+		// rates below one per second still allow a request
+		{0.5, 1},
+	}
+
+	for _, d := range testData {
+		b := &bucket{rate: d.rate, last: now}
+		b.tokens = b.burst()
+
+		var allowed int
+		for i := 0; i < 10; i++ {
+			if b.take(now) {
+				allowed++
+			}
+		}
+		if allowed != d.allowed {
+			t.Fatalf("Expected %d requests allowed at rate %v got %d", d.allowed, d.rate, allowed)
+		}
+	}
+
+	// a request is allowed again once a token is refilled
+	b := &bucket{rate: 0.5, tokens: 0, last: now}
+	if b.take(now.Add(time.Second)) {
+		t.Fatal("Expected no token after a second at rate 0.5")
+	}
+	if !b.take(now.Add(2 * time.Second)) {
+		t.Fatal("Expected a token after two seconds at rate 0.5")
+	}
+}
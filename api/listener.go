@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// sockListener applies socket options to every accepted tcp connection
+type sockListener struct {
+	net.Listener
+
+	noDelay     bool
+	readBuffer  int
+	writeBuffer int
+	// keep connections alive like the acme listeners do
+	keepAlive bool
+}
+
+func (l *sockListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		return c, nil
+	}
+
+	// socket options are best effort, a failure shouldn't stop
+	// the server from accepting connections
+	if err := tc.SetNoDelay(l.noDelay); err != nil {
+		log.Debugf("Failed to set TCP_NODELAY: %v", err)
+	}
+	if l.readBuffer > 0 {
+		if err := tc.SetReadBuffer(l.readBuffer); err != nil {
+			log.Debugf("Failed to set read buffer: %v", err)
+		}
+	}
+	if l.writeBuffer > 0 {
+		if err := tc.SetWriteBuffer(l.writeBuffer); err != nil {
+			log.Debugf("Failed to set write buffer: %v", err)
+		}
+	}
+	if l.keepAlive {
+		tc.SetKeepAlive(true)
+		tc.SetKeepAlivePeriod(3 * time.Minute)
+	}
+
+	return tc, nil
+}
+
+// wrapListener wraps the listener so accepted connections get the
+// configured socket options
+func (s *httpServer) wrapListener(l net.Listener, keepAlive bool) net.Listener {
+	s.RLock()
+	defer s.RUnlock()
+
+	return &sockListener{
+		Listener:    l,
+		noDelay:     s.noDelay,
+		readBuffer:  s.readBuffer,
+		writeBuffer: s.writeBuffer,
+		keepAlive:   keepAlive,
+	}
+}
+
+// unixPrefix marks the address of a unix socket e.g unix:///var/run/micro.sock
+const unixPrefix = "unix://"
+
+// listenUnix listens on the unix socket at the path, first removing a stale
+// socket nothing accepts on, e.g left by a server that crashed. The socket
+// is removed again once the listener is closed.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("unix socket %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestSockListener(t *testing.T) {
+	s := newServer("127.0.0.1:0")
+	s.Configure(withNoDelay(false), withReadBuffer(1<<16))
+
+	tl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := s.wrapListener(tl, true)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc, ok := c.(*net.TCPConn)
+	if !ok {
+		t.Fatalf("Expected a tcp connection got %T", c)
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var noDelay, keepAlive, readBuffer int
+	raw.Control(func(fd uintptr) {
+		noDelay, _ = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_NODELAY)
+		keepAlive, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)
+		readBuffer, _ = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+	})
+
+	if noDelay != 0 {
+		t.Fatal("Expected TCP_NODELAY to be disabled")
+	}
+	if keepAlive == 0 {
+		t.Fatal("Expected keep alives to be enabled")
+	}
+	if readBuffer < 1<<16 {
+		t.Fatalf("Expected read buffer of at least %d got %d", 1<<16, readBuffer)
+	}
+}
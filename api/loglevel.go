@@ -0,0 +1,137 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// header carrying the token required by admin endpoints
+var adminTokenHeader = "X-Micro-Admin-Token"
+
+// adminHandler only serves requests carrying the admin token
+func adminHandler(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(adminTokenHeader)), []byte(token)) != 1 {
+			writeError(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// logLevel changes the level of the logger at runtime, optionally
+// reverting it after a duration e.g to debug an incident. Changes are
+// serialised and every change starts a new generation so a revert
+// scheduled by an earlier change never overrides a later one.
+type logLevel struct {
+	// token required to change the level
+	token string
+
+	sync.Mutex
+	timer *time.Timer
+	// incremented by every change
+	gen uint64
+	// level reverted to once the timer fires
+	original log.Level
+}
+
+func newLogLevel(token string) *logLevel {
+	return &logLevel{token: token}
+}
+
+// set changes the level, reverting after d if it's non zero
+func (l *logLevel) set(level log.Level, d time.Duration) error {
+	l.Lock()
+	defer l.Unlock()
+
+	// a pending revert goes back to the level before the first change
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	} else {
+		l.original = log.DefaultLogger.Options().Level
+	}
+	l.gen++
+
+	if err := log.Init(log.WithLevel(level)); err != nil {
+		return err
+	}
+
+	if d > 0 {
+		gen, original := l.gen, l.original
+		l.timer = time.AfterFunc(d, func() {
+			l.revert(gen, original)
+		})
+	}
+
+	return nil
+}
+
+// revert goes back to the original level unless the level changed since
+// the revert was scheduled, the timer may fire while a change is made
+func (l *logLevel) revert(gen uint64, original log.Level) {
+	l.Lock()
+	defer l.Unlock()
+	if gen != l.gen {
+		return
+	}
+	l.timer = nil
+	log.Init(log.WithLevel(original))
+	log.Infof("Log level reverted to %s", original)
+}
+
+// level returns the current level, synchronised with pending reverts
+func (l *logLevel) level() log.Level {
+	l.Lock()
+	defer l.Unlock()
+	return log.DefaultLogger.Options().Level
+}
+
+func (l *logLevel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(adminTokenHeader)), []byte(l.token)) != 1 {
+		writeError(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "PUT":
+		level, err := log.GetLevel(r.FormValue("level"))
+		if err != nil {
+			writeError(w, fmt.Sprintf("%s is not a valid log level", r.FormValue("level")), http.StatusBadRequest)
+			return
+		}
+
+		var d time.Duration
+		if v := r.FormValue("duration"); len(v) > 0 {
+			d, err = time.ParseDuration(v)
+			if err != nil || d < 0 {
+				writeError(w, fmt.Sprintf("%s is not a valid duration", v), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := l.set(level, d); err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if d > 0 {
+			log.Infof("Log level set to %s for %v", level, d)
+		} else {
+			log.Infof("Log level set to %s", level)
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"level": "%s"}`, l.level())
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+func TestLogLevel(t *testing.T) {
+	defer log.Init(log.WithLevel(log.InfoLevel))
+	log.Init(log.WithLevel(log.InfoLevel))
+
+	l := newLogLevel("secret")
+
+	testData := []struct {
+		token string
+		query string
+		code  int
+		level log.Level
+	}{
+		{"", "level=debug", http.StatusForbidden, log.InfoLevel},
+		{"secret", "level=verbose", http.StatusBadRequest, log.InfoLevel},
+		{"secret", "level=debug&duration=-1s", http.StatusBadRequest, log.InfoLevel},
+		{"secret", "level=warn", http.StatusOK, log.WarnLevel},
+		{"secret", "level=debug&duration=10ms", http.StatusOK, log.DebugLevel},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("PUT", "/_loglevel?"+d.query, nil)
+		r.Header.Set(adminTokenHeader, d.token)
+		w := httptest.NewRecorder()
+		l.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %s got %d", d.code, d.query, w.Code)
+		}
+		if level := l.level(); level != d.level {
+			t.Fatalf("Expected level %s for %s got %s", d.level, d.query, level)
+		}
+	}
+
+	// the temporary debug level reverts to the previous level
+	time.Sleep(time.Millisecond * 50)
+	if level := l.level(); level != log.WarnLevel {
+		t.Fatalf("Expected level to revert to warn got %s", level)
+	}
+}
+
+func TestLogLevelStaleRevert(t *testing.T) {
+	defer log.Init(log.WithLevel(log.InfoLevel))
+	log.Init(log.WithLevel(log.InfoLevel))
+
+	l := newLogLevel("secret")
+	if err := l.set(log.DebugLevel, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	stale := l.gen
+
+	// the level is changed as the revert of the first change fires
+	if err := l.set(log.WarnLevel, 0); err != nil {
+		t.Fatal(err)
+	}
+	l.revert(stale, log.InfoLevel)
+
+	if level := l.level(); level != log.WarnLevel {
+		t.Fatalf("Expected the stale revert to be ignored got %s", level)
+	}
+}
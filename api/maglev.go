@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+var (
+	// header the gateway passes the affinity key of a request to client
+	// wrappers and the proxy in
+	affinityHeader = "Micro-Api-Affinity-Key"
+	// slots of the maglev lookup table, a prime much larger than the
+	// number of nodes so keys spread evenly
+	maglevTableSize uint64 = 65537
+	// lookup tables kept, dropped all at once when over
+	maglevMaxTables = 256
+)
+
+// maglevTable maps keys to nodes with maglev hashing, each node filling
+// the slots in its own permutation of the table in turn. Adding or removing
+// a node only moves the keys of few slots to other nodes.
+type maglevTable struct {
+	nodes []*registry.Node
+	slots []int
+}
+
+func maglevHash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// maglevSkip hashes the node id with another function than maglevHash so
+// the offset and skip of its permutation are independent
+func maglevSkip(s string) uint64 {
+	h := fnv.New64()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+func newMaglevTable(nodes []*registry.Node) *maglevTable {
+	size := maglevTableSize
+	offsets := make([]uint64, len(nodes))
+	skips := make([]uint64, len(nodes))
+	next := make([]uint64, len(nodes))
+	for i, n := range nodes {
+		offsets[i] = maglevHash(n.Id) % size
+		skips[i] = maglevSkip(n.Id)%(size-1) + 1
+	}
+
+	slots := make([]int, size)
+	for i := range slots {
+		slots[i] = -1
+	}
+	for filled := uint64(0); filled < size; {
+		for i := range nodes {
+			c := (offsets[i] + next[i]*skips[i]) % size
+			for slots[c] >= 0 {
+				next[i]++
+				c = (offsets[i] + next[i]*skips[i]) % size
+			}
+			slots[c] = i
+			next[i]++
+			filled++
+			if filled == size {
+				break
+			}
+		}
+	}
+
+	return &maglevTable{nodes: nodes, slots: slots}
+}
+
+// next returns the selector walking the table from the slot of the key,
+// so retries go to the other nodes in the order the key would move to them
+func (t *maglevTable) next(key string) selector.Next {
+	slot := maglevHash(key) % maglevTableSize
+	tried := make(map[int]bool)
+	return func() (*registry.Node, error) {
+		if len(tried) == len(t.nodes) {
+			tried = make(map[int]bool)
+		}
+		for tried[t.slots[slot]] {
+			slot = (slot + 1) % maglevTableSize
+		}
+		i := t.slots[slot]
+		tried[i] = true
+		return t.nodes[i], nil
+	}
+}
+
+// affinityKey is where the key requests are hashed by is read from
+type affinityKey struct {
+	// header or query
+	source string
+	name   string
+}
+
+// parseAffinityKey parses the key in the format header:name or query:name
+func parseAffinityKey(s string) (affinityKey, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 || (parts[0] != "header" && parts[0] != "query") {
+		return affinityKey{}, fmt.Errorf("%s is not a valid affinity key, expected header:name or query:name", s)
+	}
+	return affinityKey{source: parts[0], name: parts[1]}, nil
+}
+
+// affinity sends requests with the same key to the same backend node with
+// consistent hashing, improving the hit rate of backend caches. Requests
+// without a key are sent to a random node.
+type affinity struct {
+	key affinityKey
+
+	sync.Mutex
+	// lookup tables by the ids of their nodes, so tables of the nodes
+	// left by filters e.g of zones are kept too
+	tables map[string]*maglevTable
+}
+
+func newAffinity(key affinityKey) *affinity {
+	return &affinity{
+		key:    key,
+		tables: make(map[string]*maglevTable),
+	}
+}
+
+// table returns the lookup table of the nodes of the services, nil if
+// there are none
+func (a *affinity) table(services []*registry.Service) *maglevTable {
+	var nodes []*registry.Node
+	for _, s := range services {
+		nodes = append(nodes, s.Nodes...)
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Id < nodes[j].Id })
+
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.Id
+	}
+	key := strings.Join(ids, ",")
+
+	a.Lock()
+	defer a.Unlock()
+	t, ok := a.tables[key]
+	if !ok {
+		if len(a.tables) >= maglevMaxTables {
+			a.tables = make(map[string]*maglevTable)
+		}
+		t = newMaglevTable(nodes)
+		a.tables[key] = t
+	}
+	return t
+}
+
+// Strategy returns the selector strategy hashing the key
+func (a *affinity) Strategy(key string) selector.Strategy {
+	return func(services []*registry.Service) selector.Next {
+		t := a.table(services)
+		if t == nil {
+			return selector.Random(services)
+		}
+		return t.next(key)
+	}
+}
+
+// strategy returns the selector strategy of the request for the proxy
+func (a *affinity) strategy(r *http.Request) selector.Strategy {
+	if key := r.Header.Get(affinityHeader); len(key) > 0 {
+		return a.Strategy(key)
+	}
+	return selector.Random
+}
+
+// randomStrategy selects a random node for every request
+func randomStrategy(*http.Request) selector.Strategy {
+	return selector.Random
+}
+
+// Handler passes the affinity key of the request to the client wrappers
+// and the proxy, replacing any value sent by the client
+func (a *affinity) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var key string
+		switch a.key.source {
+		case "header":
+			key = r.Header.Get(a.key.name)
+		case "query":
+			key = r.URL.Query().Get(a.key.name)
+		}
+		if len(key) > 0 {
+			r.Header.Set(affinityHeader, key)
+		} else {
+			r.Header.Del(affinityHeader)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// affinityClient selects backends by the affinity key of the request
+type affinityClient struct {
+	client.Client
+	affinity *affinity
+}
+
+// withAffinity returns the call options to select a backend by the key
+func (a *affinityClient) withAffinity(ctx context.Context, opts []client.CallOption) []client.CallOption {
+	key, ok := metadata.Get(ctx, affinityHeader)
+	if !ok || len(key) == 0 {
+		return opts
+	}
+	strategy := selector.WithStrategy(a.affinity.Strategy(key))
+	return append(opts[:len(opts):len(opts)], client.WithSelectOption(strategy))
+}
+
+func (a *affinityClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return a.Client.Call(ctx, req, rsp, a.withAffinity(ctx, opts)...)
+}
+
+func (a *affinityClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	return a.Client.Stream(ctx, req, a.withAffinity(ctx, opts)...)
+}
+
+// affinityWrapper returns a client wrapper selecting backends by affinity
+func affinityWrapper(a *affinity) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &affinityClient{Client: c, affinity: a}
+	}
+}
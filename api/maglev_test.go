@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/registry"
+)
+
+func testNodes(n int) []*registry.Node {
+	nodes := make([]*registry.Node, n)
+	for i := range nodes {
+		nodes[i] = &registry.Node{Id: fmt.Sprintf("greeter-%d", i)}
+	}
+	return nodes
+}
+
+func TestMaglevTable(t *testing.T) {
+	a := newAffinity(affinityKey{source: "header", name: "X-User-Id"})
+	services := []*registry.Service{{Name: "go.micro.api.greeter", Nodes: testNodes(4)}}
+
+	// keys spread evenly over the nodes
+	slots := make(map[int]int)
+	for _, i := range a.table(services).slots {
+		slots[i]++
+	}
+	for i, n := range slots {
+		if share := float64(n) / float64(maglevTableSize); share < 0.24 || share > 0.26 {
+			t.Fatalf("Expected node %d to get a quarter of the slots got %v", i, share)
+		}
+	}
+
+	owners := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		node, _ := a.Strategy(key)(services)()
+		again, _ := a.Strategy(key)(services)()
+		if node.Id != again.Id {
+			t.Fatalf("Expected %s to hash to the same node got %s and %s", key, node.Id, again.Id)
+		}
+		owners[key] = node.Id
+	}
+
+	// removing a node only moves its keys
+	removed := []*registry.Service{{Name: "go.micro.api.greeter", Nodes: testNodes(3)}}
+	var moved int
+	for key, owner := range owners {
+		node, _ := a.Strategy(key)(removed)()
+		if owner != "greeter-3" && node.Id != owner {
+			moved++
+		}
+	}
+	if moved > 30 {
+		t.Fatalf("Expected few keys of the remaining nodes to move got %d", moved)
+	}
+
+	// retries go to the other nodes
+	next := a.Strategy("user-1")(services)
+	seen := make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		node, _ := next()
+		seen[node.Id] = true
+	}
+	if len(seen) != 4 {
+		t.Fatalf("Expected retries to try every node got %v", seen)
+	}
+
+	// services without nodes have none available
+	if _, err := a.Strategy("user-1")(nil)(); err == nil {
+		t.Fatal("Expected no node to be available")
+	}
+}
+
+func TestAffinityHandler(t *testing.T) {
+	testData := []struct {
+		key    string
+		url    string
+		header string
+		want   string
+	}{
+		{"header:X-User-Id", "/greeter", "1", "1"},
+		{"query:user", "/greeter?user=2", "", "2"},
+		// values sent by the client are replaced
+		{"query:user", "/greeter", "", ""},
+	}
+
+	for _, d := range testData {
+		key, err := parseAffinityKey(d.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		h := newAffinity(key).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header.Get(affinityHeader)
+		}))
+
+		r := httptest.NewRequest("GET", d.url, nil)
+		r.Header.Set(affinityHeader, "forged")
+		if len(d.header) > 0 {
+			r.Header.Set("X-User-Id", d.header)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if got != d.want {
+			t.Fatalf("Expected affinity key %q for %s got %q", d.want, d.key, got)
+		}
+	}
+
+	for _, key := range []string{"X-User-Id", "cookie:user", "header:"} {
+		if _, err := parseAffinityKey(key); err == nil {
+			t.Fatalf("Expected %s to be rejected", key)
+		}
+	}
+}
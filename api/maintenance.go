@@ -0,0 +1,54 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+)
+
+// header carrying the api key of clients allowed through maintenance
+var maintenanceKeyHeader = "X-Api-Key"
+
+// maintenance rejects requests with a 503 while the backends are being
+// deployed, letting through the clients on the allowlist so the deploy
+// can be tested before it's opened to everyone
+type maintenance struct {
+	// networks of the clients let through
+	allowed []*net.IPNet
+	// api keys of the clients let through
+	keys []string
+	// proxies whose X-Forwarded-For header is trusted
+	trusted []*net.IPNet
+}
+
+func newMaintenance(allowed []*net.IPNet, keys []string, trusted []*net.IPNet) *maintenance {
+	return &maintenance{
+		allowed: allowed,
+		keys:    keys,
+		trusted: trusted,
+	}
+}
+
+// allow determines whether the request is from a client on the allowlist
+func (m *maintenance) allow(r *http.Request) bool {
+	if key := r.Header.Get(maintenanceKeyHeader); len(key) > 0 {
+		for _, k := range m.keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				return true
+			}
+		}
+	}
+	return containsIP(m.allowed, clientIP(r, m.trusted))
+}
+
+func (m *maintenance) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.allow(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", "60")
+		writeError(w, "Service unavailable for maintenance", http.StatusServiceUnavailable)
+	})
+}
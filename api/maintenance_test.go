@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMaintenance(t *testing.T) {
+	allowed, err := parseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted, err := parseCIDRs([]string{"192.168.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newMaintenance(allowed, []string{"deploy-key"}, trusted).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	testData := []struct {
+		remote  string
+		forward string
+		key     string
+		code    int
+	}{
+		// everyone else gets the 503
+		{"1.2.3.4:1234", "", "", http.StatusServiceUnavailable},
+		{"1.2.3.4:1234", "", "wrong-key", http.StatusServiceUnavailable},
+		// clients on the allowlist get through
+		{"10.1.2.3:1234", "", "", http.StatusOK},
+		{"1.2.3.4:1234", "", "deploy-key", http.StatusOK},
+		// forwarded addresses are only trusted from trusted proxies
+		{"192.168.0.1:1234", "10.1.2.3", "", http.StatusOK},
+		{"1.2.3.4:1234", "10.1.2.3", "", http.StatusServiceUnavailable},
+		// the client can prepend any address, the proxy appends the real one
+		{"192.168.0.1:1234", "10.1.2.3, 1.2.3.4", "", http.StatusServiceUnavailable},
+		// trusted proxies in the chain are skipped
+		{"192.168.0.1:1234", "1.2.3.4, 10.1.2.3, 192.168.0.1", "", http.StatusOK},
+		{"192.168.0.1:1234", "10.1.2.3, not-an-ip", "", http.StatusServiceUnavailable},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("GET", "/greeter", nil)
+		r.RemoteAddr = d.remote
+		if len(d.forward) > 0 {
+			r.Header.Set("X-Forwarded-For", d.forward)
+		}
+		if len(d.key) > 0 {
+			r.Header.Set(maintenanceKeyHeader, d.key)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected %d for %s forwarding %q with key %q got %d", d.code, d.remote, d.forward, d.key, w.Code)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/micro/go-micro/v2/debug/trace"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics exports the requests served in the prometheus format, along with
+// the connections made to backends by requests proxied over http so the
+// time spent connecting can be told apart from the time backends take.
+// Request durations can carry the trace of the request as an exemplar,
+// only exposed in the OpenMetrics format.
+type metrics struct {
+	// observe durations with the trace id as exemplar
+	exemplars bool
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inflight prometheus.Gauge
+	// backend connections by whether they were reused
+	conns    *prometheus.CounterVec
+	connects prometheus.Histogram
+}
+
+func newMetrics(reg prometheus.Registerer, pool *proxyBufferPool, dialer *backendDialer, exemplars bool) *metrics {
+	m := &metrics{
+		exemplars: exemplars,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "requests_total",
+			Help:      "Requests served by service and status code",
+		}, []string{"service", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to serve requests by service",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service"}),
+		inflight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "requests_in_flight",
+			Help:      "Requests being served",
+		}),
+		conns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "backend_connections_total",
+			Help:      "Connections used by requests proxied to backends by whether they were reused",
+		}, []string{"reused"}),
+		connects: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "backend_connect_duration_seconds",
+			Help:      "Time taken to establish new connections to backends including dns and tls",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+	reg.MustRegister(m.requests, m.duration, m.inflight, m.conns, m.connects)
+
+	// the buffers allocated out of those taken from the pool
+	if pool != nil {
+		reg.MustRegister(
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Namespace: "micro",
+				Subsystem: "api",
+				Name:      "proxy_buffer_gets_total",
+				Help:      "Buffers taken from the pool to copy proxied bodies",
+			}, func() float64 { return float64(atomic.LoadUint64(&pool.gets)) }),
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Namespace: "micro",
+				Subsystem: "api",
+				Name:      "proxy_buffer_allocs_total",
+				Help:      "Buffers allocated as the pool had none to reuse",
+			}, func() float64 { return float64(atomic.LoadUint64(&pool.allocs)) }),
+		)
+	}
+
+	// the connections to backends which couldn't be established in time
+	if dialer != nil {
+		reg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "micro",
+			Subsystem: "api",
+			Name:      "backend_dial_timeouts_total",
+			Help:      "Connections to backends which timed out before they were established",
+		}, func() float64 { return float64(dialer.Timeouts()) }))
+	}
+	return m
+}
+
+// trace records the connections the request makes to backends, called
+// by the transport of the requests proxied over http
+func (m *metrics) trace() *httptrace.ClientTrace {
+	var start time.Time
+	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			start = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			m.conns.WithLabelValues(strconv.FormatBool(info.Reused)).Inc()
+			if !info.Reused {
+				m.connects.Observe(time.Since(start).Seconds())
+			}
+		},
+	}
+}
+
+// statusRecorder keeps the status code of the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// exemplar returns the exemplar labels of the trace of the request, nil if
+// it isn't traced. Exemplar labels are limited to 128 characters in all.
+func exemplar(r *http.Request) prometheus.Labels {
+	traceID, _, ok := trace.FromContext(r.Context())
+	if !ok || len(traceID) == 0 || utf8.RuneCountInString(traceID) > 64 {
+		return nil
+	}
+	return prometheus.Labels{"trace_id": traceID}
+}
+
+func (m *metrics) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inflight.Inc()
+		defer m.inflight.Dec()
+
+		// the tracer starts the trace before the request gets here
+		var ex prometheus.Labels
+		if m.exemplars {
+			ex = exemplar(r)
+		}
+
+		start := time.Now()
+		mw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		// the proxy passes the context on to the backend request
+		*r = *r.WithContext(httptrace.WithClientTrace(r.Context(), m.trace()))
+		h.ServeHTTP(mw, r)
+
+		// the service is only known once the request is resolved
+		name := service(r)
+		if len(name) == 0 {
+			name = "unresolved"
+		}
+		m.requests.WithLabelValues(name, strconv.Itoa(mw.status)).Inc()
+		d := m.duration.WithLabelValues(name)
+		if eo, ok := d.(prometheus.ExemplarObserver); ok && ex != nil {
+			eo.ObserveWithExemplar(time.Since(start).Seconds(), ex)
+		} else {
+			d.Observe(time.Since(start).Seconds())
+		}
+	})
+}
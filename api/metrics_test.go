@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/debug/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics(prometheus.NewRegistry(), nil, nil, false)
+
+	var inflight float64
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inflight = testutil.ToFloat64(m.inflight)
+		if r.URL.Path == "/foo" {
+			// resolved as the auth wrapper does
+			ep := &resolver.Endpoint{Name: "go.micro.api.foo"}
+			*r = *r.Clone(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/favicon.ico", nil))
+
+	if inflight != 1 {
+		t.Fatalf("Expected 1 request in flight while serving got %v", inflight)
+	}
+	if n := testutil.ToFloat64(m.inflight); n != 0 {
+		t.Fatalf("Expected no requests in flight after serving got %v", n)
+	}
+	if n := testutil.ToFloat64(m.requests.WithLabelValues("go.micro.api.foo", "404")); n != 1 {
+		t.Fatalf("Expected 1 request to go.micro.api.foo got %v", n)
+	}
+	if n := testutil.ToFloat64(m.requests.WithLabelValues("unresolved", "200")); n != 1 {
+		t.Fatalf("Expected 1 unresolved request got %v", n)
+	}
+}
+
+func TestMetricsBackendConnections(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := newMetrics(prometheus.NewRegistry(), nil, nil, false)
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.Transport = &http.Transport{}
+	h := m.Handler(proxy)
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 got %d", w.Code)
+		}
+	}
+
+	// the first request connects, the others reuse the connection
+	if n := testutil.ToFloat64(m.conns.WithLabelValues("false")); n != 1 {
+		t.Fatalf("Expected 1 new connection got %v", n)
+	}
+	if n := testutil.ToFloat64(m.conns.WithLabelValues("true")); n != 2 {
+		t.Fatalf("Expected 2 reused connections got %v", n)
+	}
+}
+
+func TestMetricsExemplar(t *testing.T) {
+	r := httptest.NewRequest("GET", "/foo", nil)
+	if ex := exemplar(r); ex != nil {
+		t.Fatalf("Expected no exemplar for an untraced request got %v", ex)
+	}
+
+	r = r.WithContext(trace.ToContext(r.Context(), "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"))
+	if ex := exemplar(r); ex["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("Expected the trace id as exemplar got %v", ex)
+	}
+
+	// exemplars over the length limit would panic
+	r = r.WithContext(trace.ToContext(r.Context(), strings.Repeat("a", 65), "00f067aa0ba902b7"))
+	if ex := exemplar(r); ex != nil {
+		t.Fatalf("Expected no exemplar for a long trace id got %v", ex)
+	}
+}
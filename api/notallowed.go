@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-micro/v2/api"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// methodChecker rejects requests with a 405 when the path matches an api
+// endpoint the resolved service registered but none of them accept the
+// method, listing the methods which are in the Allow header. Without it the
+// request falls through to the resolver and fails as any other call would.
+// Only the endpoints of the resolved service are checked.
+type methodChecker struct {
+	registry  registry.Registry
+	namespace string
+	// message of the error returned
+	msg string
+
+	sync.Mutex
+	// compiled endpoint paths
+	paths map[string]*regexp.Regexp
+}
+
+func newMethodChecker(reg registry.Registry, namespace, msg string) *methodChecker {
+	return &methodChecker{
+		registry:  reg,
+		namespace: namespace,
+		msg:       msg,
+		paths:     make(map[string]*regexp.Regexp),
+	}
+}
+
+// match determines whether the request matches the path of the endpoint,
+// the same as the registry router
+func (c *methodChecker) match(ep *api.Endpoint, r *http.Request) bool {
+	if len(ep.Host) > 0 {
+		var host bool
+		for _, h := range ep.Host {
+			if r.Host == h {
+				host = true
+				break
+			}
+		}
+		if !host {
+			return false
+		}
+	}
+
+	for _, p := range ep.Path {
+		c.Lock()
+		re, ok := c.paths[p]
+		if !ok {
+			re, _ = regexp.CompilePOSIX(p)
+			c.paths[p] = re
+		}
+		c.Unlock()
+		if re != nil && re.MatchString(r.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed returns the methods of the endpoints of the service matching the
+// request, and whether any of them accept its method
+func (c *methodChecker) allowed(name string, r *http.Request) ([]string, bool) {
+	methods := make(map[string]bool)
+	for _, n := range []string{name, c.namespace + "." + name} {
+		services, err := c.registry.GetService(n)
+		if err != nil {
+			continue
+		}
+		for _, s := range services {
+			for _, e := range s.Endpoints {
+				ep := api.Decode(e.Metadata)
+				// endpoints accepting any method can't be matched against
+				if ep == nil || len(ep.Method) == 0 || !c.match(ep, r) {
+					continue
+				}
+				for _, m := range ep.Method {
+					if m == r.Method {
+						return nil, true
+					}
+					methods[m] = true
+				}
+			}
+		}
+	}
+
+	// no endpoint matched the path
+	if len(methods) == 0 {
+		return nil, true
+	}
+
+	allow := make([]string, 0, len(methods))
+	for m := range methods {
+		allow = append(allow, m)
+	}
+	sort.Strings(allow)
+	return allow, false
+}
+
+func (c *methodChecker) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// preflight requests are answered by cors
+		name := service(r)
+		if len(name) == 0 || r.Method == "OPTIONS" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if allow, ok := c.allowed(name, r); !ok {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			writeError(w, c.msg, http.StatusMethodNotAllowed)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+func TestMethodChecker(t *testing.T) {
+	reg := &testRegistry{services: map[string][]*registry.Service{
+		"go.micro.api.greeter": {{
+			Name: "go.micro.api.greeter",
+			Endpoints: []*registry.Endpoint{
+				{Name: "Greeter.Hello", Metadata: map[string]string{"endpoint": "Greeter.Hello", "method": "POST,PUT", "path": "^/greeter/hello$"}},
+				{Name: "Greeter.List", Metadata: map[string]string{"endpoint": "Greeter.List", "method": "GET", "path": "^/greeter/hello$"}},
+				{Name: "Greeter.Any", Metadata: map[string]string{"endpoint": "Greeter.Any", "path": "^/greeter/any$"}},
+			},
+		}},
+	}}
+	h := newMethodChecker(reg, "go.micro.api", "Nope").Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	testData := []struct {
+		method string
+		path   string
+		code   int
+		allow  string
+	}{
+		{"POST", "/greeter/hello", http.StatusOK, ""},
+		{"GET", "/greeter/hello", http.StatusOK, ""},
+		{"DELETE", "/greeter/hello", http.StatusMethodNotAllowed, "GET, POST, PUT"},
+		// preflight requests are let through
+		{"OPTIONS", "/greeter/hello", http.StatusOK, ""},
+		// endpoints without methods accept any
+		{"DELETE", "/greeter/any", http.StatusOK, ""},
+		// paths without an endpoint fall through to the resolver
+		{"DELETE", "/greeter/other", http.StatusOK, ""},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest(d.method, d.path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), resolver.Endpoint{}, &resolver.Endpoint{Name: "greeter"}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code || w.Header().Get("Allow") != d.allow {
+			t.Fatalf("Expected status %d allowing %q for %s %s got %d %q", d.code, d.allow, d.method, d.path, w.Code, w.Header().Get("Allow"))
+		}
+		if d.code == http.StatusMethodNotAllowed && !strings.Contains(w.Body.String(), "Nope") {
+			t.Fatalf("Expected the configured message got %q", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/debug/trace"
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+const (
+	// spans sent in a single export request
+	otlpBatchSize = 512
+	// spans buffered waiting to be exported, dropped once full
+	otlpQueueSize = 4096
+	// how often buffered spans are exported
+	otlpFlushInterval = 5 * time.Second
+)
+
+// otlpTracer starts spans with ids in the w3c trace context format and
+// exports them to an opentelemetry collector in the OTLP/HTTP json format.
+// Finished spans are buffered and exported in batches, with those left
+// flushed on Stop.
+type otlpTracer struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	spans chan *trace.Span
+	once  sync.Once
+	done  chan bool
+}
+
+func newOTLPTracer(endpoint, service string) *otlpTracer {
+	t := &otlpTracer{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service:  service,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		spans:    make(chan *trace.Span, otlpQueueSize),
+		done:     make(chan bool),
+	}
+	go t.run()
+	return t
+}
+
+// randomID returns n random bytes hex encoded
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Start starts a span continuing the trace in the context if any
+func (t *otlpTracer) Start(ctx context.Context, name string) (context.Context, *trace.Span) {
+	span := &trace.Span{
+		Name:     name,
+		Trace:    randomID(16),
+		Id:       randomID(8),
+		Started:  time.Now(),
+		Metadata: make(map[string]string),
+	}
+	if traceID, parentID, ok := trace.FromContext(ctx); ok {
+		span.Trace = traceID
+		span.Parent = parentID
+	}
+	return trace.ToContext(ctx, span.Trace, span.Id), span
+}
+
+// Finish queues the span to be exported, dropping it if the queue is full
+func (t *otlpTracer) Finish(s *trace.Span) error {
+	s.Duration = time.Since(s.Started)
+	select {
+	case t.spans <- s:
+		return nil
+	default:
+		return fmt.Errorf("dropped span %s of trace %s, the export queue is full", s.Id, s.Trace)
+	}
+}
+
+// Read returns no spans as they're kept by the collector
+func (t *otlpTracer) Read(...trace.ReadOption) ([]*trace.Span, error) {
+	return nil, nil
+}
+
+// Stop exports the spans left and stops exporting
+func (t *otlpTracer) Stop() {
+	t.once.Do(func() {
+		close(t.spans)
+		<-t.done
+	})
+}
+
+func (t *otlpTracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	var batch []*trace.Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.export(batch); err != nil {
+			log.Errorf("Failed to export %d spans to %s: %v", len(batch), t.endpoint, err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case s, ok := <-t.spans:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// otlp json encoding of the trace service request
+type otlpValue struct {
+	StringValue string `json:"stringValue,omitempty"`
+	IntValue    string `json:"intValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+const (
+	otlpSpanKindServer  = 2
+	otlpStatusCodeError = 2
+)
+
+// otlpAttributeKeys maps span metadata to the semantic conventions
+var otlpAttributeKeys = map[string]string{
+	"service": "micro.service",
+	"method":  "http.method",
+	"path":    "http.target",
+}
+
+// otlpEncode converts the spans to a trace service request
+func otlpEncode(service string, spans []*trace.Span) *otlpRequest {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/micro/micro/v2/api"}}
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.Trace,
+			SpanID:            s.Id,
+			ParentSpanID:      s.Parent,
+			Name:              s.Name,
+			Kind:              otlpSpanKindServer,
+			StartTimeUnixNano: strconv.FormatInt(s.Started.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.Started.Add(s.Duration).UnixNano(), 10),
+		}
+		for k, v := range s.Metadata {
+			switch k {
+			case "status":
+				span.Attributes = append(span.Attributes, otlpAttribute{Key: "http.status_code", Value: otlpValue{IntValue: v}})
+			case "error":
+				span.Status = otlpStatus{Code: otlpStatusCodeError, Message: v}
+			default:
+				if key, ok := otlpAttributeKeys[k]; ok {
+					k = key
+				}
+				span.Attributes = append(span.Attributes, otlpAttribute{Key: k, Value: otlpValue{StringValue: v}})
+			}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: service}}},
+			},
+			ScopeSpans: []otlpScopeSpans{scope},
+		}},
+	}
+}
+
+// export posts the spans to the collector
+func (t *otlpTracer) export(spans []*trace.Span) error {
+	b, err := json.Marshal(otlpEncode(t.service, spans))
+	if err != nil {
+		return err
+	}
+
+	rsp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", rsp.Status)
+	}
+	return nil
+}
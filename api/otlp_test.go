@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+func TestOTLPTracer(t *testing.T) {
+	var mtx sync.Mutex
+	var exported []otlpSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("Expected spans exported to /v1/traces got %s", r.URL.Path)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mtx.Lock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				exported = append(exported, ss.Spans...)
+			}
+		}
+		mtx.Unlock()
+	}))
+	defer collector.Close()
+
+	ot := newOTLPTracer(collector.URL, "go.micro.api")
+
+	var traceparent string
+	h := newTracer(ot, 1, nil, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(traceparentHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	r := httptest.NewRequest("GET", "/foo/bar", nil)
+	r.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ep := &resolver.Endpoint{Name: "go.micro.api.foo"}
+	r = r.WithContext(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	// the spans left are exported once stopped
+	ot.Stop()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(exported) != 1 {
+		t.Fatalf("Expected 1 span exported got %d", len(exported))
+	}
+	span := exported[0]
+	if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("Expected the trace of the client to be continued got %s %s", span.TraceID, span.ParentSpanID)
+	}
+	if span.Name != "go.micro.api.foo" {
+		t.Fatalf("Expected span named after the service got %s", span.Name)
+	}
+	if expect := "00-" + span.TraceID + "-" + span.SpanID + "-01"; traceparent != expect {
+		t.Fatalf("Expected traceparent %s passed to the backend got %s", expect, traceparent)
+	}
+	if span.Status.Code != otlpStatusCodeError {
+		t.Fatalf("Expected the span to record an error got status %d", span.Status.Code)
+	}
+
+	attrs := make(map[string]otlpValue)
+	for _, a := range span.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["http.status_code"].IntValue != "502" {
+		t.Fatalf("Expected status 502 recorded got %v", attrs["http.status_code"])
+	}
+	if attrs["micro.service"].StringValue != "go.micro.api.foo" || attrs["http.method"].StringValue != "GET" {
+		t.Fatalf("Expected the service and method recorded got %v", attrs)
+	}
+}
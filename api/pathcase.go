@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// pathCase lowercases the paths under a prefix so mixed case requests
+// resolve to the same service and endpoint
+type pathCase struct {
+	prefix string
+	// lowercase only the service name segment following the prefix
+	service bool
+}
+
+// parsePathCases parses path normalisations in the format prefix=mode
+// where the mode is path or service
+func parsePathCases(list []string) ([]pathCase, error) {
+	var cases []pathCase
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") {
+			return nil, fmt.Errorf("%s is not a valid path normalisation, expected prefix=mode", s)
+		}
+		switch parts[1] {
+		case "path", "service":
+		default:
+			return nil, fmt.Errorf("%s is not a valid path normalisation mode", parts[1])
+		}
+		cases = append(cases, pathCase{prefix: parts[0], service: parts[1] == "service"})
+	}
+	return cases, nil
+}
+
+// normalise returns the path lowercased as configured for its prefix
+func (c pathCase) normalise(path string) (string, bool) {
+	if len(path) < len(c.prefix) || !strings.EqualFold(path[:len(c.prefix)], c.prefix) {
+		return path, false
+	}
+
+	rest := path[len(c.prefix):]
+	// the prefix has to end on a segment boundary
+	if len(rest) > 0 && rest[0] != '/' && !strings.HasSuffix(c.prefix, "/") {
+		return path, false
+	}
+	if !c.service {
+		return c.prefix + strings.ToLower(rest), true
+	}
+
+	// the service name is the first segment after the prefix
+	lead := len(rest) - len(strings.TrimLeft(rest, "/"))
+	end := strings.Index(rest[lead:], "/")
+	if end == -1 {
+		end = len(rest)
+	} else {
+		end += lead
+	}
+	return c.prefix + strings.ToLower(rest[:end]) + rest[end:], true
+}
+
+// pathCaseHandler normalises the case of request paths before they're
+// resolved, using the longest matching prefix
+func pathCaseHandler(cases []pathCase, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var match *pathCase
+		for i, c := range cases {
+			if _, ok := c.normalise(r.URL.Path); ok && (match == nil || len(c.prefix) > len(match.prefix)) {
+				match = &cases[i]
+			}
+		}
+
+		if match != nil {
+			path, _ := match.normalise(r.URL.Path)
+			r.URL.Path = path
+			r.URL.RawPath = ""
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPathCase(t *testing.T) {
+	if _, err := parsePathCases([]string{"/greeter=upper"}); err == nil {
+		t.Fatal("Expected invalid mode to be rejected")
+	}
+
+	cases, err := parsePathCases([]string{"/=service", "/admin=path"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path string
+	h := pathCaseHandler(cases, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}))
+
+	testData := []struct {
+		path   string
+		expect string
+	}{
+		{"/Greeter/Say/Hello", "/greeter/Say/Hello"},
+		{"/GREETER", "/greeter"},
+		{"/Admin/Users/List", "/admin/users/list"},
+		{"/Administrator/Users", "/administrator/Users"},
+	}
+
+	for _, d := range testData {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", d.path, nil))
+		if path != d.expect {
+			t.Fatalf("Expected %s to be normalised to %s got %s", d.path, d.expect, path)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// pathDepth returns the number of segments in the path, counting empty
+// segments so repeated slashes can't be used to get around the limit
+func pathDepth(path string) int {
+	return strings.Count(strings.TrimSuffix(path, "/"), "/")
+}
+
+// pathDepthHandler rejects requests whose path has more than max segments
+// with a 400 before they're resolved
+func pathDepthHandler(max int, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pathDepth(r.URL.Path) > max {
+			writeError(w, "Request path too deep", http.StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPathDepth(t *testing.T) {
+	testData := []struct {
+		path string
+		code int
+	}{
+		{"/", http.StatusOK},
+		{"/foo/bar/baz", http.StatusOK},
+		{"/foo/bar/baz/", http.StatusOK},
+		{"/foo/bar/baz/qux", http.StatusBadRequest},
+		// empty segments count towards the depth
+		{"/foo//bar/baz", http.StatusBadRequest},
+	}
+
+	h := pathDepthHandler(3, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, d := range testData {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", d.path, nil))
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %s got %d", d.code, d.path, w.Code)
+		}
+	}
+}
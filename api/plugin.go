@@ -1,7 +1,9 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"github.com/micro/micro/v2/plugin"
 )
@@ -10,6 +12,19 @@ var (
 	defaultManager = plugin.NewManager()
 )
 
+// statuser is implemented by plugins which report their health
+type statuser interface {
+	Status() error
+}
+
+// pluginStatus is the status of a loaded plugin
+type pluginStatus struct {
+	Name    string `json:"name"`
+	Order   int    `json:"order"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
 // Plugins lists the api plugins
 func Plugins() []plugin.Plugin {
 	return defaultManager.Plugins()
@@ -22,3 +37,39 @@ func Register(pl plugin.Plugin) error {
 	}
 	return defaultManager.Register(pl)
 }
+
+// pluginsHandler lists the loaded plugins in the order they wrap requests
+// along with their health
+func pluginsHandler(w http.ResponseWriter, r *http.Request) {
+	plugins := append(Plugins(), plugin.Plugins()...)
+
+	status := make([]pluginStatus, 0, len(plugins))
+	healthy := true
+	for i, p := range plugins {
+		s := pluginStatus{Name: p.String(), Order: i, Healthy: true}
+		// plugins which don't report their health are assumed healthy
+		st, ok := p.(statuser)
+		if !ok {
+			status = append(status, s)
+			continue
+		}
+		if err := st.Status(); err != nil {
+			s.Healthy = false
+			s.Error = err.Error()
+			healthy = false
+		}
+		status = append(status, s)
+	}
+
+	b, err := json.Marshal(status)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
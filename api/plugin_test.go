@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/micro/v2/plugin"
+)
+
+// basicPlugin doesn't report its health
+type basicPlugin struct {
+	plugin.Plugin
+}
+
+func TestPluginsHandler(t *testing.T) {
+	defaultManager = plugin.NewManager()
+	defer func() { defaultManager = plugin.NewManager() }()
+
+	Register(plugin.NewPlugin(plugin.WithName("healthy")))
+	Register(plugin.NewPlugin(
+		plugin.WithName("broken"),
+		plugin.WithStatus(func() error { return errors.New("not connected") }),
+	))
+	Register(plugin.NewPlugin(plugin.WithName("unset"), plugin.WithStatus(nil)))
+	Register(basicPlugin{plugin.NewPlugin(plugin.WithName("basic"))})
+
+	h := adminHandler("secret", http.HandlerFunc(pluginsHandler))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/_plugins", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected status %d without the admin token got %d", http.StatusForbidden, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/_plugins", nil)
+	r.Header.Set(adminTokenHeader, "secret")
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status %d got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	var status []pluginStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []pluginStatus{
+		{Name: "healthy", Order: 0, Healthy: true},
+		{Name: "broken", Order: 1, Healthy: false, Error: "not connected"},
+		{Name: "unset", Order: 2, Healthy: true},
+		{Name: "basic", Order: 3, Healthy: true},
+	}
+
+	if len(status) != len(testData) {
+		t.Fatalf("Expected %d plugins got %d", len(testData), len(status))
+	}
+	for i, d := range testData {
+		if status[i] != d {
+			t.Fatalf("Expected %+v got %+v", d, status[i])
+		}
+	}
+}
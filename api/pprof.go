@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofPrefix is the path the profiling endpoints are served under
+const pprofPrefix = "/debug/pprof/"
+
+// pprofHandler serves the net/http/pprof profiling endpoints, e.g the heap
+// profile at /debug/pprof/heap
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pprofPrefix, pprof.Index)
+	mux.HandleFunc(pprofPrefix+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(pprofPrefix+"profile", pprof.Profile)
+	mux.HandleFunc(pprofPrefix+"symbol", pprof.Symbol)
+	mux.HandleFunc(pprofPrefix+"trace", pprof.Trace)
+	return mux
+}
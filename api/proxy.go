@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/micro/go-micro/v2/api/router"
+	"github.com/micro/go-micro/v2/client/selector"
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// proxyBufferPool reuses the buffers proxied bodies are copied through so
+// they aren't allocated for every request, counting the buffers taken and
+// those which had to be allocated
+type proxyBufferPool struct {
+	size int
+	pool sync.Pool
+
+	gets   uint64
+	allocs uint64
+}
+
+func newProxyBufferPool(size int) *proxyBufferPool {
+	p := &proxyBufferPool{size: size}
+	p.pool.New = func() interface{} {
+		atomic.AddUint64(&p.allocs, 1)
+		return make([]byte, size)
+	}
+	return p
+}
+
+func (p *proxyBufferPool) Get() []byte {
+	atomic.AddUint64(&p.gets, 1)
+	return p.pool.Get().([]byte)
+}
+
+func (p *proxyBufferPool) Put(b []byte) {
+	if cap(b) != p.size {
+		return
+	}
+	p.pool.Put(b[:p.size])
+}
+
+// headerTooLarge determines whether the backend response failed as its
+// headers were over the limit of the transport, which has no typed error
+func headerTooLarge(err error) bool {
+	return strings.Contains(err.Error(), "response headers exceeded")
+}
+
+// dialTimedOut determines whether the error is a connection to a backend
+// which timed out before it was established
+func dialTimedOut(err error) bool {
+	var oe *net.OpError
+	return errors.As(err, &oe) && oe.Op == "dial" && oe.Timeout()
+}
+
+// proxyHandler proxies requests to a node of the service the router
+// resolves them to like the go-micro http handler, selected with the
+// strategy of the request, copying bodies through buffers from the pool.
+// Backend responses with headers over maxHeaderBytes are rejected with a 502.
+// Websockets are proxied over a hijacked connection to the node. Requests
+// past the deadline of their context get a 504, those to nodes which can't
+// be connected to in the dial timeout a 503.
+func proxyHandler(rt router.Router, pool httputil.BufferPool, maxHeaderBytes int64, dialer *backendDialer, strategy func(*http.Request) selector.Strategy) http.Handler {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxResponseHeaderBytes = maxHeaderBytes
+	transport.DialContext = dialer.DialContext
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		service, err := rt.Route(r)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// retries go to other nodes than those tried
+		services := service.Services
+		attempts, retried := attemptsFromContext(r.Context())
+		if retried {
+			services = attempts.exclude(services)
+		}
+
+		node, err := strategy(r)(services)()
+		if err != nil {
+			writeError(w, fmt.Sprintf("Service %s not found", service.Name), http.StatusNotFound)
+			return
+		}
+		if retried {
+			attempts.add(node.Id)
+		}
+
+		if isWebsocket(r) {
+			proxyWebsocket(w, r, node.Address, dialer.DialContext)
+			return
+		}
+
+		u, err := url.Parse(fmt.Sprintf("http://%s", node.Address))
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		proxy := httputil.NewSingleHostReverseProxy(u)
+		proxy.BufferPool = pool
+		proxy.Transport = transport
+		proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			if r.Context().Err() == context.DeadlineExceeded {
+				log.Warnf("Service %s at %s timed out", service.Name, node.Address)
+				writeError(w, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+				return
+			}
+			if dialTimedOut(err) {
+				log.Warnf("Timed out connecting to service %s at %s", service.Name, node.Address)
+				writeError(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			if headerTooLarge(err) {
+				log.Warnf("Service %s at %s returned response headers over %d bytes", service.Name, node.Address, maxHeaderBytes)
+			} else {
+				log.Errorf("Failed to proxy to service %s at %s: %v", service.Name, node.Address, err)
+			}
+			writeError(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
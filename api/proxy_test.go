@@ -0,0 +1,100 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api"
+	"github.com/micro/go-micro/v2/api/router"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// testRouter routes every request to the service
+type testRouter struct {
+	router.Router
+	service *api.Service
+}
+
+func (r *testRouter) Route(req *http.Request) (*api.Service, error) {
+	return r.service, nil
+}
+
+func TestProxyHandler(t *testing.T) {
+	body := strings.Repeat("a", 64*1024)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer backend.Close()
+
+	service := &api.Service{
+		Name: "go.micro.api.greeter",
+		Services: []*registry.Service{{
+			Name:  "go.micro.api.greeter",
+			Nodes: []*registry.Node{{Id: "greeter-1", Address: strings.TrimPrefix(backend.URL, "http://")}},
+		}},
+	}
+	pool := newProxyBufferPool(1024)
+	h := proxyHandler(&testRouter{service: service}, pool, 1<<20, newBackendDialer(0), randomStrategy)
+
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/greeter", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 got %d", w.Code)
+		}
+		if b, _ := ioutil.ReadAll(w.Body); string(b) != body {
+			t.Fatalf("Expected the whole body to be copied got %d bytes", len(b))
+		}
+	}
+
+	// a buffer is taken per response, the pool reuses them
+	if pool.gets != 10 {
+		t.Fatalf("Expected 10 buffers taken got %d", pool.gets)
+	}
+	if pool.allocs >= pool.gets {
+		t.Fatalf("Expected buffers to be reused got %d allocs for %d gets", pool.allocs, pool.gets)
+	}
+
+	// services without nodes aren't found
+	h = proxyHandler(&testRouter{service: &api.Service{Name: "go.micro.api.greeter"}}, pool, 1<<20, newBackendDialer(0), randomStrategy)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/greeter", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404 got %d", w.Code)
+	}
+}
+
+func TestProxyHandlerHeaderLimit(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Large", strings.Repeat("a", 8*1024))
+	}))
+	defer backend.Close()
+
+	service := &api.Service{
+		Name: "go.micro.api.greeter",
+		Services: []*registry.Service{{
+			Name:  "go.micro.api.greeter",
+			Nodes: []*registry.Node{{Id: "greeter-1", Address: strings.TrimPrefix(backend.URL, "http://")}},
+		}},
+	}
+
+	testData := []struct {
+		max  int64
+		code int
+	}{
+		{1 << 20, http.StatusOK},
+		{4 * 1024, http.StatusBadGateway},
+	}
+
+	for _, d := range testData {
+		h := proxyHandler(&testRouter{service: service}, newProxyBufferPool(1024), d.max, newBackendDialer(0), randomStrategy)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/greeter", nil))
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d with a limit of %d bytes got %d", d.code, d.max, w.Code)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// loadQueryDefaults reads the default query params from a json file
+// mapping path prefixes to the params injected e.g
+//
+//	{"/users": {"limit": "50"}}
+func loadQueryDefaults(file string) (map[string]map[string]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var defaults map[string]map[string]string
+	if err := json.Unmarshal(b, &defaults); err != nil {
+		return nil, err
+	}
+
+	for prefix := range defaults {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("%s is not a valid path prefix", prefix)
+		}
+	}
+
+	return defaults, nil
+}
+
+// queryDefaults returns the defaults of the longest prefix of the path,
+// matching whole segments
+func queryDefaults(defaults map[string]map[string]string, path string) map[string]string {
+	var match string
+	for prefix := range defaults {
+		if len(prefix) > len(match) && hasPathPrefix(path, prefix) {
+			match = prefix
+		}
+	}
+	if len(match) == 0 {
+		return nil
+	}
+	return defaults[match]
+}
+
+// queryDefaultsHandler adds the default query params of the route the
+// request doesn't set. Params set by the client, even if empty, are kept.
+func queryDefaultsHandler(defaults map[string]map[string]string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := queryDefaults(defaults, r.URL.Path)
+		if len(params) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		var added bool
+		for k, v := range params {
+			if _, ok := query[k]; ok {
+				continue
+			}
+			query.Set(k, v)
+			added = true
+		}
+		if added {
+			r.URL.RawQuery = query.Encode()
+			// the form may have been parsed from the query already
+			r.Form = nil
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
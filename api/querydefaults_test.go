@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueryDefaults(t *testing.T) {
+	defaults := map[string]map[string]string{
+		"/users":       {"limit": "50", "sort": "name"},
+		"/users/admin": {"limit": "10"},
+	}
+
+	var query map[string][]string
+	h := queryDefaultsHandler(defaults, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+	}))
+
+	testData := []struct {
+		url   string
+		limit string
+		sort  string
+	}{
+		{"/users", "50", "name"},
+		{"/users/list?sort=age", "50", "age"},
+		// params set by the client are kept even if empty
+		{"/users?limit=", "", "name"},
+		{"/users/admin/list", "10", ""},
+		// prefixes match whole segments
+		{"/usersearch", "", ""},
+	}
+
+	for _, d := range testData {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", d.url, nil))
+
+		var limit, sort string
+		if v := query["limit"]; len(v) > 0 {
+			limit = v[0]
+		}
+		if v := query["sort"]; len(v) > 0 {
+			sort = v[0]
+		}
+		if limit != d.limit || sort != d.sort {
+			t.Fatalf("Expected limit %q sort %q for %s got %q %q", d.limit, d.sort, d.url, limit, sort)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// max number of clients tracked before buckets which refilled are pruned
+var rateLimitMaxClients = 100000
+
+// clientLimits throttles the requests of each client to every service,
+// keyed by the client ip or by a header identifying it e.g an api key.
+// Clients without the header are keyed by their ip.
+type clientLimits struct {
+	// requests per second and max burst of requests
+	rate  float64
+	burst int
+	// header keying the client, the client ip if empty
+	header string
+	// proxies whose X-Forwarded-For header is trusted
+	trusted []*net.IPNet
+	// names of the limit, remaining and reset headers
+	headers []string
+
+	sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newClientLimits(rate float64, burst int, header string, trusted []*net.IPNet, headers []string) *clientLimits {
+	return &clientLimits{
+		rate:    rate,
+		burst:   burst,
+		header:  header,
+		trusted: trusted,
+		headers: headers,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// key returns the key of the client making the request
+func (l *clientLimits) key(r *http.Request) string {
+	if len(l.header) > 0 {
+		if v := r.Header.Get(l.header); len(v) > 0 {
+			return l.header + ":" + v
+		}
+	}
+	if ip := clientIP(r, l.trusted); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// allow takes a token from the bucket of the key, returning the tokens
+// remaining, the time until the bucket is full again and until the next
+// token
+func (l *clientLimits) allow(key string) (bool, int, time.Duration, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimitMaxClients {
+			l.prune(now)
+		}
+		b = &bucket{rate: l.rate, size: float64(l.burst), last: now}
+		b.tokens = b.burst()
+		l.buckets[key] = b
+	}
+	allowed := b.take(now)
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return allowed, int(b.tokens), b.reset(), wait
+}
+
+// prune drops the buckets which refilled, a new bucket behaves the same
+func (l *clientLimits) prune(now time.Time) {
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst() {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+func (l *clientLimits) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the service is only known once the request is resolved
+		ok, remaining, reset, wait := l.allow(l.key(r) + "|" + service(r))
+		if len(l.headers) == 3 {
+			w.Header().Set(l.headers[0], strconv.FormatFloat(l.rate, 'f', -1, 64))
+			w.Header().Set(l.headers[1], strconv.Itoa(remaining))
+			w.Header().Set(l.headers[2], strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+		}
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientLimits(t *testing.T) {
+	l := newClientLimits(1, 2, "X-Api-Key", nil, RateLimitHeaders)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(addr, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.RemoteAddr = addr
+		if len(key) > 0 {
+			r.Header.Set("X-Api-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// a client gets the burst then is throttled
+	for i := 0; i < 2; i++ {
+		if w := send("10.0.0.1:1234", ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d within the burst to be allowed got %d", i, w.Code)
+		}
+	}
+	w := send("10.0.0.1:4321", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("Unexpected rate limit headers %v", w.Header())
+	}
+
+	// other clients and api keys have their own limit
+	if w := send("10.0.0.2:1234", ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected another client to be allowed got %d", w.Code)
+	}
+	if w := send("10.0.0.1:1234", "key"); w.Code != http.StatusOK {
+		t.Fatalf("Expected a client keyed by api key to be allowed got %d", w.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// redirectWriter holds back redirect responses so they can be followed
+type redirectWriter struct {
+	http.ResponseWriter
+	header   http.Header
+	code     int
+	redirect bool
+	location string
+	wrote    bool
+}
+
+// Header returns the headers held back until the response is committed,
+// those of the response after so trailers set once the body is written
+// reach the client
+func (w *redirectWriter) Header() http.Header {
+	if w.wrote && !w.redirect {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *redirectWriter) WriteHeader(code int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if loc := w.header.Get("Location"); len(loc) > 0 {
+			w.redirect = true
+			w.code = code
+			w.location = loc
+			return
+		}
+	}
+
+	dst := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *redirectWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	// drop the body of redirects we follow
+	if w.redirect {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *redirectWriter) Flush() {
+	if w.redirect || !w.wrote {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// redirectHandler follows redirects returned by backends within the gateway
+// instead of passing them through to the client. Only redirects to paths on
+// the gateway are followed, anything else or anything past the max number of
+// redirects is passed through.
+func redirectHandler(max int, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// upgraded connections are hijacked rather than redirected
+		if upgrading(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		for i := 0; ; i++ {
+			rw := &redirectWriter{ResponseWriter: w, header: make(http.Header)}
+			h.ServeHTTP(rw, r)
+
+			if !rw.redirect {
+				return
+			}
+
+			u, err := r.URL.Parse(rw.location)
+			if err != nil || i >= max || !followable(r, u) {
+				dst := w.Header()
+				for k, v := range rw.header {
+					dst[k] = v
+				}
+				w.WriteHeader(rw.code)
+				return
+			}
+
+			// follow the redirect as a client would
+			next := r.Clone(r.Context())
+			next.URL = u
+			next.RequestURI = u.RequestURI()
+			if rw.code != http.StatusTemporaryRedirect && rw.code != http.StatusPermanentRedirect && r.Method != "HEAD" {
+				next.Method = "GET"
+			}
+			next.Body = http.NoBody
+			next.ContentLength = 0
+			r = next
+		}
+	})
+}
+
+// followable determines whether the redirect stays on the gateway and can be
+// followed without replaying a request body
+func followable(r *http.Request, u *url.URL) bool {
+	if len(u.Host) > 0 && u.Host != r.Host {
+		return false
+	}
+	// requests with a body can't be replayed
+	if r.Method != "GET" && r.Method != "HEAD" && r.ContentLength != 0 {
+		return false
+	}
+	return true
+}
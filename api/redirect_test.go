@@ -0,0 +1,74 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/foo/old":
+			http.Redirect(w, r, "/foo/new", http.StatusMovedPermanently)
+		case "/foo/loop":
+			http.Redirect(w, r, "/foo/loop", http.StatusFound)
+		case "/foo/external":
+			http.Redirect(w, r, "http://other.com/foo", http.StatusFound)
+		default:
+			w.Write([]byte(r.URL.Path))
+		}
+	})
+
+	testData := []struct {
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{"/foo/old", http.StatusOK, "/foo/new", ""},
+		{"/foo/loop", http.StatusFound, "", "/foo/loop"},
+		{"/foo/external", http.StatusFound, "", "http://other.com/foo"},
+	}
+
+	for _, d := range testData {
+		w := httptest.NewRecorder()
+		redirectHandler(3, h).ServeHTTP(w, httptest.NewRequest("GET", d.path, nil))
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for %s got %d", d.code, d.path, w.Code)
+		}
+		if len(d.body) > 0 && w.Body.String() != d.body {
+			t.Fatalf("Expected body %s for %s got %s", d.body, d.path, w.Body.String())
+		}
+		if loc := w.Header().Get("Location"); loc != d.location {
+			t.Fatalf("Expected location %q for %s got %q", d.location, d.path, loc)
+		}
+	}
+}
+
+func TestRedirectHandlerTrailers(t *testing.T) {
+	gateway := httptest.NewServer(redirectHandler(3, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/foo/old" {
+			http.Redirect(w, r, "/foo/new", http.StatusFound)
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("body"))
+		w.Header().Set("Grpc-Status", "0")
+	})))
+	defer gateway.Close()
+
+	rsp, err := http.Get(gateway.URL + "/foo/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	ioutil.ReadAll(rsp.Body)
+
+	// trailers are set once the body is written
+	if v := rsp.Trailer.Get("Grpc-Status"); v != "0" {
+		t.Fatalf("Expected the Grpc-Status trailer got %q", v)
+	}
+}
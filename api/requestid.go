@@ -0,0 +1,102 @@
+package api
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// crockford base32 alphabet used by ulids
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// maxRequestIDLength caps the length of incoming ids
+var maxRequestIDLength = 128
+
+// newULID returns a lexically sortable ulid
+func newULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	rand.Read(b[6:])
+
+	// encode the 128 bits as 26 base32 characters,
+	// padding the front with two zero bits
+	var out [26]byte
+	var acc uint64
+	bits := uint(2)
+	j := 0
+	for _, c := range b {
+		acc = acc<<8 | uint64(c)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[j] = crockford[(acc>>bits)&31]
+			j++
+		}
+	}
+	return string(out[:])
+}
+
+// requestIDs generates and propagates an id for every request
+type requestIDs struct {
+	header  string
+	format  string
+	prefix  string
+	pattern *regexp.Regexp
+	counter uint64
+}
+
+func newRequestIDs(header, format, prefix string, pattern *regexp.Regexp) *requestIDs {
+	return &requestIDs{
+		header:  header,
+		format:  format,
+		prefix:  prefix,
+		pattern: pattern,
+	}
+}
+
+// generate returns a new id in the configured format
+func (r *requestIDs) generate() string {
+	switch r.format {
+	case "ulid":
+		return newULID()
+	case "counter":
+		return fmt.Sprintf("%s%d", r.prefix, atomic.AddUint64(&r.counter, 1))
+	default:
+		return uuid.New().String()
+	}
+}
+
+// valid determines whether an incoming id can be kept
+func (r *requestIDs) valid(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	if r.pattern != nil {
+		return r.pattern.MatchString(id)
+	}
+	return true
+}
+
+func (r *requestIDs) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(r.header)
+		if !r.valid(id) {
+			id = r.generate()
+		}
+
+		// pass the id on to the backend and back to the client
+		req.Header.Set(r.header, id)
+		w.Header().Set(r.header, id)
+
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestULID(t *testing.T) {
+	a := newULID()
+	if len(a) != 26 {
+		t.Fatalf("Expected ulid of length 26 got %s", a)
+	}
+	if b := newULID(); b == a {
+		t.Fatalf("Expected unique ulids got %s twice", a)
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	ids := newRequestIDs("X-Request-Id", "counter", "api-", regexp.MustCompile("^api-[0-9]+$"))
+
+	var backend string
+	h := ids.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backend = r.Header.Get("X-Request-Id")
+	}))
+
+	testData := []struct {
+		incoming string
+		id       string
+	}{
+		{"", "api-1"},
+		{"api-100", "api-100"},
+		{"invalid", "api-2"},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		if len(d.incoming) > 0 {
+			r.Header.Set("X-Request-Id", d.incoming)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if backend != d.id {
+			t.Fatalf("Expected backend id %s got %s", d.id, backend)
+		}
+		if id := w.Header().Get("X-Request-Id"); id != d.id {
+			t.Fatalf("Expected response id %s got %s", d.id, id)
+		}
+	}
+}
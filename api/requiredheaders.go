@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// loadRequiredHeaders reads the headers required by routes from a json
+// file mapping path prefixes to the headers e.g
+//
+//	{"/orders": ["X-API-Version"]}
+func loadRequiredHeaders(file string) (map[string][]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var required map[string][]string
+	if err := json.Unmarshal(b, &required); err != nil {
+		return nil, err
+	}
+
+	for prefix, headers := range required {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("%s is not a valid path prefix", prefix)
+		}
+		for _, name := range headers {
+			if len(name) == 0 {
+				return nil, fmt.Errorf("%s requires a blank header", prefix)
+			}
+		}
+	}
+
+	return required, nil
+}
+
+// requiredHeaders returns the headers required by the longest prefix of
+// the path, matching whole segments
+func requiredHeaders(required map[string][]string, path string) []string {
+	var match string
+	for prefix := range required {
+		if len(prefix) > len(match) && hasPathPrefix(path, prefix) {
+			match = prefix
+		}
+	}
+	if len(match) == 0 {
+		return nil
+	}
+	return required[match]
+}
+
+// requiredHeadersHandler rejects requests missing a header their route
+// requires with a 400 naming the headers, before they're resolved so
+// misconfigured clients fail early. Blank headers count as missing.
+func requiredHeadersHandler(required map[string][]string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var missing []string
+		for _, name := range requiredHeaders(required, r.URL.Path) {
+			if len(r.Header.Get(name)) == 0 {
+				missing = append(missing, http.CanonicalHeaderKey(name))
+			}
+		}
+		if len(missing) > 0 {
+			writeError(w, "Missing required header "+strings.Join(missing, ", "), http.StatusBadRequest)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
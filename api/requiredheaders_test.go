@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequiredHeaders(t *testing.T) {
+	required := map[string][]string{
+		"/orders":        {"X-API-Version"},
+		"/orders/create": {"x-api-version", "Idempotency-Key"},
+	}
+
+	h := requiredHeadersHandler(required, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	testData := []struct {
+		path    string
+		headers map[string]string
+		missing string
+	}{
+		{"/orders", map[string]string{"X-Api-Version": "2"}, ""},
+		{"/orders/list", nil, "X-Api-Version"},
+		// blank headers count as missing
+		{"/orders", map[string]string{"X-Api-Version": ""}, "X-Api-Version"},
+		{"/orders/create", map[string]string{"X-Api-Version": "2"}, "Idempotency-Key"},
+		{"/orders/create", nil, "X-Api-Version, Idempotency-Key"},
+		// prefixes match whole segments
+		{"/ordersearch", nil, ""},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("GET", d.path, nil)
+		for k, v := range d.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if len(d.missing) == 0 {
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected %s to be allowed got %d", d.path, w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected %s to be rejected got %d", d.path, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "Missing required header "+d.missing) {
+			t.Fatalf("Expected the missing headers %s got %s", d.missing, w.Body.String())
+		}
+	}
+}
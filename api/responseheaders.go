@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseResponseHeaders parses headers in the format key=value
+func parseResponseHeaders(list []string) (http.Header, error) {
+	headers := make(http.Header)
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			return nil, fmt.Errorf("%s is not a valid response header, expected key=value", s)
+		}
+		headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+	return headers, nil
+}
+
+// responseHeadersWriter applies the headers once the response is committed.
+// Those not forced are set before the handler runs so every response gets
+// them, and dropped on commit if the handler added its own values.
+type responseHeadersWriter struct {
+	http.ResponseWriter
+	headers   http.Header
+	force     bool
+	committed bool
+}
+
+// commit applies the headers to the response about to be written
+func (w *responseHeadersWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+
+	dst := w.Header()
+	for k, v := range w.headers {
+		if w.force {
+			dst[k] = v
+			continue
+		}
+		// values added after the preset ones, e.g by the proxy copying
+		// the headers of the backend, replace them
+		if n := len(v); len(dst[k]) > n && equalValues(dst[k][:n], v) {
+			dst[k] = dst[k][n:]
+		}
+	}
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *responseHeadersWriter) WriteHeader(code int) {
+	w.commit()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseHeadersWriter) Write(b []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseHeadersWriter) Flush() {
+	w.commit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseHeadersWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// responseHeadersHandler sets the headers on every response e.g for
+// security hardening, including responses the handler doesn't write. Values
+// set by the backend are kept unless forced.
+func responseHeadersHandler(headers http.Header, force bool, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !force {
+			dst := w.Header()
+			for k, v := range headers {
+				if len(dst[k]) == 0 {
+					// copied so values added don't write to the config
+					dst[k] = append([]string(nil), v...)
+				}
+			}
+		}
+
+		rw := &responseHeadersWriter{ResponseWriter: w, headers: headers, force: force}
+		h.ServeHTTP(rw, r)
+		// the server writes the response of handlers which didn't
+		rw.commit()
+	})
+}
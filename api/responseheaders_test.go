@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	headers, err := parseResponseHeaders([]string{
+		"Strict-Transport-Security=max-age=31536000; includeSubDomains",
+		"X-Frame-Options=DENY",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		w.Write([]byte("ok"))
+	})
+
+	testData := []struct {
+		force bool
+		frame string
+	}{
+		{false, "SAMEORIGIN"},
+		{true, "DENY"},
+	}
+
+	for _, d := range testData {
+		w := httptest.NewRecorder()
+		responseHeadersHandler(headers, d.force, backend).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if v := w.Header().Get("Strict-Transport-Security"); v != "max-age=31536000; includeSubDomains" {
+			t.Fatalf("Expected the hsts header to be set got %q", v)
+		}
+		if v := w.Header()["X-Frame-Options"]; len(v) != 1 || v[0] != d.frame {
+			t.Fatalf("Expected X-Frame-Options %s when forced %v got %v", d.frame, d.force, v)
+		}
+	}
+
+	if _, err := parseResponseHeaders([]string{"X-Frame-Options"}); err == nil {
+		t.Fatal("Expected a header without a value to be rejected")
+	}
+}
+
+func TestResponseHeadersUnwritten(t *testing.T) {
+	headers := http.Header{"X-Frame-Options": {"DENY"}}
+
+	testData := []struct {
+		name    string
+		handler http.HandlerFunc
+		frame   []string
+	}{
+		// the server writes the response of handlers which don't
+		{"unwritten", func(w http.ResponseWriter, r *http.Request) {}, []string{"DENY"}},
+		// the headers are sent with the first flush
+		{"flushed", func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+			w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		}, []string{"DENY"}},
+		// the proxy adds the headers of the backend, replacing ours
+		{"added", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Frame-Options", "SAMEORIGIN")
+			w.WriteHeader(http.StatusOK)
+		}, []string{"SAMEORIGIN"}},
+	}
+
+	for _, d := range testData {
+		w := httptest.NewRecorder()
+		responseHeadersHandler(headers, false, d.handler).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if v := w.Result().Header["X-Frame-Options"]; len(v) != len(d.frame) || v[0] != d.frame[0] {
+			t.Fatalf("Expected X-Frame-Options %v for the %s response got %v", d.frame, d.name, v)
+		}
+	}
+
+	if v := headers["X-Frame-Options"]; len(v) != 1 || v[0] != "DENY" {
+		t.Fatalf("Expected the configured headers not to change got %v", v)
+	}
+}
+
+func TestResponseHeadersPreflight(t *testing.T) {
+	s := newServer("127.0.0.1:0")
+	s.opts.EnableCORS = true
+	s.Configure(withResponseHeaders(http.Header{"X-Frame-Options": {"DENY"}}, true))
+	s.Handle("/rpc", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// preflights are answered by cors, outside the wrappers
+	r := httptest.NewRequest("OPTIONS", "/rpc", nil)
+	r.Header.Set("Origin", "http://localhost:3000")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, r)
+
+	if len(w.Header().Get("Access-Control-Allow-Origin")) == 0 {
+		t.Fatal("Expected the preflight to be answered by cors")
+	}
+	if v := w.Header().Get("X-Frame-Options"); v != "DENY" {
+		t.Fatalf("Expected the preflight to get the response headers got %q", v)
+	}
+}
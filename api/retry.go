@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/selector"
+	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// retryClient retries the calls of idempotent requests which fail with a
+// timeout or server error, e.g a node restarting, up to retries times. Each
+// retry is sent to another node than those tried and waits for the backoff,
+// doubled on every retry. Calls of other requests aren't retried, including
+// by the go-micro client.
+type retryClient struct {
+	client.Client
+	retries int
+	backoff time.Duration
+}
+
+// delay returns the time to wait before the retry
+func (c *retryClient) delay(retry int) time.Duration {
+	return c.backoff << uint(retry-1)
+}
+
+func (c *retryClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	// the retries of the client would go to any node
+	opts = append(opts[:len(opts):len(opts)], client.WithRetries(0))
+	if c.retries <= 0 || !idempotent(ctx) {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	// the nodes are selected again on every call, without those tried
+	a := new(nodeAttempts)
+	opts = append(opts,
+		client.WithSelectOption(selector.WithFilter(a.exclude)),
+		client.WithCallWrapper(func(next client.CallFunc) client.CallFunc {
+			return func(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+				a.add(node.Id)
+				return next(ctx, node, req, rsp, opts)
+			}
+		}),
+	)
+
+	var err error
+	for i := 0; i <= c.retries; i++ {
+		if i > 0 {
+			log.Debugf("Retrying call to %s after %v", req.Service(), err)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(c.delay(i)):
+			}
+		}
+
+		err = c.Client.Call(ctx, req, rsp, opts...)
+		if retry, _ := client.RetryOnError(ctx, req, i, err); !retry {
+			return err
+		}
+	}
+	return err
+}
+
+func (c *retryClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	var retries int
+	if idempotent(ctx) {
+		retries = c.retries
+	}
+	opts = append(opts[:len(opts):len(opts)], client.WithRetries(retries))
+	return c.Client.Stream(ctx, req, opts...)
+}
+
+// retryWrapper returns a client wrapper retrying the failed calls of
+// idempotent requests
+func retryWrapper(retries int, backoff time.Duration) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &retryClient{Client: c, retries: retries, backoff: backoff}
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// flakyClient fails calls with the error until it's been called fails
+// times, sending each call to the first node the filters leave
+type flakyClient struct {
+	client.Client
+	err     error
+	fails   int
+	nodes   []string
+	options client.CallOptions
+}
+
+func (c *flakyClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.options = client.CallOptions{}
+	for _, o := range opts {
+		o(&c.options)
+	}
+
+	var so selector.SelectOptions
+	for _, o := range c.options.SelectOptions {
+		o(&so)
+	}
+	services := []*registry.Service{{Name: req.Service(), Nodes: testNodes(3)}}
+	for _, f := range so.Filters {
+		services = f(services)
+	}
+	node := services[0].Nodes[0]
+	c.nodes = append(c.nodes, node.Id)
+
+	call := func(context.Context, *registry.Node, client.Request, interface{}, client.CallOptions) error {
+		if len(c.nodes) <= c.fails {
+			return c.err
+		}
+		return nil
+	}
+	for i := len(c.options.CallWrappers); i > 0; i-- {
+		call = c.options.CallWrappers[i-1](call)
+	}
+	return call(ctx, node, req, rsp, c.options)
+}
+
+func TestRetryClient(t *testing.T) {
+	testData := []struct {
+		method string
+		header map[string]string
+		err    error
+		calls  int
+	}{
+		{"GET", nil, errors.InternalServerError(clientErrorID, "connection refused"), 3},
+		{"GET", nil, errors.Timeout(clientErrorID, "timeout"), 3},
+		// errors of the backend other than server errors aren't retried
+		{"GET", nil, errors.NotFound("go.micro.srv.greeter", "not found"), 1},
+		{"POST", nil, errors.InternalServerError(clientErrorID, "connection refused"), 1},
+		{"POST", map[string]string{"Idempotency-Key": "123"}, errors.InternalServerError(clientErrorID, "connection refused"), 3},
+	}
+
+	for _, d := range testData {
+		fc := &flakyClient{err: d.err, fails: 2}
+		c := retryWrapper(3, time.Millisecond)(fc)
+
+		var err error
+		h := methodHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// handlers build the call metadata from the headers
+			md := make(metadata.Metadata)
+			for k, v := range r.Header {
+				md[k] = strings.Join(v, ",")
+			}
+			err = c.Call(metadata.NewContext(context.Background(), md), &testRequest{service: "go.micro.srv.greeter"}, nil)
+		}))
+
+		r := httptest.NewRequest(d.method, "/greeter", nil)
+		for k, v := range d.header {
+			r.Header.Set(k, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if len(fc.nodes) != d.calls {
+			t.Fatalf("Expected %d calls for %s %v got %d", d.calls, d.method, d.header, len(fc.nodes))
+		}
+		if fc.options.Retries != 0 {
+			t.Fatalf("Expected the retries of the client to be disabled got %d", fc.options.Retries)
+		}
+		if d.calls == 3 {
+			if err != nil {
+				t.Fatalf("Expected the retry to succeed got %v", err)
+			}
+			// every retry goes to a node not tried
+			if fc.nodes[0] != "greeter-0" || fc.nodes[1] != "greeter-1" || fc.nodes[2] != "greeter-2" {
+				t.Fatalf("Expected each retry to go to another node got %v", fc.nodes)
+			}
+		} else if err == nil {
+			t.Fatalf("Expected the error of %s %v to be returned", d.method, d.header)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	c := &retryClient{retries: 3, backoff: 100 * time.Millisecond}
+	for i, expect := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		if d := c.delay(i + 1); d != expect {
+			t.Fatalf("Expected retry %d to wait %v got %v", i+1, expect, d)
+		}
+	}
+
+	// retries stop at the deadline of the call
+	fc := &flakyClient{err: errors.InternalServerError(clientErrorID, "connection refused"), fails: 10}
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{methodKey: "GET"})
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := retryWrapper(3, time.Second)(fc).Call(ctx, &testRequest{service: "go.micro.srv.greeter"}, nil); err == nil {
+		t.Fatal("Expected the error of the last call")
+	}
+	if time.Since(start) > time.Second || len(fc.nodes) != 1 {
+		t.Fatalf("Expected the retry to stop at the deadline got %d calls after %v", len(fc.nodes), time.Since(start))
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// header the gateway passes the id of the attempts at a retried request to
+// client wrappers in, replacing any value sent by the client
+var attemptsHeader = "Micro-Api-Attempts"
+
+type attemptsKey struct{}
+
+// nodeAttempts records the backend node each attempt at a request was sent
+// to, so retries go to other nodes than those which failed
+type nodeAttempts struct {
+	sync.Mutex
+	// node ids in the order of the attempts
+	nodes []string
+}
+
+func (a *nodeAttempts) add(id string) {
+	a.Lock()
+	a.nodes = append(a.nodes, id)
+	a.Unlock()
+}
+
+// Nodes returns the ids of the nodes tried in the order of the attempts
+func (a *nodeAttempts) Nodes() []string {
+	a.Lock()
+	defer a.Unlock()
+	return append([]string{}, a.nodes...)
+}
+
+// exclude is a selector filter dropping the nodes already tried. The
+// services are kept whole once every node was tried, retrying them all.
+func (a *nodeAttempts) exclude(old []*registry.Service) []*registry.Service {
+	a.Lock()
+	tried := make(map[string]bool, len(a.nodes))
+	for _, id := range a.nodes {
+		tried[id] = true
+	}
+	a.Unlock()
+	if len(tried) == 0 {
+		return old
+	}
+
+	var services []*registry.Service
+	for _, s := range old {
+		var nodes []*registry.Node
+		for _, n := range s.Nodes {
+			if !tried[n.Id] {
+				nodes = append(nodes, n)
+			}
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		service := new(registry.Service)
+		*service = *s
+		service.Nodes = nodes
+		services = append(services, service)
+	}
+	if len(services) == 0 {
+		return old
+	}
+	return services
+}
+
+// attemptsFromContext returns the attempts at the request if retried
+func attemptsFromContext(ctx context.Context) (*nodeAttempts, bool) {
+	a, ok := ctx.Value(attemptsKey{}).(*nodeAttempts)
+	return a, ok
+}
+
+// attemptTracker tracks the attempts at the requests being retried. The
+// proxy finds them in the request context and the client wrappers by the
+// id passed in the call metadata, as the handlers don't pass the context.
+type attemptTracker struct {
+	sync.RWMutex
+	requests map[string]*nodeAttempts
+}
+
+func newAttemptTracker() *attemptTracker {
+	return &attemptTracker{requests: make(map[string]*nodeAttempts)}
+}
+
+// start tracks the attempts at the request until done is called
+func (t *attemptTracker) start(r *http.Request) (req *http.Request, done func()) {
+	id := uuid.New().String()
+	a := new(nodeAttempts)
+
+	t.Lock()
+	t.requests[id] = a
+	t.Unlock()
+
+	r.Header.Set(attemptsHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), attemptsKey{}, a)), func() {
+		t.Lock()
+		delete(t.requests, id)
+		t.Unlock()
+	}
+}
+
+func (t *attemptTracker) get(id string) (*nodeAttempts, bool) {
+	t.RLock()
+	defer t.RUnlock()
+	a, ok := t.requests[id]
+	return a, ok
+}
+
+// retryNodesClient excludes the nodes already tried from the selection of
+// retried requests and records the node each call is sent to. Streams are
+// kept off the nodes tried but can't be recorded.
+type retryNodesClient struct {
+	client.Client
+	tracker *attemptTracker
+}
+
+func (c *retryNodesClient) options(ctx context.Context, opts []client.CallOption) ([]client.CallOption, *nodeAttempts) {
+	id, ok := metadata.Get(ctx, attemptsHeader)
+	if !ok {
+		return opts, nil
+	}
+	a, ok := c.tracker.get(id)
+	if !ok {
+		return opts, nil
+	}
+	return append(opts[:len(opts):len(opts)], client.WithSelectOption(selector.WithFilter(a.exclude))), a
+}
+
+func (c *retryNodesClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	opts, a := c.options(ctx, opts)
+	if a != nil {
+		opts = append(opts, client.WithCallWrapper(func(next client.CallFunc) client.CallFunc {
+			return func(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+				a.add(node.Id)
+				return next(ctx, node, req, rsp, opts)
+			}
+		}))
+	}
+	return c.Client.Call(ctx, req, rsp, opts...)
+}
+
+func (c *retryNodesClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	opts, _ = c.options(ctx, opts)
+	return c.Client.Stream(ctx, req, opts...)
+}
+
+// retryNodesWrapper returns a client wrapper sending retries to other nodes
+func retryNodesWrapper(t *attemptTracker) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &retryNodesClient{Client: c, tracker: t}
+	}
+}
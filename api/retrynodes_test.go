@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// firstStrategy selects the first node left
+func firstStrategy(*http.Request) selector.Strategy {
+	return func(services []*registry.Service) selector.Next {
+		return func() (*registry.Node, error) {
+			return services[0].Nodes[0], nil
+		}
+	}
+}
+
+func TestRetryOtherNodes(t *testing.T) {
+	var hits []string
+	backend := func(name string, code int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+			w.WriteHeader(code)
+		}))
+	}
+	failing, healthy := backend("failing", http.StatusServiceUnavailable), backend("healthy", http.StatusOK)
+	defer failing.Close()
+	defer healthy.Close()
+
+	service := &api.Service{
+		Name: "go.micro.api.greeter",
+		Services: []*registry.Service{{
+			Name: "go.micro.api.greeter",
+			Nodes: []*registry.Node{
+				{Id: "greeter-1", Address: strings.TrimPrefix(failing.URL, "http://")},
+				{Id: "greeter-2", Address: strings.TrimPrefix(healthy.URL, "http://")},
+			},
+		}},
+	}
+
+	tracker := newAttemptTracker()
+	var tried []string
+	h := bufferHandler(1024, 2, tracker, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyHandler(&testRouter{service: service}, newProxyBufferPool(1024), 1<<20, newBackendDialer(0), firstStrategy).ServeHTTP(w, r)
+		a, _ := attemptsFromContext(r.Context())
+		tried = a.Nodes()
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "/greeter", strings.NewReader("{}")))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the retry to succeed got %d", w.Code)
+	}
+	if len(hits) != 2 || hits[0] != "failing" || hits[1] != "healthy" {
+		t.Fatalf("Expected the retry to go to the other node got %v", hits)
+	}
+	if len(tried) != 2 || tried[0] != "greeter-1" || tried[1] != "greeter-2" {
+		t.Fatalf("Expected the nodes of both attempts to be tracked got %v", tried)
+	}
+	if len(tracker.requests) != 0 {
+		t.Fatalf("Expected the attempts to be dropped once done got %d", len(tracker.requests))
+	}
+}
+
+// optionsClient records the options of the last call
+type optionsClient struct {
+	client.Client
+	options client.CallOptions
+}
+
+func (c *optionsClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.options = client.CallOptions{}
+	for _, o := range opts {
+		o(&c.options)
+	}
+	return nil
+}
+
+func TestRetryNodesClient(t *testing.T) {
+	tracker := newAttemptTracker()
+	r, done := tracker.start(httptest.NewRequest("PUT", "/greeter", nil))
+	defer done()
+	a, _ := attemptsFromContext(r.Context())
+	a.add("greeter-1")
+
+	oc := &optionsClient{}
+	c := retryNodesWrapper(tracker)(oc)
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{attemptsHeader: r.Header.Get(attemptsHeader)})
+	if err := c.Call(ctx, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(oc.options.SelectOptions) != 1 || len(oc.options.CallWrappers) != 1 {
+		t.Fatalf("Expected the tried nodes to be excluded and the call recorded got %+v", oc.options)
+	}
+
+	// the node the call is sent to is recorded
+	call := oc.options.CallWrappers[0](func(context.Context, *registry.Node, client.Request, interface{}, client.CallOptions) error {
+		return nil
+	})
+	call(ctx, &registry.Node{Id: "greeter-2"}, nil, nil, client.CallOptions{})
+	if nodes := a.Nodes(); len(nodes) != 2 || nodes[1] != "greeter-2" {
+		t.Fatalf("Expected greeter-2 to be recorded got %v", nodes)
+	}
+
+	// the tried nodes are excluded unless all were tried
+	services := []*registry.Service{{Name: "go.micro.api.greeter", Nodes: testNodes(3)}}
+	a = &nodeAttempts{nodes: []string{"greeter-0", "greeter-2"}}
+	if s := a.exclude(services); len(s) != 1 || len(s[0].Nodes) != 1 || s[0].Nodes[0].Id != "greeter-1" {
+		t.Fatalf("Expected only greeter-1 to be left got %v", s)
+	}
+	a.add("greeter-1")
+	if s := a.exclude(services); len(s[0].Nodes) != 3 {
+		t.Fatalf("Expected every node once all were tried got %v", s)
+	}
+
+	// calls of requests not retried are left alone
+	if err := c.Call(context.Background(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(oc.options.SelectOptions) != 0 {
+		t.Fatalf("Expected no options got %+v", oc.options)
+	}
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/micro/go-micro/v2/logger"
+	"golang.org/x/crypto/ocsp"
+)
+
+// maxOCSPEntries bounds the cached ocsp responses, one per certificate
+const maxOCSPEntries = 10000
+
+// crlEntry is a cached certificate revocation list
+type crlEntry struct {
+	list    *pkix.CertificateList
+	revoked map[string]bool
+	fetched time.Time
+	// issuer the signature of the list was verified against
+	issuer *x509.Certificate
+}
+
+// ocspEntry is a cached ocsp response for a certificate
+type ocspEntry struct {
+	revoked bool
+	fetched time.Time
+}
+
+// revocationFetch is a fetch in flight, shared by the requests waiting on it
+type revocationFetch struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+// revocationChecker checks whether client certificates are revoked against
+// CRLs and OCSP responders, either those configured or those listed in the
+// certificate, caching the results for the refresh interval. CRLs are only
+// trusted when signed by the issuer of the certificate and not expired.
+type revocationChecker struct {
+	// crl url overriding the distribution points of certificates
+	crlURL string
+	// ocsp responder overriding the servers of certificates
+	ocspURL string
+	// how long revocation data is cached
+	refresh time.Duration
+	// reject certificates whose revocation status can't be determined
+	failClosed bool
+	client     *http.Client
+
+	sync.Mutex
+	crls map[string]*crlEntry
+	ocsp map[string]*ocspEntry
+	// when expired ocsp responses were last dropped
+	swept time.Time
+	// fetches in flight by url
+	fetches map[string]*revocationFetch
+}
+
+func newRevocationChecker(crlURL, ocspURL string, refresh time.Duration, failClosed bool) *revocationChecker {
+	return &revocationChecker{
+		crlURL:     crlURL,
+		ocspURL:    ocspURL,
+		refresh:    refresh,
+		failClosed: failClosed,
+		client:     &http.Client{Timeout: time.Second * 10},
+		crls:       make(map[string]*crlEntry),
+		ocsp:       make(map[string]*ocspEntry),
+		swept:      time.Now(),
+		fetches:    make(map[string]*revocationFetch),
+	}
+}
+
+// once calls fn for the key, requests for the same key while it's in flight
+// waiting on it and sharing its result rather than each fetching
+func (c *revocationChecker) once(key string, fn func() (interface{}, error)) (interface{}, error) {
+	c.Lock()
+	if f, ok := c.fetches[key]; ok {
+		c.Unlock()
+		<-f.done
+		return f.val, f.err
+	}
+	f := &revocationFetch{done: make(chan struct{})}
+	c.fetches[key] = f
+	c.Unlock()
+
+	f.val, f.err = fn()
+
+	c.Lock()
+	delete(c.fetches, key)
+	c.Unlock()
+	close(f.done)
+
+	return f.val, f.err
+}
+
+func (c *revocationChecker) fetch(url string) ([]byte, error) {
+	rsp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, errors.New(rsp.Status)
+	}
+	return ioutil.ReadAll(rsp.Body)
+}
+
+// crl returns the revoked serial numbers of the list at the url, verifying
+// the list was signed by the issuer and is current
+func (c *revocationChecker) crl(url string, issuer *x509.Certificate) (map[string]bool, error) {
+	c.Lock()
+	e, ok := c.crls[url]
+	c.Unlock()
+
+	if !ok || time.Since(e.fetched) >= c.refresh {
+		v, err := c.once("crl "+url, func() (interface{}, error) {
+			b, err := c.fetch(url)
+			if err != nil {
+				return nil, err
+			}
+			list, err := x509.ParseCRL(b)
+			if err != nil {
+				return nil, err
+			}
+
+			revoked := make(map[string]bool)
+			for _, rc := range list.TBSCertList.RevokedCertificates {
+				revoked[rc.SerialNumber.String()] = true
+			}
+			e := &crlEntry{list: list, revoked: revoked, fetched: time.Now()}
+
+			c.Lock()
+			c.crls[url] = e
+			c.Unlock()
+			return e, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		e = v.(*crlEntry)
+	}
+
+	// the list is fetched over plain http so can't be trusted unless signed,
+	// the signature being verified once per issuer rather than per request
+	if issuer == nil {
+		return nil, errors.New("crl issuer unknown")
+	}
+	c.Lock()
+	verified := e.issuer != nil && e.issuer.Equal(issuer)
+	c.Unlock()
+	if !verified {
+		if err := issuer.CheckCRLSignature(e.list); err != nil {
+			return nil, fmt.Errorf("crl %s: %v", url, err)
+		}
+		c.Lock()
+		e.issuer = issuer
+		c.Unlock()
+	}
+	if e.list.HasExpired(time.Now()) {
+		return nil, fmt.Errorf("crl %s has expired", url)
+	}
+
+	return e.revoked, nil
+}
+
+// ocspRevoked asks the responder whether the certificate is revoked
+func (c *revocationChecker) ocspRevoked(url string, cert, issuer *x509.Certificate) (bool, error) {
+	key := url + "/" + cert.SerialNumber.String()
+
+	c.Lock()
+	e, ok := c.ocsp[key]
+	c.Unlock()
+	if ok && time.Since(e.fetched) < c.refresh {
+		return e.revoked, nil
+	}
+
+	v, err := c.once("ocsp "+key, func() (interface{}, error) {
+		req, err := ocsp.CreateRequest(cert, issuer, nil)
+		if err != nil {
+			return nil, err
+		}
+		rsp, err := c.client.Post(url, "application/ocsp-request", bytes.NewReader(req))
+		if err != nil {
+			return nil, err
+		}
+		defer rsp.Body.Close()
+		b, err := ioutil.ReadAll(rsp.Body)
+		if err != nil {
+			return nil, err
+		}
+		res, err := ocsp.ParseResponseForCert(b, cert, issuer)
+		if err != nil {
+			return nil, err
+		}
+		if res.Status == ocsp.Unknown {
+			return nil, errors.New("ocsp status unknown")
+		}
+
+		e := &ocspEntry{revoked: res.Status == ocsp.Revoked, fetched: time.Now()}
+		c.cacheOCSP(key, e)
+		return e, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return v.(*ocspEntry).revoked, nil
+}
+
+// cacheOCSP caches the response, dropping those expired at most once per
+// refresh interval and evicting others when full so responses for every
+// certificate seen aren't kept forever
+func (c *revocationChecker) cacheOCSP(key string, e *ocspEntry) {
+	c.Lock()
+	defer c.Unlock()
+
+	if time.Since(c.swept) >= c.refresh {
+		for k, v := range c.ocsp {
+			if time.Since(v.fetched) >= c.refresh {
+				delete(c.ocsp, k)
+			}
+		}
+		c.swept = time.Now()
+	}
+
+	for k := range c.ocsp {
+		if len(c.ocsp) < maxOCSPEntries {
+			break
+		}
+		delete(c.ocsp, k)
+	}
+
+	c.ocsp[key] = e
+}
+
+// revoked checks the certificate against the crls and ocsp responders
+func (c *revocationChecker) revoked(cert, issuer *x509.Certificate) (bool, error) {
+	crls := cert.CRLDistributionPoints
+	if len(c.crlURL) > 0 {
+		crls = []string{c.crlURL}
+	}
+	responders := cert.OCSPServer
+	if len(c.ocspURL) > 0 {
+		responders = []string{c.ocspURL}
+	}
+
+	if len(crls) == 0 && len(responders) == 0 {
+		return false, errors.New("no crl or ocsp responder")
+	}
+
+	var lastErr error
+	var checked bool
+
+	for _, url := range crls {
+		list, err := c.crl(url, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if list[cert.SerialNumber.String()] {
+			return true, nil
+		}
+		checked = true
+	}
+
+	// ocsp requires the issuer to identify the certificate
+	if issuer != nil {
+		for _, url := range responders {
+			revoked, err := c.ocspRevoked(url, cert, issuer)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if revoked {
+				return true, nil
+			}
+			checked = true
+		}
+	}
+
+	if !checked {
+		return false, lastErr
+	}
+	return false, nil
+}
+
+// check returns an error if the certificate at the head of the verified
+// chain is revoked, or its revocation status can't be determined when failing
+// closed
+func (c *revocationChecker) check(chain []*x509.Certificate) error {
+	cert := chain[0]
+	var issuer *x509.Certificate
+	if len(chain) > 1 {
+		issuer = chain[1]
+	}
+
+	revoked, err := c.revoked(cert, issuer)
+	if err != nil {
+		log.Warnf("Failed to check revocation of %s: %v", cert.Subject, err)
+		if c.failClosed {
+			return errors.New("unable to verify client certificate revocation")
+		}
+	}
+	if revoked {
+		return errors.New("client certificate revoked")
+	}
+	return nil
+}
+
+// revocationHandler rejects requests with revoked client certificates with a
+// 403. They're checked per request rather than during the handshake so the
+// client is told why it was rejected, and the CRL and OCSP fetches don't
+// stall the handshake.
+func revocationHandler(c *revocationChecker, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			if err := c.check(r.TLS.VerifiedChains[0]); err != nil {
+				writeError(w, err.Error(), http.StatusForbidden)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,235 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testCert(t *testing.T, serial int64, parent *x509.Certificate, key *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		parent, key = tmpl, priv
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &priv.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, priv
+}
+
+func TestRevocationChecker(t *testing.T) {
+	ca, caKey := testCert(t, 1, nil, nil)
+	good, _ := testCert(t, 2, ca, caKey)
+	revoked, _ := testCert(t, 3, ca, caKey)
+	_, otherKey := testCert(t, 4, nil, nil)
+
+	revokedList := []pkix.RevokedCertificate{
+		{SerialNumber: revoked.SerialNumber, RevocationTime: time.Now()},
+	}
+	crl, err := ca.CreateCRL(rand.Reader, caKey, revokedList, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an empty list not signed by the ca, e.g tampered with in transit
+	forged, err := ca.CreateCRL(rand.Reader, otherKey, nil, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := ca.CreateCRL(rand.Reader, caKey, nil, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetched int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		switch r.URL.Path {
+		case "/forged":
+			w.Write(forged)
+		case "/expired":
+			w.Write(expired)
+		default:
+			w.Write(crl)
+		}
+	}))
+	defer srv.Close()
+
+	testData := []struct {
+		url        string
+		failClosed bool
+		cert       *x509.Certificate
+		ok         bool
+	}{
+		{srv.URL, false, good, true},
+		{srv.URL, false, revoked, false},
+		{"", false, good, true},
+		{"", true, good, false},
+		// lists which can't be trusted leave the status unknown
+		{srv.URL + "/forged", false, revoked, true},
+		{srv.URL + "/forged", true, revoked, false},
+		{srv.URL + "/expired", false, good, true},
+		{srv.URL + "/expired", true, good, false},
+	}
+
+	for _, d := range testData {
+		c := newRevocationChecker(d.url, "", time.Hour, d.failClosed)
+		err := c.check([]*x509.Certificate{d.cert, ca})
+		if ok := err == nil; ok != d.ok {
+			t.Fatalf("Expected ok %v for serial %v from %q got %v", d.ok, d.cert.SerialNumber, d.url, err)
+		}
+	}
+
+	// the crl is cached between requests
+	fetched = 0
+	c := newRevocationChecker(srv.URL, "", time.Hour, false)
+	for i := 0; i < 3; i++ {
+		c.revoked(good, ca)
+	}
+	if fetched != 1 {
+		t.Fatalf("Expected crl to be fetched once got %d", fetched)
+	}
+}
+
+func TestRevocationSingleFlight(t *testing.T) {
+	ca, caKey := testCert(t, 1, nil, nil)
+	good, _ := testCert(t, 2, ca, caKey)
+
+	crl, err := ca.CreateCRL(rand.Reader, caKey, nil, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetched int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetched, 1)
+		<-release
+		w.Write(crl)
+	}))
+	defer srv.Close()
+
+	// requests checked while the crl is fetched wait on the same fetch
+	c := newRevocationChecker(srv.URL, "", time.Hour, true)
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.check([]*x509.Certificate{good, ca})
+		}()
+	}
+	time.Sleep(time.Millisecond * 100)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected the certificate to be checked got %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fetched); n != 1 {
+		t.Fatalf("Expected the crl to be fetched once got %d", n)
+	}
+}
+
+func TestRevocationOCSPCache(t *testing.T) {
+	c := newRevocationChecker("", "", time.Hour, false)
+
+	// expired responses are dropped once the refresh interval passes
+	c.ocsp["expired"] = &ocspEntry{fetched: time.Now().Add(-2 * time.Hour)}
+	c.swept = time.Now().Add(-2 * time.Hour)
+	c.cacheOCSP("current", &ocspEntry{fetched: time.Now()})
+	if _, ok := c.ocsp["expired"]; ok || len(c.ocsp) != 1 {
+		t.Fatalf("Expected the expired response to be dropped got %v", c.ocsp)
+	}
+
+	// responses are evicted when full
+	for i := 0; i < maxOCSPEntries+10; i++ {
+		c.cacheOCSP(strconv.Itoa(i), &ocspEntry{fetched: time.Now()})
+	}
+	if len(c.ocsp) != maxOCSPEntries {
+		t.Fatalf("Expected %d cached responses got %d", maxOCSPEntries, len(c.ocsp))
+	}
+}
+
+func TestRevocationHandler(t *testing.T) {
+	ca, caKey := testCert(t, 1, nil, nil)
+	good, goodKey := testCert(t, 2, ca, caKey)
+	revoked, revokedKey := testCert(t, 3, ca, caKey)
+
+	crl, err := ca.CreateCRL(rand.Reader, caKey, []pkix.RevokedCertificate{
+		{SerialNumber: revoked.SerialNumber, RevocationTime: time.Now()},
+	}, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crlSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	}))
+	defer crlSrv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	c := newRevocationChecker(crlSrv.URL, "", time.Hour, false)
+	srv := httptest.NewUnstartedServer(revocationHandler(c, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	srv.TLS = &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	get := func(cert *x509.Certificate, key *ecdsa.PrivateKey) (int, error) {
+		tr := srv.Client().Transport.(*http.Transport).Clone()
+		tr.TLSClientConfig.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{cert.Raw},
+			PrivateKey:  key,
+		}}
+		defer tr.CloseIdleConnections()
+		rsp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if err != nil {
+			return 0, err
+		}
+		rsp.Body.Close()
+		return rsp.StatusCode, nil
+	}
+
+	if code, err := get(good, goodKey); err != nil || code != http.StatusOK {
+		t.Fatalf("Expected a valid certificate to be served got %d %v", code, err)
+	}
+	// the request is rejected rather than the handshake so the client is told why
+	if code, err := get(revoked, revokedKey); err != nil || code != http.StatusForbidden {
+		t.Fatalf("Expected a revoked certificate to be forbidden got %d %v", code, err)
+	}
+}
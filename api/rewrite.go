@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// pathRewrite rewrites the paths matching a pattern with the replacement,
+// which can refer to the groups captured e.g $1
+type pathRewrite struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// parsePathRewrites parses path rewrites in the format pattern=replacement,
+// kept in the order given
+func parsePathRewrites(list []string) ([]pathRewrite, error) {
+	var rewrites []pathRewrite
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid path rewrite, expected pattern=replacement", s)
+		}
+		re, err := regexp.Compile(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid path rewrite pattern: %v", parts[0], err)
+		}
+		rewrites = append(rewrites, pathRewrite{pattern: re, replacement: parts[1]})
+	}
+	return rewrites, nil
+}
+
+// rewrite returns the path rewritten by the first rule matching it
+func rewrite(rewrites []pathRewrite, path string) (string, bool) {
+	for _, rw := range rewrites {
+		if !rw.pattern.MatchString(path) {
+			continue
+		}
+		path = rw.pattern.ReplaceAllString(path, rw.replacement)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		return path, true
+	}
+	return path, false
+}
+
+// pathRewriteHandler rewrites request paths before they're resolved so
+// clients of legacy paths reach the services at their current ones
+func pathRewriteHandler(rewrites []pathRewrite, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path, ok := rewrite(rewrites, r.URL.Path); ok {
+			r.URL.Path = path
+			r.URL.RawPath = ""
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
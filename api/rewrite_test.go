@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPathRewrite(t *testing.T) {
+	for _, s := range []string{"/v1", "=/users", "(=/users"} {
+		if _, err := parsePathRewrites([]string{s}); err == nil {
+			t.Fatalf("Expected invalid rewrite %s to be rejected", s)
+		}
+	}
+
+	rewrites, err := parsePathRewrites([]string{
+		`^/v1/users/(\w+)/orders$=/orders/$1`,
+		`^/v1/(.*)$=/$1`,
+		`^/v2/(.*)$=/$1`,
+		`^/legacy$=greeter`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path, query string
+	h := pathRewriteHandler(rewrites, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		query = r.URL.RawQuery
+	}))
+
+	testData := []struct {
+		path   string
+		expect string
+	}{
+		{"/v1/users", "/users"},
+		// the first rule matched wins
+		{"/v1/users/john/orders", "/orders/john"},
+		{"/v2/greeter/say", "/greeter/say"},
+		{"/legacy", "/greeter"},
+		{"/users/v1/list", "/users/v1/list"},
+	}
+
+	for _, d := range testData {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", d.path+"?name=john", nil))
+		if path != d.expect {
+			t.Fatalf("Expected %s to be rewritten to %s got %s", d.path, d.expect, path)
+		}
+		if query != "name=john" {
+			t.Fatalf("Expected the query of %s to be kept got %s", d.path, query)
+		}
+	}
+}
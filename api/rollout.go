@@ -0,0 +1,253 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/errors"
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// states of a rollout
+const (
+	rolloutRolling    = "rolling"
+	rolloutComplete   = "complete"
+	rolloutRolledBack = "rolled_back"
+)
+
+// rollout is the state of a service being cut over between versions
+type rollout struct {
+	Service string `json:"service"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	// percentage of requests sent to the new version
+	Weight int    `json:"weight"`
+	State  string `json:"state"`
+	// requests and errors of the new version during the current step
+	Requests uint64 `json:"requests"`
+	Errors   uint64 `json:"errors"`
+}
+
+// parseRollouts parses rollouts in the format service=from:to
+func parseRollouts(list []string) (map[string]*rollout, error) {
+	rollouts := make(map[string]*rollout)
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid rollout, expected service=from:to", s)
+		}
+		versions := strings.SplitN(parts[1], ":", 2)
+		if len(versions) != 2 || len(versions[0]) == 0 || len(versions[1]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid rollout, expected service=from:to", s)
+		}
+		rollouts[parts[0]] = &rollout{
+			Service: parts[0],
+			From:    versions[0],
+			To:      versions[1],
+			State:   rolloutRolling,
+		}
+	}
+	return rollouts, nil
+}
+
+// rolloutController progressively shifts the traffic of services from one
+// version to another. Every interval the weight of the new version grows
+// by the step until it takes all the traffic. When the error rate of the
+// new version exceeds the max during a step the rollout is rolled back and
+// all the traffic returns to the old version. A step only ends once the new
+// version served the min number of requests so a few errors at low traffic
+// neither roll it back nor go unnoticed. The http handler proxies requests
+// itself so it isn't covered.
+type rolloutController struct {
+	// percentage the weight grows by every interval
+	step     int
+	interval time.Duration
+	// error rate of the new version triggering a rollback
+	maxErrorRate float64
+	// requests to the new version needed to end a step
+	minRequests uint64
+	// called on every step and rollback e.g to record stats
+	record func(event, service string)
+
+	sync.Mutex
+	rollouts map[string]*rollout
+}
+
+func newRolloutController(rollouts map[string]*rollout, step int, interval time.Duration, maxErrorRate float64, minRequests int, record func(string, string)) *rolloutController {
+	for _, r := range rollouts {
+		r.Weight = step
+	}
+	return &rolloutController{
+		step:         step,
+		interval:     interval,
+		maxErrorRate: maxErrorRate,
+		minRequests:  uint64(minRequests),
+		record:       record,
+		rollouts:     rollouts,
+	}
+}
+
+// version returns the version to send a request for the service to, empty
+// if the service isn't being rolled out
+func (c *rolloutController) version(service string) string {
+	c.Lock()
+	defer c.Unlock()
+
+	r, ok := c.rollouts[service]
+	if !ok {
+		return ""
+	}
+	if rand.Intn(100) < r.Weight {
+		return r.To
+	}
+	return r.From
+}
+
+// report counts the outcome of a request sent to the version of the service
+func (c *rolloutController) report(service, version string, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	r, ok := c.rollouts[service]
+	if !ok || r.State != rolloutRolling || version != r.To {
+		return
+	}
+	r.Requests++
+	if failed(err) {
+		r.Errors++
+	}
+}
+
+// failed determines whether the error means the backend failed as
+// opposed to rejecting the request
+func failed(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := errors.Parse(err.Error()).Code
+	return code == 0 || code >= 500
+}
+
+// advance moves every rollout in progress on by a step or rolls it back
+func (c *rolloutController) advance() {
+	c.Lock()
+	defer c.Unlock()
+
+	for name, r := range c.rollouts {
+		if r.State != rolloutRolling {
+			continue
+		}
+
+		// wait for enough requests to judge the new version
+		if r.Requests < c.minRequests {
+			continue
+		}
+
+		if r.Requests > 0 && float64(r.Errors)/float64(r.Requests) > c.maxErrorRate {
+			log.Warnf("Rolling back %s to version %s after %d of %d requests to %s failed", name, r.From, r.Errors, r.Requests, r.To)
+			r.Weight = 0
+			r.State = rolloutRolledBack
+			c.record("rollout_rollback", name)
+			continue
+		}
+
+		r.Weight += c.step
+		if r.Weight >= 100 {
+			r.Weight = 100
+			r.State = rolloutComplete
+		}
+		r.Requests = 0
+		r.Errors = 0
+
+		log.Infof("Rolling out %s version %s to %d%% of requests", name, r.To, r.Weight)
+		c.record("rollout_step", name)
+	}
+}
+
+// Start advances the rollouts every interval
+func (c *rolloutController) Start() {
+	go func() {
+		t := time.NewTicker(c.interval)
+		defer t.Stop()
+		for range t.C {
+			c.advance()
+		}
+	}()
+}
+
+// ServeHTTP lists the state of the rollouts
+func (c *rolloutController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.Lock()
+	rollouts := make([]rollout, 0, len(c.rollouts))
+	for _, r := range c.rollouts {
+		rollouts = append(rollouts, *r)
+	}
+	c.Unlock()
+
+	sort.Slice(rollouts, func(i, j int) bool {
+		return rollouts[i].Service < rollouts[j].Service
+	})
+
+	b, err := json.Marshal(rollouts)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// rolloutClient sends requests for the services being rolled out to the
+// version picked by the controller
+type rolloutClient struct {
+	client.Client
+	c *rolloutController
+}
+
+// withVersion returns the call options to select a backend of the version
+func withVersion(version string, opts []client.CallOption) []client.CallOption {
+	filter := selector.WithFilter(selector.FilterVersion(version))
+	return append(opts[:len(opts):len(opts)], client.WithSelectOption(filter))
+}
+
+func (r *rolloutClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	version := r.c.version(req.Service())
+	if len(version) == 0 {
+		return r.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	err := r.Client.Call(ctx, req, rsp, withVersion(version, opts)...)
+	r.c.report(req.Service(), version, err)
+	return err
+}
+
+func (r *rolloutClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	version := r.c.version(req.Service())
+	if len(version) == 0 {
+		return r.Client.Stream(ctx, req, opts...)
+	}
+
+	stream, err := r.Client.Stream(ctx, req, withVersion(version, opts)...)
+	r.c.report(req.Service(), version, err)
+	return stream, err
+}
+
+// rolloutWrapper returns a client wrapper routing requests by rollout
+func rolloutWrapper(c *rolloutController) client.Wrapper {
+	return func(cl client.Client) client.Client {
+		return &rolloutClient{
+			Client: cl,
+			c:      c,
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	merrors "github.com/micro/go-micro/v2/errors"
+)
+
+func TestRollout(t *testing.T) {
+	if _, err := parseRollouts([]string{"go.micro.srv.greeter=1.0.0"}); err == nil {
+		t.Fatal("Expected rollout without a new version to be invalid")
+	}
+
+	rollouts, err := parseRollouts([]string{
+		"go.micro.srv.greeter=1.0.0:2.0.0",
+		"go.micro.srv.users=1.0.0:1.1.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rollbacks []string
+	c := newRolloutController(rollouts, 50, time.Minute, 0.1, 10, func(event, service string) {
+		if event == "rollout_rollback" {
+			rollbacks = append(rollbacks, service)
+		}
+	})
+
+	if v := c.version("go.micro.srv.other"); len(v) > 0 {
+		t.Fatalf("Expected no version for a service not rolled out got %s", v)
+	}
+
+	// a failure is too few requests to judge the new version by
+	c.report("go.micro.srv.users", "1.1.0", merrors.InternalServerError("go.micro.srv.users", "failed"))
+	c.advance()
+	if r := rollouts["go.micro.srv.users"]; r.Weight != 50 || r.State != rolloutRolling {
+		t.Fatalf("Expected users rollout to wait for more requests got %d%% %s", r.Weight, r.State)
+	}
+
+	// the greeter is healthy, users fails on the new version
+	for i := 0; i < 10; i++ {
+		c.report("go.micro.srv.greeter", "2.0.0", nil)
+		c.report("go.micro.srv.greeter", "2.0.0", merrors.NotFound("go.micro.srv.greeter", "not found"))
+		c.report("go.micro.srv.users", "1.0.0", errors.New("failed"))
+		c.report("go.micro.srv.users", "1.1.0", merrors.InternalServerError("go.micro.srv.users", "failed"))
+	}
+	c.advance()
+
+	if r := rollouts["go.micro.srv.greeter"]; r.Weight != 100 || r.State != rolloutComplete {
+		t.Fatalf("Expected greeter rollout to complete got %d%% %s", r.Weight, r.State)
+	}
+	if r := rollouts["go.micro.srv.users"]; r.Weight != 0 || r.State != rolloutRolledBack {
+		t.Fatalf("Expected users rollout to be rolled back got %d%% %s", r.Weight, r.State)
+	}
+	if len(rollbacks) != 1 || rollbacks[0] != "go.micro.srv.users" {
+		t.Fatalf("Expected users rollback to be recorded got %v", rollbacks)
+	}
+
+	for i := 0; i < 10; i++ {
+		if v := c.version("go.micro.srv.greeter"); v != "2.0.0" {
+			t.Fatalf("Expected completed rollout to use version 2.0.0 got %s", v)
+		}
+		if v := c.version("go.micro.srv.users"); v != "1.0.0" {
+			t.Fatalf("Expected rolled back rollout to use version 1.0.0 got %s", v)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("GET", "/_rollouts", nil))
+
+	var state []rollout
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state) != 2 || state[0].Service != "go.micro.srv.greeter" || state[1].State != rolloutRolledBack {
+		t.Fatalf("Unexpected rollout state %+v", state)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+// routeResolver resolves the requests of composite and template routes to
+// the backend services they call, so the auth wrapper and the middleware
+// keyed by service see the real backend rather than what the path resolves
+// to. Other requests are resolved by the fallback.
+type routeResolver struct {
+	resolver.Resolver
+
+	routes *mux.Router
+	// the endpoints called by each route
+	endpoints map[*mux.Route][]*resolver.Endpoint
+}
+
+func newRouteResolver() *routeResolver {
+	return &routeResolver{
+		routes:    mux.NewRouter(),
+		endpoints: make(map[*mux.Route][]*resolver.Endpoint),
+	}
+}
+
+// add registers the endpoints called for the path, of any method if empty
+func (r *routeResolver) add(path, method string, endpoints ...*resolver.Endpoint) {
+	route := r.routes.NewRoute().Path(path)
+	if len(method) > 0 {
+		route.Methods(method)
+	}
+	r.endpoints[route] = endpoints
+}
+
+// fallback sets the resolver of requests not matching a route
+func (r *routeResolver) fallback(rr resolver.Resolver) resolver.Resolver {
+	r.Resolver = rr
+	return r
+}
+
+// match returns the endpoints of the route matching the request
+func (r *routeResolver) match(req *http.Request) ([]*resolver.Endpoint, bool) {
+	var m mux.RouteMatch
+	if !r.routes.Match(req, &m) || m.MatchErr != nil {
+		return nil, false
+	}
+	endpoints := r.endpoints[m.Route]
+	return endpoints, len(endpoints) > 0
+}
+
+// Resolve returns the endpoint of the route, the first one for routes
+// calling several, so requests are keyed by a backend they call
+func (r *routeResolver) Resolve(req *http.Request) (*resolver.Endpoint, error) {
+	endpoints, ok := r.match(req)
+	if !ok {
+		return r.Resolver.Resolve(req)
+	}
+	ep := *endpoints[0]
+	return &ep, nil
+}
+
+// ResolveEndpoints returns every endpoint the route of the request calls so
+// access to all of them is verified, none for requests not matching a route
+func (r *routeResolver) ResolveEndpoints(req *http.Request) []*resolver.Endpoint {
+	endpoints, _ := r.match(req)
+
+	eps := make([]*resolver.Endpoint, len(endpoints))
+	for i, e := range endpoints {
+		ep := *e
+		eps[i] = &ep
+	}
+	return eps
+}
+
+func (r *routeResolver) String() string {
+	return "route"
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+func TestRouteResolver(t *testing.T) {
+	r := newRouteResolver()
+	r.add("/users/{id}", "GET", &resolver.Endpoint{Name: "go.micro.srv.users", Method: "Users.Get"})
+	r.add("/dashboard", "", compositeEndpoints(map[string]compositeCall{
+		"user":  {Service: "go.micro.srv.user", Endpoint: "User.Read"},
+		"feed":  {Service: "go.micro.srv.feed", Endpoint: "Feed.List"},
+		"stats": {Service: "go.micro.srv.stats", Endpoint: "Stats.Read"},
+	})...)
+	rr := r.fallback(testResolver{})
+
+	testData := []struct {
+		method    string
+		path      string
+		service   string
+		endpoints int
+	}{
+		{"GET", "/users/1", "go.micro.srv.users", 1},
+		// composites are keyed by their first call
+		{"GET", "/dashboard", "go.micro.srv.feed", 3},
+		// other methods and paths are resolved by the fallback
+		{"POST", "/users/1", "go.micro.api.files", 0},
+		{"GET", "/files/1", "go.micro.api.files", 0},
+	}
+
+	for _, d := range testData {
+		req := httptest.NewRequest(d.method, d.path, nil)
+		ep, err := rr.Resolve(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ep.Name != d.service {
+			t.Fatalf("Expected %s %s to resolve to %s got %s", d.method, d.path, d.service, ep.Name)
+		}
+		if eps := r.ResolveEndpoints(req); len(eps) != d.endpoints {
+			t.Fatalf("Expected %d endpoints for %s %s got %d", d.endpoints, d.method, d.path, len(eps))
+		}
+	}
+
+	// the endpoints returned are copies
+	ep, _ := rr.Resolve(httptest.NewRequest("GET", "/users/1", nil))
+	ep.Name = "changed"
+	if ep, _ := rr.Resolve(httptest.NewRequest("GET", "/users/1", nil)); ep.Name != "go.micro.srv.users" {
+		t.Fatalf("Expected the route endpoint not to change got %s", ep.Name)
+	}
+}
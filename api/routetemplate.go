@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+)
+
+// templateRoute maps a url template to a backend method e.g
+// /users/{id} to Users.Get with the id passed in the request
+type templateRoute struct {
+	// http method matched, any if empty
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Service  string `json:"service"`
+	Endpoint string `json:"endpoint"`
+}
+
+// loadTemplateRoutes reads the template routes from a json file e.g
+//
+//	[{"method": "GET", "path": "/users/{id}", "service": "go.micro.srv.users", "endpoint": "Users.Get"}]
+func loadTemplateRoutes(file string) ([]templateRoute, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []templateRoute
+	if err := json.Unmarshal(b, &routes); err != nil {
+		return nil, err
+	}
+
+	for _, t := range routes {
+		if len(t.Path) == 0 || len(t.Service) == 0 || len(t.Endpoint) == 0 {
+			return nil, fmt.Errorf("template route %+v requires a path, service and endpoint", t)
+		}
+	}
+
+	return routes, nil
+}
+
+// templateHandler calls the backend method of a template route. The request
+// is built from the json body, the query params and the path params in
+// that order of precedence, path params being passed as strings.
+type templateHandler struct {
+	client client.Client
+	route  templateRoute
+}
+
+func newTemplateHandler(c client.Client, t templateRoute) *templateHandler {
+	return &templateHandler{
+		client: c,
+		route:  t,
+	}
+}
+
+// templateRequest builds the backend request of the route
+func templateRequest(r *http.Request) (json.RawMessage, error) {
+	req := make(map[string]interface{})
+
+	if r.Body != nil {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 0 {
+			if err := json.Unmarshal(b, &req); err != nil {
+				return nil, fmt.Errorf("request body must be a json object: %v", err)
+			}
+		}
+	}
+
+	for k, v := range r.URL.Query() {
+		req[k] = strings.Join(v, ",")
+	}
+
+	for k, v := range mux.Vars(r) {
+		req[k] = v
+	}
+
+	return json.Marshal(req)
+}
+
+func (t *templateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request, err := templateRequest(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rsp, err := callJSON(requestContext(r), t.client, t.route.Service, t.route.Endpoint, request)
+	if err != nil {
+		e := errors.Parse(err.Error())
+		code := int(e.Code)
+		// codes which aren't http statuses would panic writing the header
+		if code < 100 || code > 599 {
+			code = http.StatusInternalServerError
+		}
+		b, _ := json.Marshal(e)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write(b)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(rsp)
+}
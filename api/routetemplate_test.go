@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+)
+
+// echoClient responds with the request it was sent
+type echoClient struct {
+	client.Client
+	body json.RawMessage
+}
+
+type echoRequest struct {
+	client.Request
+	body interface{}
+}
+
+func (r *echoRequest) Body() interface{} { return r.body }
+
+func (c *echoClient) NewRequest(service, endpoint string, req interface{}, opts ...client.RequestOption) client.Request {
+	return &echoRequest{body: req}
+}
+
+func (c *echoClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	*rsp.(*json.RawMessage) = *req.Body().(*json.RawMessage)
+	return nil
+}
+
+func TestTemplateHandler(t *testing.T) {
+	r := mux.NewRouter()
+	route := templateRoute{Method: "POST", Path: "/users/{id}/posts/{post}", Service: "go.micro.srv.users", Endpoint: "Posts.Update"}
+	r.Handle(route.Path, newTemplateHandler(new(echoClient), route)).Methods(route.Method)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/users/1/posts/2?draft=true&id=3", strings.NewReader(`{"title": "hello", "post": "4"}`))
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 got %d", w.Code)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	testData := map[string]interface{}{
+		// path params take precedence over the query and body
+		"id":    "1",
+		"post":  "2",
+		"draft": "true",
+		"title": "hello",
+	}
+
+	for k, v := range testData {
+		if got[k] != v {
+			t.Fatalf("Expected %s to be %v got %v", k, v, got[k])
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/users/1/posts/2", strings.NewReader(`[1, 2]`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for a non object body got %d", w.Code)
+	}
+}
+
+// failingClient fails every call with the code
+type failingClient struct {
+	client.Client
+	code int32
+}
+
+func (c *failingClient) NewRequest(service, endpoint string, req interface{}, opts ...client.RequestOption) client.Request {
+	return &testRequest{service: service, endpoint: endpoint}
+}
+
+func (c *failingClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	return &errors.Error{Id: req.Service(), Code: c.code, Detail: "failed"}
+}
+
+func TestTemplateHandlerErrors(t *testing.T) {
+	testData := []struct {
+		code   int32
+		status int
+	}{
+		{404, http.StatusNotFound},
+		{0, http.StatusInternalServerError},
+		// codes which aren't http statuses
+		{42, http.StatusInternalServerError},
+		{1000, http.StatusInternalServerError},
+	}
+
+	route := templateRoute{Path: "/users/{id}", Service: "go.micro.srv.users", Endpoint: "Users.Get"}
+	for _, d := range testData {
+		r := mux.NewRouter()
+		r.Handle(route.Path, newTemplateHandler(&failingClient{code: d.code}, route))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/users/1", nil))
+		if w.Code != d.status {
+			t.Fatalf("Expected status %d for code %d got %d", d.status, d.code, w.Code)
+		}
+	}
+}
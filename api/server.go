@@ -0,0 +1,502 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/server"
+	log "github.com/micro/go-micro/v2/logger"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// httpServer is the api gateway http server. It behaves like the go-micro
+// api http server but gives the gateway control over the listener and
+// the underlying http.Server.
+type httpServer struct {
+	mux  *http.ServeMux
+	opts server.Options
+
+	// socket options applied to accepted connections
+	noDelay     bool
+	readBuffer  int
+	writeBuffer int
+
+	// access log applied to all requests
+	accessLog *accessLog
+
+	// skip cors handling of same origin requests
+	corsSameOrigin bool
+	// origins, methods and headers allowed cross origin
+	corsOptions corsOptions
+
+	// headers set on every response, including cors preflights
+	responseHeaders      http.Header
+	forceResponseHeaders bool
+
+	// how long to wait for in flight requests on stop
+	drainTimeout time.Duration
+	// how long to keep serving once stopping so load balancers see the
+	// server draining before it stops accepting
+	shutdownDelay time.Duration
+	// set once stopping
+	draining int32
+
+	// max concurrent streams per http2 connection
+	maxConcurrentStreams uint32
+
+	// timeouts of the http server
+	timeouts serverTimeouts
+
+	// alpn protocols offered by tls, h2 and http/1.1 if empty
+	nextProtos []string
+
+	// closes connections flooding the server with requests
+	connLimiter *connLimiter
+
+	sync.RWMutex
+	address string
+	srv     *http.Server
+}
+
+// address acme providers serve on
+var acmeAddress = ":443"
+
+// serverTimeouts bound how long the server waits on clients, unlimited if 0
+type serverTimeouts struct {
+	// reading a request including the body
+	read time.Duration
+	// writing the response, from the end of the request headers
+	write time.Duration
+	// waiting for the next request on a keep alive connection
+	idle time.Duration
+}
+
+// serverOption configures the gateway specific parts of the server
+type serverOption func(s *httpServer)
+
+// withNoDelay sets TCP_NODELAY on accepted connections
+func withNoDelay(b bool) serverOption {
+	return func(s *httpServer) {
+		s.noDelay = b
+	}
+}
+
+// withReadBuffer sets the socket read buffer size of accepted connections
+func withReadBuffer(size int) serverOption {
+	return func(s *httpServer) {
+		s.readBuffer = size
+	}
+}
+
+// withWriteBuffer sets the socket write buffer size of accepted connections
+func withWriteBuffer(size int) serverOption {
+	return func(s *httpServer) {
+		s.writeBuffer = size
+	}
+}
+
+// withAccessLog sets the access log used for requests
+func withAccessLog(a *accessLog) serverOption {
+	return func(s *httpServer) {
+		s.accessLog = a
+	}
+}
+
+// withCORSSameOriginBypass skips cors handling of same origin requests
+func withCORSSameOriginBypass(b bool) serverOption {
+	return func(s *httpServer) {
+		s.corsSameOrigin = b
+	}
+}
+
+// withCORS restricts the origins, methods and headers allowed cross origin
+func withCORS(opts corsOptions) serverOption {
+	return func(s *httpServer) {
+		s.corsOptions = opts
+	}
+}
+
+// withResponseHeaders sets the headers on every response, keeping those set
+// by the handler unless forced
+func withResponseHeaders(headers http.Header, force bool) serverOption {
+	return func(s *httpServer) {
+		s.responseHeaders = headers
+		s.forceResponseHeaders = force
+	}
+}
+
+// withDrainTimeout waits up to d for in flight requests to complete on stop
+func withDrainTimeout(d time.Duration) serverOption {
+	return func(s *httpServer) {
+		s.drainTimeout = d
+	}
+}
+
+// withTimeouts sets the read, write and idle timeouts of the server so slow
+// clients can't hold connections open indefinitely
+func withTimeouts(t serverTimeouts) serverOption {
+	return func(s *httpServer) {
+		s.timeouts = t
+	}
+}
+
+// withNextProtos sets the alpn protocols offered during the tls handshake
+func withNextProtos(protos []string) serverOption {
+	return func(s *httpServer) {
+		s.nextProtos = protos
+	}
+}
+
+// withShutdownDelay keeps serving for d once stopping, reporting draining
+func withShutdownDelay(d time.Duration) serverOption {
+	return func(s *httpServer) {
+		s.shutdownDelay = d
+	}
+}
+
+// withMaxConcurrentStreams bounds the streams a client can open on an
+// http2 connection
+func withMaxConcurrentStreams(n uint32) serverOption {
+	return func(s *httpServer) {
+		s.maxConcurrentStreams = n
+	}
+}
+
+// withConnLimiter closes connections starting or resetting requests faster
+// than the limiter allows
+func withConnLimiter(c *connLimiter) serverOption {
+	return func(s *httpServer) {
+		s.connLimiter = c
+	}
+}
+
+func newServer(address string, opts ...server.Option) *httpServer {
+	var options server.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &httpServer{
+		opts:      options,
+		mux:       http.NewServeMux(),
+		noDelay:   true,
+		accessLog: newAccessLog(os.Stdout, "combined", true, nil, nil),
+		address:   address,
+	}
+}
+
+func (s *httpServer) Address() string {
+	s.RLock()
+	defer s.RUnlock()
+	return s.address
+}
+
+func (s *httpServer) Init(opts ...server.Option) error {
+	for _, o := range opts {
+		o(&s.opts)
+	}
+	return nil
+}
+
+// Configure applies the gateway specific server options
+func (s *httpServer) Configure(opts ...serverOption) {
+	s.Lock()
+	defer s.Unlock()
+	for _, o := range opts {
+		o(s)
+	}
+}
+
+func (s *httpServer) Handle(path string, handler http.Handler) {
+	// apply the wrappers, e.g. auth
+	for _, wrapper := range s.opts.Wrappers {
+		handler = wrapper(handler)
+	}
+
+	// wrap with cors
+	s.RLock()
+	if s.opts.EnableCORS {
+		if s.corsSameOrigin {
+			handler = sameOriginHandler(corsHandler(s.corsOptions, handler), handler)
+		} else {
+			handler = corsHandler(s.corsOptions, handler)
+		}
+	}
+
+	// set the response headers outside cors so preflights get them too
+	if len(s.responseHeaders) > 0 {
+		handler = responseHeadersHandler(s.responseHeaders, s.forceResponseHeaders, handler)
+	}
+
+	// wrap with logger
+	handler = s.accessLog.Handler(handler)
+	s.RUnlock()
+
+	s.mux.Handle(path, handler)
+}
+
+// listen returns the listener of the server and whether it serves tls
+func (s *httpServer) listen() (net.Listener, bool, error) {
+	address := s.address
+	config := s.opts.TLSConfig
+	if !s.opts.EnableTLS {
+		config = nil
+	}
+
+	// the acme providers serve on the standard tls port. Their tls config
+	// is used over our own listener so the socket options still apply.
+	acmeProvider := s.opts.EnableACME && s.opts.ACMEProvider != nil
+	if acmeProvider {
+		address = acmeAddress
+		c, err := s.opts.ACMEProvider.TLSConfig(s.opts.ACMEHosts...)
+		if err != nil {
+			return nil, false, err
+		}
+		config = c
+	}
+
+	var l net.Listener
+	var err error
+	if strings.HasPrefix(address, unixPrefix) {
+		l, err = listenUnix(strings.TrimPrefix(address, unixPrefix))
+	} else {
+		l, err = net.Listen("tcp", address)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// set the socket options before the tls handshake
+	l = s.wrapListener(l, acmeProvider)
+
+	s.RLock()
+	if s.connLimiter != nil {
+		l = s.connLimiter.Listener(l)
+	}
+	s.RUnlock()
+
+	if config == nil {
+		return l, false, nil
+	}
+
+	if len(s.nextProtos) > 0 {
+		config = offerProtos(config, s.nextProtos)
+	} else {
+		config = withH2(config)
+	}
+
+	return tls.NewListener(l, config), true, nil
+}
+
+// acme protocol answering tls-alpn-01 challenges
+const acmeTLSProto = "acme-tls/1"
+
+// offerProtos returns the tls config offering the protocols, keeping the
+// acme protocol the provider's config offers to answer challenges
+func offerProtos(config *tls.Config, protos []string) *tls.Config {
+	next := append([]string{}, protos...)
+	for _, p := range config.NextProtos {
+		if p == acmeTLSProto {
+			next = append(next, p)
+		}
+	}
+
+	config = config.Clone()
+	config.NextProtos = next
+	return config
+}
+
+// withH2 returns the tls config offering http2 so clients can negotiate it
+func withH2(config *tls.Config) *tls.Config {
+	for _, p := range config.NextProtos {
+		if p == "h2" {
+			return config
+		}
+	}
+
+	config = config.Clone()
+	protos := []string{"h2"}
+	if len(config.NextProtos) == 0 {
+		protos = append(protos, "http/1.1")
+	}
+	config.NextProtos = append(protos, config.NextProtos...)
+	return config
+}
+
+func (s *httpServer) Start() error {
+	l, secure, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("HTTP API Listening on %s", l.Addr().String())
+
+	var handler http.Handler = s.mux
+
+	s.RLock()
+	h2s := &http2.Server{
+		MaxConcurrentStreams: s.maxConcurrentStreams,
+	}
+	if s.connLimiter != nil {
+		handler = s.connLimiter.Handler(handler)
+	}
+	s.RUnlock()
+
+	srv := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  s.timeouts.read,
+		WriteTimeout: s.timeouts.write,
+		IdleTimeout:  s.timeouts.idle,
+	}
+
+	// serve http2 to tls connections negotiating h2 and over cleartext to
+	// clients with prior knowledge or upgrading with h2c
+	if secure {
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			l.Close()
+			return err
+		}
+	} else {
+		srv.Handler = h2c.NewHandler(handler, h2s)
+	}
+
+	s.Lock()
+	s.address = l.Addr().String()
+	if l.Addr().Network() == "unix" {
+		s.address = unixPrefix + s.address
+	}
+	s.srv = srv
+	s.Unlock()
+
+	go func() {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Errorf("HTTP API server error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Draining determines whether the server is stopping
+func (s *httpServer) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// Stop reports the server draining, closing connections as their requests
+// complete, keeps serving up to the shutdown delay then stops accepting
+// requests and waits for those in flight to complete up to the drain
+// timeout. Stopping a stopped server is a no-op.
+func (s *httpServer) Stop() error {
+	s.Lock()
+	srv := s.srv
+	s.srv = nil
+	s.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	// close idle connections and respond with Connection: close from now
+	// on so clients reconnect to other instances, while requests in flight
+	// complete within the drain timeout
+	srv.SetKeepAlivesEnabled(false)
+	atomic.StoreInt32(&s.draining, 1)
+	if s.shutdownDelay > 0 {
+		log.Infof("HTTP API draining, serving for %v before shutting down", s.shutdownDelay)
+		time.Sleep(s.shutdownDelay)
+	}
+
+	if s.drainTimeout <= 0 {
+		return srv.Close()
+	}
+
+	log.Infof("HTTP API draining requests for up to %v", s.drainTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Errorf("HTTP API failed to drain requests: %v", err)
+		return srv.Close()
+	}
+
+	return nil
+}
+
+func (s *httpServer) String() string {
+	return "http"
+}
+
+// serverGroup serves the same handler on several servers, e.g a tls one
+// for external clients alongside a plaintext one for internal clients
+type serverGroup []*httpServer
+
+func (g serverGroup) Init(opts ...server.Option) {
+	for _, s := range g {
+		s.Init(opts...)
+	}
+}
+
+// Configure applies the gateway specific server options to every server
+func (g serverGroup) Configure(opts ...serverOption) {
+	for _, s := range g {
+		s.Configure(opts...)
+	}
+}
+
+func (g serverGroup) Handle(path string, handler http.Handler) {
+	for _, s := range g {
+		s.Handle(path, handler)
+	}
+}
+
+// Start starts every server, stopping those started if one fails
+func (g serverGroup) Start() error {
+	for i, s := range g {
+		if err := s.Start(); err != nil {
+			g[:i].Stop()
+			return err
+		}
+	}
+	return nil
+}
+
+// Draining determines whether the servers are stopping
+func (g serverGroup) Draining() bool {
+	for _, s := range g {
+		if s.Draining() {
+			return true
+		}
+	}
+	return false
+}
+
+// Stop stops the servers together so their shutdown delays and drain
+// timeouts overlap, returning the first error
+func (g serverGroup) Stop() error {
+	errs := make([]error, len(g))
+	var wg sync.WaitGroup
+	for i, s := range g {
+		wg.Add(1)
+		go func(i int, s *httpServer) {
+			defer wg.Done()
+			errs[i] = s.Stop()
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
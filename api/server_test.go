@@ -0,0 +1,428 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/server"
+	"github.com/micro/go-micro/v2/registry/memory"
+	"golang.org/x/net/http2"
+)
+
+// testACMEProvider serves a fixed certificate
+type testACMEProvider struct {
+	config *tls.Config
+}
+
+func (p *testACMEProvider) Listen(hosts ...string) (net.Listener, error) {
+	return nil, errors.New("the provider's listener should not be used")
+}
+
+func (p *testACMEProvider) TLSConfig(hosts ...string) (*tls.Config, error) {
+	return p.config, nil
+}
+
+func TestACMEListener(t *testing.T) {
+	ca, key := testCert(t, 1, nil, nil)
+	config := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{ca.Raw}, PrivateKey: key}},
+	}
+
+	defer func(addr string) { acmeAddress = addr }(acmeAddress)
+	acmeAddress = "127.0.0.1:0"
+
+	s := newServer("127.0.0.1:0")
+	s.opts.EnableACME = true
+	s.opts.ACMEProvider = &testACMEProvider{config: config}
+
+	l, secure, err := s.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if !secure {
+		t.Fatal("Expected the acme listener to serve tls")
+	}
+
+	go func() {
+		c, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			c.Close()
+		}
+	}()
+
+	c, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tc, ok := c.(*tls.Conn)
+	if !ok {
+		t.Fatalf("Expected a tls connection got %T", c)
+	}
+	if err := tc.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// maxStreams reads the max concurrent streams the server advertises to a
+// http2 client on the connection
+func maxStreams(t *testing.T, c net.Conn) uint32 {
+	if _, err := c.Write([]byte(http2.ClientPreface)); err != nil {
+		t.Fatal(err)
+	}
+	fr := http2.NewFramer(c, c)
+	if err := fr.WriteSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fr.ReadFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf, ok := f.(*http2.SettingsFrame)
+	if !ok {
+		t.Fatalf("Expected a settings frame got %v", f)
+	}
+	v, ok := sf.Value(http2.SettingMaxConcurrentStreams)
+	if !ok {
+		t.Fatal("Expected the max concurrent streams to be set")
+	}
+	return v
+}
+
+func TestHTTP2MaxStreams(t *testing.T) {
+	cert, key := testCert(t, 1, nil, nil)
+
+	testData := []struct {
+		name   string
+		secure bool
+	}{
+		{"h2c", false},
+		{"h2", true},
+	}
+
+	for _, d := range testData {
+		s := newServer("127.0.0.1:0")
+		if d.secure {
+			s.opts.EnableTLS = true
+			s.opts.TLSConfig = &tls.Config{
+				Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}},
+			}
+		}
+		s.Configure(withMaxConcurrentStreams(10))
+		s.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		var c net.Conn
+		var err error
+		if d.secure {
+			var tc *tls.Conn
+			tc, err = tls.Dial("tcp", s.Address(), &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+			if err == nil && tc.ConnectionState().NegotiatedProtocol != "h2" {
+				t.Fatalf("Expected h2 to be negotiated got %q", tc.ConnectionState().NegotiatedProtocol)
+			}
+			c = tc
+		} else {
+			// cleartext http2 with prior knowledge
+			c, err = net.Dial("tcp", s.Address())
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := maxStreams(t, c); n != 10 {
+			t.Fatalf("Expected %s to allow 10 concurrent streams got %d", d.name, n)
+		}
+		c.Close()
+		s.Stop()
+	}
+}
+
+func TestStopDraining(t *testing.T) {
+	s := newServer("127.0.0.1:0")
+	s.Configure(withDrainTimeout(5*time.Second), withShutdownDelay(500*time.Millisecond))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	s.Handle("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	hc := newHealth(memory.NewRegistry(), s.Draining)
+	hc.setRegistered()
+	s.Handle("/readyz", http.HandlerFunc(hc.Ready))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + s.Address()
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	slow := make(chan error, 1)
+	go func() {
+		rsp, err := client.Get(url + "/slow")
+		if err == nil {
+			rsp.Body.Close()
+			if rsp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("unexpected status %d", rsp.StatusCode)
+			}
+		}
+		slow <- err
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop() }()
+
+	// the server keeps serving during the delay, reporting it's draining
+	var code int
+	for i := 0; i < 20 && code != http.StatusServiceUnavailable; i++ {
+		rsp, err := client.Get(url + "/readyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+		code = rsp.StatusCode
+		time.Sleep(10 * time.Millisecond)
+	}
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the server to report draining got %d", code)
+	}
+
+	// requests in flight complete once the server stopped accepting
+	time.Sleep(700 * time.Millisecond)
+	if _, err := client.Get(url + "/readyz"); err == nil {
+		t.Fatal("Expected the server to stop accepting after the delay")
+	}
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatalf("Expected the request in flight to complete got %v", err)
+	}
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopClosesConnections(t *testing.T) {
+	s := newServer("127.0.0.1:0")
+	s.Configure(withDrainTimeout(5*time.Second), withShutdownDelay(300*time.Millisecond))
+	s.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + s.Address()
+
+	rsp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.Close {
+		t.Fatal("Expected the connection to be kept alive before stopping")
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop() }()
+	for !s.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// responses close the connection once draining so clients move on
+	rsp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if !rsp.Close {
+		t.Fatal("Expected the connection to be closed while draining")
+	}
+	if err := <-stopped; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	s := newServer("127.0.0.1:0")
+	s.Configure(withTimeouts(serverTimeouts{read: 100 * time.Millisecond}))
+	s.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	c, err := net.Dial("tcp", s.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// a client which never finishes its headers is disconnected
+	if _, err := c.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	start := time.Now()
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Expected the connection to be closed")
+	} else if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		t.Fatal("Expected the server to close the connection before the client gave up")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Expected the connection to be closed after the read timeout got %v", d)
+	}
+}
+
+func TestNextProtos(t *testing.T) {
+	cert, key := testCert(t, 1, nil, nil)
+
+	testData := []struct {
+		protos []string
+		client []string
+		want   string
+	}{
+		// h2 is offered by default
+		{nil, []string{"h2", "http/1.1"}, "h2"},
+		{[]string{"http/1.1"}, []string{"h2", "http/1.1"}, "http/1.1"},
+		{[]string{"x-custom", "h2"}, []string{"x-custom"}, "x-custom"},
+	}
+
+	for _, d := range testData {
+		s := newServer("127.0.0.1:0")
+		s.opts.EnableTLS = true
+		s.opts.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}},
+		}
+		s.Configure(withNextProtos(d.protos))
+		s.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		c, err := tls.Dial("tcp", s.Address(), &tls.Config{InsecureSkipVerify: true, NextProtos: d.client})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := c.ConnectionState().NegotiatedProtocol; p != d.want {
+			t.Fatalf("Expected %s to be negotiated with %v got %q", d.want, d.protos, p)
+		}
+		c.Close()
+		s.Stop()
+	}
+
+	// the acme challenge protocol is kept
+	config := offerProtos(&tls.Config{NextProtos: []string{"h2", acmeTLSProto}}, []string{"http/1.1"})
+	if len(config.NextProtos) != 2 || config.NextProtos[0] != "http/1.1" || config.NextProtos[1] != acmeTLSProto {
+		t.Fatalf("Expected the acme protocol to be kept got %v", config.NextProtos)
+	}
+}
+
+func TestServerGroup(t *testing.T) {
+	cert, key := testCert(t, 1, nil, nil)
+
+	external, internal := newServer("127.0.0.1:0"), newServer("127.0.0.1:0")
+	g := serverGroup{external, internal}
+	g.Init(server.EnableTLS(true), server.TLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}},
+	}))
+	internal.Init(server.EnableTLS(false))
+	g.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		DisableKeepAlives: true,
+	}}
+	urls := []string{"https://" + external.Address(), "http://" + internal.Address()}
+	for _, url := range urls {
+		rsp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected %s to be served got %d", url, rsp.StatusCode)
+		}
+	}
+
+	if err := g.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if !g.Draining() {
+		t.Fatal("Expected the group to report draining once stopped")
+	}
+	for _, url := range urls {
+		if _, err := client.Get(url); err == nil {
+			t.Fatalf("Expected %s to be stopped", url)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unixsocket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "api.sock")
+
+	// a stale socket left by a crashed server is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := newServer(unixPrefix + path)
+	s.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Address() != unixPrefix+path {
+		t.Fatalf("Expected address %s got %s", unixPrefix+path, s.Address())
+	}
+
+	// a socket in use isn't
+	if err := newServer(unixPrefix + path).Start(); err == nil {
+		t.Fatal("Expected a socket in use to be rejected")
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}}
+	rsp, err := client.Get("http://api/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the socket to be served got %d", rsp.StatusCode)
+	}
+	client.CloseIdleConnections()
+
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected the socket to be removed on stop got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// window of latency samples used to compute the p99
+	shedWindow = time.Second * 10
+	// how often the p99 is recomputed
+	shedInterval = time.Second
+	// never shed more than this so we keep sampling latency
+	maxShedRatio = 0.95
+	// max number of latency samples kept
+	maxShedSamples = 10000
+)
+
+type sample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+// shedder rejects a fraction of new requests with a 503 when the
+// recent p99 latency exceeds the target latency
+type shedder struct {
+	// target p99 latency
+	target time.Duration
+	// scales the fraction of requests shed
+	aggressiveness float64
+	// called for every shed request e.g to record stats
+	record func()
+
+	// number of requests shed
+	shed uint64
+
+	sync.Mutex
+	samples  []sample
+	p99      time.Duration
+	computed time.Time
+}
+
+func newShedder(target time.Duration, aggressiveness float64, record func()) *shedder {
+	return &shedder{
+		target:         target,
+		aggressiveness: aggressiveness,
+		record:         record,
+	}
+}
+
+// observe records the latency of a served request
+func (s *shedder) observe(d time.Duration) {
+	s.Lock()
+	s.samples = append(s.samples, sample{at: time.Now(), latency: d})
+	if len(s.samples) > maxShedSamples {
+		s.samples = s.samples[len(s.samples)-maxShedSamples:]
+	}
+	s.Unlock()
+}
+
+// percentile returns the current p99, recomputing it at most once per interval
+func (s *shedder) percentile() time.Duration {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.computed) < shedInterval {
+		return s.p99
+	}
+	s.computed = now
+
+	// drop samples outside the window
+	var i int
+	for i < len(s.samples) && now.Sub(s.samples[i].at) > shedWindow {
+		i++
+	}
+	s.samples = s.samples[i:]
+
+	if len(s.samples) == 0 {
+		s.p99 = 0
+		return s.p99
+	}
+
+	latencies := make([]time.Duration, len(s.samples))
+	for i, sm := range s.samples {
+		latencies[i] = sm.latency
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s.p99 = latencies[(len(latencies)*99)/100]
+
+	return s.p99
+}
+
+// ratio returns the fraction of requests to shed for the given p99
+func (s *shedder) ratio(p99 time.Duration) float64 {
+	if p99 <= s.target {
+		return 0
+	}
+	r := s.aggressiveness * float64(p99-s.target) / float64(p99)
+	if r > maxShedRatio {
+		r = maxShedRatio
+	}
+	return r
+}
+
+// Shed returns the number of requests shed so far
+func (s *shedder) Shed() uint64 {
+	return atomic.LoadUint64(&s.shed)
+}
+
+func (s *shedder) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ratio := s.ratio(s.percentile()); ratio > 0 && rand.Float64() < ratio {
+			atomic.AddUint64(&s.shed, 1)
+			if s.record != nil {
+				s.record()
+			}
+			w.Header().Set("Retry-After", "1")
+			writeError(w, "Service overloaded", http.StatusServiceUnavailable)
+			return
+		}
+
+		start := time.Now()
+		h.ServeHTTP(w, r)
+		s.observe(time.Since(start))
+	})
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShedderRatio(t *testing.T) {
+	s := newShedder(time.Millisecond*100, 1.0, nil)
+
+	testData := []struct {
+		p99   time.Duration
+		ratio float64
+	}{
+		{0, 0},
+		{time.Millisecond * 100, 0},
+		{time.Millisecond * 200, 0.5},
+		{time.Hour, maxShedRatio},
+	}
+
+	for _, d := range testData {
+		if r := s.ratio(d.p99); r != d.ratio {
+			t.Fatalf("Expected ratio %v for p99 %v got %v", d.ratio, d.p99, r)
+		}
+	}
+}
+
+func TestShedderHandler(t *testing.T) {
+	var recorded int
+	s := newShedder(time.Millisecond, 1.0, func() { recorded++ })
+
+	// fake a slow p99 so every request would be shed at the max ratio
+	s.p99 = time.Second
+	s.computed = time.Now().Add(time.Hour)
+
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	var shed int
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+		if w.Code == http.StatusServiceUnavailable {
+			shed++
+		}
+	}
+
+	if shed == 0 {
+		t.Fatal("Expected requests to be shed")
+	}
+	if uint64(shed) != s.Shed() || shed != recorded {
+		t.Fatalf("Expected %d shed requests got %d recorded %d", shed, s.Shed(), recorded)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+func TestLoadSigningKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "key")
+	ioutil.WriteFile(file, []byte("secret\n"), 0600)
+	keys, err := loadSigningKeys([]string{"go.micro.api.greeter=" + file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(keys["go.micro.api.greeter"]) != "secret" {
+		t.Fatalf("Expected the key to be trimmed got %q", keys["go.micro.api.greeter"])
+	}
+
+	empty := filepath.Join(dir, "empty")
+	ioutil.WriteFile(empty, nil, 0600)
+	for _, s := range []string{"greeter", "greeter=", "greeter=" + filepath.Join(dir, "missing"), "greeter=" + empty} {
+		if _, err := loadSigningKeys([]string{s}); err == nil {
+			t.Fatalf("Expected an error loading %q", s)
+		}
+	}
+}
+
+func TestSigner(t *testing.T) {
+	key := []byte("secret")
+	s := newSigner(map[string][]byte{"go.micro.api.greeter": key}, 16)
+
+	var received *http.Request
+	var body string
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	}))
+
+	send := func(name, payload string) *httptest.ResponseRecorder {
+		received = nil
+		r := httptest.NewRequest("POST", "/greeter/hello?a=b", strings.NewReader(payload))
+		r.Header.Set(signatureHeader, "forged")
+		r = r.WithContext(context.WithValue(r.Context(), resolver.Endpoint{}, &resolver.Endpoint{Name: name}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// requests to services with a key are signed over the whole request
+	send("go.micro.api.greeter", `{"name": "john"}`)
+	ts := received.Header.Get(signatureTimestampHeader)
+	expected := "sha256=" + signature(key, ts, "POST", "/greeter/hello?a=b", []byte(`{"name": "john"}`))
+	if len(ts) == 0 || received.Header.Get(signatureHeader) != expected {
+		t.Fatalf("Expected signature %s got %s", expected, received.Header.Get(signatureHeader))
+	}
+	if body != `{"name": "john"}` {
+		t.Fatalf("Expected the backend to receive the body got %q", body)
+	}
+
+	// other services aren't signed and forged signatures are dropped
+	send("go.micro.api.other", "{}")
+	if len(received.Header.Get(signatureHeader)) > 0 {
+		t.Fatalf("Expected no signature got %s", received.Header.Get(signatureHeader))
+	}
+
+	// bodies too large to sign are rejected
+	if w := send("go.micro.api.greeter", strings.Repeat("a", 17)); w.Code != http.StatusRequestEntityTooLarge || received != nil {
+		t.Fatalf("Expected status 413 got %d", w.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+// sizeRoute routes requests under the path prefix with bodies larger
+// than the threshold to a different service e.g an upload tier
+type sizeRoute struct {
+	prefix    string
+	threshold int64
+	service   string
+}
+
+// parseSizeRoutes parses routes in the format prefix:threshold:service
+func parseSizeRoutes(list []string) ([]sizeRoute, error) {
+	var routes []sizeRoute
+	for _, s := range list {
+		parts := strings.Split(s, ":")
+		if len(parts) != 3 || len(parts[0]) == 0 || len(parts[2]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid size route, expected prefix:threshold:service", s)
+		}
+		threshold, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || threshold < 0 {
+			return nil, fmt.Errorf("%s is not a valid size route threshold", parts[1])
+		}
+		routes = append(routes, sizeRoute{
+			prefix:    parts[0],
+			threshold: threshold,
+			service:   parts[2],
+		})
+	}
+	return routes, nil
+}
+
+// sizeResolver sends large requests to the service of the first matching
+// size route. Requests of unknown length e.g chunked uploads are treated
+// as large or small depending on the configured default.
+type sizeResolver struct {
+	resolver.Resolver
+	routes []sizeRoute
+	// treat requests without a content length as large
+	unknownLarge bool
+}
+
+func newSizeResolver(r resolver.Resolver, routes []sizeRoute, unknownLarge bool) *sizeResolver {
+	return &sizeResolver{
+		Resolver:     r,
+		routes:       routes,
+		unknownLarge: unknownLarge,
+	}
+}
+
+// large determines whether the request body exceeds the threshold
+func (s *sizeResolver) large(req *http.Request, threshold int64) bool {
+	if req.ContentLength < 0 {
+		return s.unknownLarge
+	}
+	return req.ContentLength > threshold
+}
+
+func (s *sizeResolver) Resolve(req *http.Request) (*resolver.Endpoint, error) {
+	ep, err := s.Resolver.Resolve(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, route := range s.routes {
+		if !strings.HasPrefix(req.URL.Path, route.prefix) {
+			continue
+		}
+		if s.large(req, route.threshold) {
+			ep.Name = route.service
+		}
+		break
+	}
+
+	return ep, nil
+}
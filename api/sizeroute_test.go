@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+type testResolver struct{}
+
+func (testResolver) Resolve(r *http.Request) (*resolver.Endpoint, error) {
+	return &resolver.Endpoint{Name: "go.micro.api.files", Path: r.URL.Path}, nil
+}
+
+func (testResolver) String() string {
+	return "test"
+}
+
+func TestSizeResolver(t *testing.T) {
+	routes, err := parseSizeRoutes([]string{"/files/upload:1024:go.micro.api.upload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		path         string
+		length       int64
+		unknownLarge bool
+		service      string
+	}{
+		{"/files/upload", 10, false, "go.micro.api.files"},
+		{"/files/upload", 1024, false, "go.micro.api.files"},
+		{"/files/upload", 1025, false, "go.micro.api.upload"},
+		{"/files/list", 2048, false, "go.micro.api.files"},
+		{"/files/upload", -1, false, "go.micro.api.files"},
+		{"/files/upload", -1, true, "go.micro.api.upload"},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("POST", d.path, strings.NewReader(""))
+		r.ContentLength = d.length
+
+		ep, err := newSizeResolver(testResolver{}, routes, d.unknownLarge).Resolve(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ep.Name != d.service {
+			t.Fatalf("Expected %s for %s of length %d got %s", d.service, d.path, d.length, ep.Name)
+		}
+	}
+
+	for _, s := range []string{"/upload", "/upload:big:go.micro.api.upload", ":10:go.micro.api.upload"} {
+		if _, err := parseSizeRoutes([]string{s}); err == nil {
+			t.Fatalf("Expected error parsing %s", s)
+		}
+	}
+}
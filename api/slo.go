@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sloSlots is the number of slots the rolling window is divided into, the
+// oldest slot being dropped as the window moves on
+const sloSlots = 60
+
+// parseSLOThresholds parses latency objectives in the format service=duration
+func parseSLOThresholds(list []string) (map[string]time.Duration, error) {
+	thresholds := make(map[string]time.Duration)
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid slo, expected service=duration", s)
+		}
+		d, err := time.ParseDuration(parts[1])
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s is not a valid slo threshold", parts[1])
+		}
+		thresholds[parts[0]] = d
+	}
+	return thresholds, nil
+}
+
+// sloSlot counts the requests served during a slot of the window
+type sloSlot struct {
+	// index of the slot since the epoch, telling apart stale counts
+	index int64
+	total int
+	met   int
+}
+
+// sloTracker counts the requests to a service served within its threshold
+// over a rolling window
+type sloTracker struct {
+	threshold time.Duration
+	slot      time.Duration
+
+	sync.Mutex
+	slots [sloSlots]sloSlot
+}
+
+func (t *sloTracker) observe(now time.Time, d time.Duration, failed bool) {
+	index := now.UnixNano() / int64(t.slot)
+
+	t.Lock()
+	defer t.Unlock()
+
+	s := &t.slots[index%sloSlots]
+	if s.index != index {
+		*s = sloSlot{index: index}
+	}
+	s.total++
+	if !failed && d <= t.threshold {
+		s.met++
+	}
+}
+
+// compliance returns the fraction of requests in the window served within
+// the threshold, 1 if there were none
+func (t *sloTracker) compliance(now time.Time) float64 {
+	index := now.UnixNano() / int64(t.slot)
+
+	t.Lock()
+	defer t.Unlock()
+
+	var total, met int
+	for _, s := range t.slots {
+		if s.index > index-sloSlots && s.index <= index {
+			total += s.total
+			met += s.met
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(met) / float64(total)
+}
+
+// slo tracks whether services meet their latency objective, exporting the
+// fraction of requests served within the threshold over a rolling window.
+// Failed requests count against the objective however fast they are.
+type slo struct {
+	services map[string]*sloTracker
+}
+
+func newSLO(reg prometheus.Registerer, thresholds map[string]time.Duration, window time.Duration) *slo {
+	s := &slo{services: make(map[string]*sloTracker)}
+	for name, threshold := range thresholds {
+		t := &sloTracker{threshold: threshold, slot: window / sloSlots}
+		s.services[name] = t
+
+		labels := prometheus.Labels{"service": name}
+		reg.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace:   "micro",
+				Subsystem:   "api",
+				Name:        "slo_compliance_ratio",
+				Help:        "Fraction of requests served within the latency objective over the window",
+				ConstLabels: labels,
+			}, func() float64 { return t.compliance(time.Now()) }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Namespace:   "micro",
+				Subsystem:   "api",
+				Name:        "slo_threshold_seconds",
+				Help:        "Latency objective of the service",
+				ConstLabels: labels,
+			}, func() float64 { return t.threshold.Seconds() }),
+		)
+	}
+	return s
+}
+
+func (s *slo) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sr, r)
+
+		// the service is only known once the request is resolved
+		t, ok := s.services[service(r)]
+		if !ok {
+			return
+		}
+		now := time.Now()
+		t.observe(now, now.Sub(start), sr.status >= 500)
+	})
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSLOTracker(t *testing.T) {
+	tr := &sloTracker{threshold: 100 * time.Millisecond, slot: time.Second}
+	now := time.Unix(1000, 0)
+
+	if c := tr.compliance(now); c != 1 {
+		t.Fatalf("Expected compliance 1 without requests got %v", c)
+	}
+
+	tr.observe(now, 50*time.Millisecond, false)
+	tr.observe(now, 150*time.Millisecond, false)
+	tr.observe(now.Add(time.Second), 10*time.Millisecond, true)
+	tr.observe(now.Add(time.Second), 10*time.Millisecond, false)
+
+	if c := tr.compliance(now.Add(time.Second)); c != 0.5 {
+		t.Fatalf("Expected compliance 0.5 got %v", c)
+	}
+
+	// the first slot drops out of the window
+	if c := tr.compliance(now.Add(sloSlots * time.Second)); c != 0.5 {
+		t.Fatalf("Expected compliance 0.5 of the last slot got %v", c)
+	}
+	if c := tr.compliance(now.Add((sloSlots + 1) * time.Second)); c != 1 {
+		t.Fatalf("Expected compliance 1 once the window moved on got %v", c)
+	}
+
+	// a slot reused by a later window starts counting again
+	tr.observe(now.Add(sloSlots*time.Second), 150*time.Millisecond, false)
+	if c := tr.compliance(now.Add(sloSlots * time.Second)); c != 1.0/3 {
+		t.Fatalf("Expected compliance 1/3 with the slot reused got %v", c)
+	}
+}
+
+func TestSLOHandler(t *testing.T) {
+	thresholds, err := parseSLOThresholds([]string{"go.micro.api.foo=1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSLO(prometheus.NewRegistry(), thresholds, time.Hour)
+
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// resolved as the auth wrapper does
+		ep := &resolver.Endpoint{Name: r.URL.Path[1:]}
+		*r = *r.Clone(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+		if r.URL.Query().Get("fail") == "true" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	for _, url := range []string{"/go.micro.api.foo", "/go.micro.api.foo?fail=true", "/go.micro.api.bar"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+	}
+
+	if c := s.services["go.micro.api.foo"].compliance(time.Now()); c != 0.5 {
+		t.Fatalf("Expected compliance 0.5 counting the failure got %v", c)
+	}
+
+	for _, v := range []string{"go.micro.api.foo=fast", "go.micro.api.foo=-1s", "=1s"} {
+		if _, err := parseSLOThresholds([]string{v}); err == nil {
+			t.Fatalf("Expected error parsing %s", v)
+		}
+	}
+}
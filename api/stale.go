@@ -0,0 +1,352 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// max number of responses kept to serve when a backend fails
+	staleMaxEntries = 1000
+	// max size of a response body kept
+	staleMaxBody = 1 << 20
+)
+
+// staleEntry is a successful response kept to serve on backend failure.
+// Responses varying by request headers are kept under the key of their
+// variant, the key of the request holding an entry with the names of the
+// headers the variants are keyed by.
+type staleEntry struct {
+	header http.Header
+	body   []byte
+	stored time.Time
+	// names of the headers the response varies by
+	vary []string
+}
+
+// staleWriter holds back error responses which can be replaced by a stale
+// response and keeps a copy of successful ones
+type staleWriter struct {
+	http.ResponseWriter
+	header http.Header
+	wrote  bool
+	// an error held back to serve the stale response instead
+	failed bool
+	// whether a stale response is available
+	stale bool
+	// copy of a successful response body, nil if it isn't kept
+	body *bytes.Buffer
+}
+
+// Header returns the headers held back until the response is committed,
+// those of the response after so trailers set once the body is written
+// reach the client
+func (w *staleWriter) Header() http.Header {
+	if w.wrote && !w.failed {
+		return w.ResponseWriter.Header()
+	}
+	return w.header
+}
+
+func (w *staleWriter) WriteHeader(code int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+
+	if code >= 500 && w.stale {
+		w.failed = true
+		return
+	}
+
+	if code == http.StatusOK && shareable(w.header) {
+		w.body = new(bytes.Buffer)
+	}
+
+	dst := w.ResponseWriter.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// shareable determines whether a response can be served to other clients.
+// Responses varying by anything but request headers aren't, as they can't
+// be keyed by the request.
+func shareable(hdr http.Header) bool {
+	cc := strings.ToLower(hdr.Get("Cache-Control"))
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "private") {
+		return false
+	}
+	if len(hdr.Get("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, name := range varyHeaders(hdr) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// varyHeaders returns the canonical names of the headers in the Vary
+// header of the response, sorted so the order they're listed in doesn't
+// change the key of the variant
+func varyHeaders(hdr http.Header) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, v := range hdr["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if len(name) == 0 || seen[name] {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// variant returns the key of the variant of the response to the request,
+// made of the values of the headers it varies by, absent headers included
+// so a request without one doesn't get a variant for a value
+func variant(key string, names []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for _, name := range names {
+		b.WriteString("\n")
+		b.WriteString(name)
+		if v, ok := r.Header[name]; ok {
+			b.WriteString(": ")
+			b.WriteString(strings.Join(v, ", "))
+		}
+	}
+	return b.String()
+}
+
+func (w *staleWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	// drop the body of errors replaced by the stale response
+	if w.failed {
+		return len(b), nil
+	}
+	if w.body != nil {
+		if w.body.Len()+len(b) > staleMaxBody {
+			w.body = nil
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *staleWriter) Flush() {
+	if w.failed || !w.wrote {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// staleDefaultMethods are the methods cached for paths without a policy
+var staleDefaultMethods = []string{"GET"}
+
+// loadStaleMethods reads the methods cached per path prefix from a json file
+// e.g {"/search": ["GET", "POST"]} so read only POST endpoints are cached
+func loadStaleMethods(file string) (map[string][]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods map[string][]string
+	if err := json.Unmarshal(b, &methods); err != nil {
+		return nil, err
+	}
+
+	for prefix, list := range methods {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("%s is not a valid path prefix", prefix)
+		}
+		for i, m := range list {
+			if len(m) == 0 || strings.ContainsAny(m, " \t/") {
+				return nil, fmt.Errorf("%s is not a valid method", m)
+			}
+			list[i] = strings.ToUpper(m)
+		}
+	}
+
+	return methods, nil
+}
+
+// staleCache serves the last successful response of a GET request when the
+// backend fails with a server error, provided it's no older than the max
+// age. Stale responses carry a Warning header. Requests with credentials or
+// cookies and private or per user responses aren't cached so responses
+// aren't shared between users. The methods cached can be set per path
+// prefix, with requests keyed by their body too for methods other than GET.
+// Responses with a Vary header are keyed by the values of the request
+// headers it names so clients don't get a variant they didn't ask for e.g
+// a gzipped body without accepting gzip.
+type staleCache struct {
+	maxAge time.Duration
+	// methods cached by path prefix
+	methods map[string][]string
+
+	sync.RWMutex
+	entries map[string]*staleEntry
+}
+
+func newStaleCache(maxAge time.Duration, methods map[string][]string) *staleCache {
+	return &staleCache{
+		maxAge:  maxAge,
+		methods: methods,
+		entries: make(map[string]*staleEntry),
+	}
+}
+
+// cacheable determines whether the method is cached for the path, by the
+// policy of the longest prefix matched
+func (c *staleCache) cacheable(method, path string) bool {
+	var match string
+	for prefix := range c.methods {
+		if len(prefix) > len(match) && hasPathPrefix(path, prefix) {
+			match = prefix
+		}
+	}
+
+	methods := staleDefaultMethods
+	if len(match) > 0 {
+		methods = c.methods[match]
+	}
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the key of the request, hashing the body into it for methods
+// other than GET. Requests with bodies too large to keep aren't cached.
+func (c *staleCache) key(r *http.Request) (string, bool) {
+	key := r.Host + r.URL.RequestURI()
+	if r.Method == "GET" {
+		return key, true
+	}
+	key = r.Method + " " + key
+	if r.Body == nil || r.Body == http.NoBody {
+		return key, true
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(staleMaxBody)+1))
+	// the body is read again by the backend
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+	if err != nil || len(b) > staleMaxBody {
+		return "", false
+	}
+
+	sum := sha256.Sum256(b)
+	return key + " " + hex.EncodeToString(sum[:]), true
+}
+
+// get returns the response of the request no older than the max age
+func (c *staleCache) get(key string) (*staleEntry, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.stored) > c.maxAge {
+		return nil, false
+	}
+	return e, true
+}
+
+// put keeps the response of the request, pruning expired responses when full
+func (c *staleCache) put(key string, e *staleEntry) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= staleMaxEntries {
+		for k, v := range c.entries {
+			if time.Since(v.stored) > c.maxAge {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= staleMaxEntries {
+			return
+		}
+	}
+	c.entries[key] = e
+}
+
+func (c *staleCache) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.cacheable(r.Method, r.URL.Path) || len(r.Header.Get("Authorization")) > 0 || len(r.Header.Get("Cookie")) > 0 || len(r.Header.Get("Upgrade")) > 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		key, ok := c.key(r)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		e, stale := c.get(key)
+		if stale && e.vary != nil {
+			e, stale = c.get(variant(key, e.vary, r))
+		}
+
+		sw := &staleWriter{
+			ResponseWriter: w,
+			header:         make(http.Header),
+			stale:          stale,
+		}
+		h.ServeHTTP(sw, r)
+
+		if sw.failed {
+			dst := w.Header()
+			for k, v := range e.header {
+				dst[k] = v
+			}
+			dst.Set("Age", strconv.Itoa(int(time.Since(e.stored).Seconds())))
+			dst.Set("Warning", `110 - "Response is Stale"`)
+			w.WriteHeader(http.StatusOK)
+			w.Write(e.body)
+			return
+		}
+
+		if sw.body == nil {
+			return
+		}
+		e = &staleEntry{
+			header: sw.header,
+			body:   sw.body.Bytes(),
+			stored: time.Now(),
+		}
+		if names := varyHeaders(sw.header); len(names) > 0 {
+			c.put(key, &staleEntry{stored: e.stored, vary: names})
+			key = variant(key, names, r)
+		}
+		c.put(key, e)
+	})
+}
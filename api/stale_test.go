@@ -0,0 +1,261 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStaleCache(t *testing.T) {
+	var down bool
+	h := newStaleCache(time.Minute, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			http.Error(w, "backend unavailable", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"msg": "` + r.URL.Path + `"}`))
+	}))
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	if w := request("GET", "/greeter"); w.Code != http.StatusOK || len(w.Header().Get("Warning")) > 0 {
+		t.Fatalf("Expected fresh response got %d %v", w.Code, w.Header())
+	}
+
+	down = true
+
+	w := request("GET", "/greeter")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected stale response got %d", w.Code)
+	}
+	if w.Body.String() != `{"msg": "/greeter"}` {
+		t.Fatalf("Expected stale body got %s", w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected stale headers got %v", w.Header())
+	}
+	if len(w.Header().Get("Warning")) == 0 {
+		t.Fatal("Expected warning header on stale response")
+	}
+
+	// nothing to serve for requests never answered or not cacheable
+	if w := request("GET", "/other"); w.Code != http.StatusBadGateway {
+		t.Fatalf("Expected error without a stale response got %d", w.Code)
+	}
+	if w := request("POST", "/greeter"); w.Code != http.StatusBadGateway {
+		t.Fatalf("Expected error for POST got %d", w.Code)
+	}
+
+	// responses older than the max age aren't served
+	c := newStaleCache(time.Minute, nil)
+	c.put("example.com/greeter", &staleEntry{stored: time.Now().Add(-time.Hour)})
+	if _, ok := c.get("example.com/greeter"); ok {
+		t.Fatal("Expected expired response not to be served")
+	}
+}
+
+func TestStaleCachePrivate(t *testing.T) {
+	var down bool
+	h := newStaleCache(time.Minute, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			http.Error(w, "backend unavailable", http.StatusBadGateway)
+			return
+		}
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/session":
+			w.Header().Set("Set-Cookie", "session=123")
+		case "/vary":
+			w.Header().Set("Vary", "*")
+		}
+		w.Write([]byte(`{"user": "john"}`))
+	}))
+
+	testData := []struct {
+		path   string
+		cookie string
+	}{
+		// requests with cookies may get a response for the user
+		{"/profile", "session=123"},
+		{"/private", ""},
+		{"/session", ""},
+		{"/vary", ""},
+	}
+
+	request := func(path, cookie string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if len(cookie) > 0 {
+			r.Header.Set("Cookie", cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, d := range testData {
+		down = false
+		request(d.path, d.cookie)
+
+		// another client without the cookie doesn't get the response
+		down = true
+		if w := request(d.path, ""); w.Code != http.StatusBadGateway {
+			t.Fatalf("Expected %s not to be served stale got %d", d.path, w.Code)
+		}
+	}
+}
+
+func TestStaleCacheVary(t *testing.T) {
+	var down bool
+	h := newStaleCache(time.Minute, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			http.Error(w, "backend unavailable", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Vary", "accept-language, Accept-Encoding")
+		body := "hello " + r.Header.Get("Accept-Language")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			body += " gzip"
+		}
+		w.Write([]byte(body))
+	}))
+
+	request := func(encoding, language string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/greeter", nil)
+		if len(encoding) > 0 {
+			r.Header.Set("Accept-Encoding", encoding)
+		}
+		if len(language) > 0 {
+			r.Header.Set("Accept-Language", language)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// variants cached while the backend is up
+	request("gzip", "en")
+	request("", "en")
+	request("gzip", "fr")
+
+	down = true
+
+	testData := []struct {
+		encoding string
+		language string
+		body     string
+	}{
+		{"gzip", "en", "hello en gzip"},
+		{"", "en", "hello en"},
+		{"gzip", "fr", "hello fr gzip"},
+		// never answered
+		{"", "fr", ""},
+		{"gzip", "de", ""},
+		{"br", "en", ""},
+	}
+
+	for _, d := range testData {
+		w := request(d.encoding, d.language)
+		if len(d.body) == 0 {
+			if w.Code != http.StatusBadGateway {
+				t.Fatalf("Expected no stale response for %q %q got %d %s", d.encoding, d.language, w.Code, w.Body.String())
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || w.Body.String() != d.body {
+			t.Fatalf("Expected stale response %s for %q %q got %d %s", d.body, d.encoding, d.language, w.Code, w.Body.String())
+		}
+		if gzip := w.Header().Get("Content-Encoding") == "gzip"; gzip != strings.Contains(d.encoding, "gzip") {
+			t.Fatalf("Expected gzip %v for %q got %v", !gzip, d.encoding, w.Header())
+		}
+	}
+}
+
+func TestStaleCacheMethods(t *testing.T) {
+	methods := map[string][]string{
+		"/search":        {"GET", "POST"},
+		"/search/export": {},
+		"/greeter":       {"HEAD"},
+	}
+
+	var down bool
+	h := newStaleCache(time.Minute, methods).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			http.Error(w, "backend unavailable", http.StatusBadGateway)
+			return
+		}
+		// the backend reads the whole body
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + string(b)))
+	}))
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	testData := []struct {
+		method string
+		path   string
+		body   string
+		cached bool
+	}{
+		{"POST", "/search", `{"q": "foo"}`, true},
+		{"GET", "/search/users", "", true},
+		{"POST", "/search/export", "", false},
+		{"GET", "/greeter", "", false},
+		{"HEAD", "/greeter", "", true},
+		{"POST", "/other", "", false},
+	}
+
+	for _, d := range testData {
+		down = false
+		if w := request(d.method, d.path, d.body); w.Body.String() != d.method+" "+d.body {
+			t.Fatalf("Expected the body passed to the backend got %s", w.Body.String())
+		}
+
+		down = true
+		if w := request(d.method, d.path, d.body); (w.Code == http.StatusOK) != d.cached {
+			t.Fatalf("Expected %s %s cached %v got %d", d.method, d.path, d.cached, w.Code)
+		}
+	}
+
+	// requests are keyed by their body
+	if w := request("POST", "/search", `{"q": "bar"}`); w.Code != http.StatusBadGateway {
+		t.Fatalf("Expected no stale response for another body got %d", w.Code)
+	}
+}
+
+func TestStaleCacheTrailers(t *testing.T) {
+	gateway := httptest.NewServer(newStaleCache(time.Minute, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("body"))
+		w.Header().Set("Grpc-Status", "0")
+	})))
+	defer gateway.Close()
+
+	// the second response is served while a stale one is kept
+	for i := 0; i < 2; i++ {
+		rsp, err := http.Get(gateway.URL + "/greeter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		// trailers are set once the body is written
+		if v := rsp.Trailer.Get("Grpc-Status"); v != "0" {
+			t.Fatalf("Expected the Grpc-Status trailer got %q", v)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// loadStatusRewrites reads the status code rewrites from a json file
+// mapping path prefixes to the codes rewritten e.g
+//
+//	{"/legacy": {"422": 400}}
+func loadStatusRewrites(file string) (map[string]map[int]int, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules map[string]map[string]int
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, err
+	}
+
+	rewrites := make(map[string]map[int]int)
+	for prefix, codes := range rules {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("%s is not a valid path prefix", prefix)
+		}
+		rewrites[prefix] = make(map[int]int)
+		for from, to := range codes {
+			code, err := strconv.Atoi(from)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("%s is not a valid status code", from)
+			}
+			if to < 100 || to > 599 {
+				return nil, fmt.Errorf("%d is not a valid status code", to)
+			}
+			rewrites[prefix][code] = to
+		}
+	}
+
+	return rewrites, nil
+}
+
+// statusWriter rewrites the status code of the response, keeping the body
+type statusWriter struct {
+	http.ResponseWriter
+	codes       map[int]int
+	path        string
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if to, ok := w.codes[code]; ok {
+		log.Debugf("Rewriting status %d of %s to %d", code, w.path, to)
+		code = to
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// statusRewriteHandler rewrites the status codes of responses with the
+// rules of the longest path prefix matching the request, e.g to map the
+// status conventions of legacy backends to those clients expect
+func statusRewriteHandler(rewrites map[string]map[int]int, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var match string
+		for prefix := range rewrites {
+			if len(prefix) > len(match) && hasPathPrefix(r.URL.Path, prefix) {
+				match = prefix
+			}
+		}
+		if len(match) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		h.ServeHTTP(&statusWriter{ResponseWriter: w, codes: rewrites[match], path: r.URL.Path}, r)
+	})
+}
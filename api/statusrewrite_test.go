@@ -0,0 +1,73 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatusRewrite(t *testing.T) {
+	rewrites := map[string]map[int]int{
+		"/legacy":       {422: 400, 200: 201},
+		"/legacy/users": {404: 410},
+	}
+
+	testData := []struct {
+		path string
+		code int
+		want int
+	}{
+		{"/legacy/orders", 422, 400},
+		{"/legacy/orders", 500, 500},
+		// writing the body implies a 200
+		{"/legacy/orders", 0, 201},
+		// the longest prefix applies
+		{"/legacy/users", 404, 410},
+		{"/legacy/users", 422, 422},
+		{"/other", 422, 422},
+	}
+
+	for _, d := range testData {
+		h := statusRewriteHandler(rewrites, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if d.code > 0 {
+				w.WriteHeader(d.code)
+			}
+			w.Write([]byte("body"))
+		}))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", d.path, nil))
+
+		if w.Code != d.want || w.Body.String() != "body" {
+			t.Fatalf("Expected %d for %d from %s got %d %s", d.want, d.code, d.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestLoadStatusRewrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rewrites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testData := map[string]bool{
+		`{"/legacy": {"422": 400}}`: true,
+		`{"legacy": {"422": 400}}`:  false,
+		`{"/legacy": {"4xx": 400}}`: false,
+		`{"/legacy": {"422": 42}}`:  false,
+	}
+
+	for config, valid := range testData {
+		file := filepath.Join(dir, "rewrites.json")
+		if err := ioutil.WriteFile(file, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadStatusRewrites(file); (err == nil) != valid {
+			t.Fatalf("Expected %s valid %v got %v", config, valid, err)
+		}
+	}
+}
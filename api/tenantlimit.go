@@ -0,0 +1,148 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/auth"
+)
+
+// tenantLimit is the requests per second and max burst of a tenant
+type tenantLimit struct {
+	rate  float64
+	burst int
+}
+
+// parseTenantLimits parses limits in the format tenant=rate or
+// tenant=rate:burst
+func parseTenantLimits(list []string) (map[string]tenantLimit, error) {
+	limits := make(map[string]tenantLimit)
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid tenant limit, expected tenant=rate:burst", s)
+		}
+		values := strings.SplitN(parts[1], ":", 2)
+		rate, err := strconv.ParseFloat(values[0], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("%s is not a valid tenant rate", values[0])
+		}
+		var burst int
+		if len(values) == 2 {
+			burst, err = strconv.Atoi(values[1])
+			if err != nil || burst < 0 {
+				return nil, fmt.Errorf("%s is not a valid tenant burst", values[1])
+			}
+		}
+		limits[parts[0]] = tenantLimit{rate: rate, burst: burst}
+	}
+	return limits, nil
+}
+
+// tenantLimits throttles the requests of each tenant across services, so
+// a noisy tenant can't use up the quota of the others. Tenants are keyed
+// by a header or by the namespace the request resolves to, e.g per domain.
+// Requests without a tenant aren't limited.
+type tenantLimits struct {
+	// header keying the tenant, the namespace if empty
+	header string
+	// limit of tenants without their own
+	limit   tenantLimit
+	tenants map[string]tenantLimit
+	// names of the limit, remaining and reset headers
+	headers []string
+
+	sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newTenantLimits(header string, limit tenantLimit, tenants map[string]tenantLimit, headers []string) *tenantLimits {
+	return &tenantLimits{
+		header:  header,
+		limit:   limit,
+		tenants: tenants,
+		headers: headers,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// tenant returns the tenant making the request
+func (l *tenantLimits) tenant(r *http.Request) string {
+	if len(l.header) > 0 {
+		return r.Header.Get(l.header)
+	}
+	// set by the auth wrapper, replacing any value sent by the client
+	return r.Header.Get(auth.NamespaceKey)
+}
+
+// allow takes a token from the bucket of the tenant, returning its limit,
+// the tokens remaining, the time until the bucket is full again and until
+// the next token. Tenants without a limit are always allowed.
+func (l *tenantLimits) allow(tenant string) (bool, tenantLimit, int, time.Duration, time.Duration) {
+	limit, ok := l.tenants[tenant]
+	if !ok {
+		limit = l.limit
+	}
+	if limit.rate <= 0 {
+		return true, limit, 0, 0, 0
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[tenant]
+	if !ok {
+		if len(l.buckets) >= rateLimitMaxClients {
+			l.prune(now)
+		}
+		b = &bucket{rate: limit.rate, size: float64(limit.burst), last: now}
+		b.tokens = b.burst()
+		l.buckets[tenant] = b
+	}
+	allowed := b.take(now)
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return allowed, limit, int(b.tokens), b.reset(), wait
+}
+
+// prune drops the buckets which refilled, a new bucket behaves the same
+func (l *tenantLimits) prune(now time.Time) {
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst() {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+func (l *tenantLimits) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := l.tenant(r)
+		if len(tenant) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ok, limit, remaining, reset, wait := l.allow(tenant)
+		if limit.rate <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if len(l.headers) == 3 {
+			w.Header().Set(l.headers[0], strconv.FormatFloat(limit.rate, 'f', -1, 64))
+			w.Header().Set(l.headers[1], strconv.Itoa(remaining))
+			w.Header().Set(l.headers[2], strconv.Itoa(int(math.Ceil(reset.Seconds()))))
+		}
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/auth"
+)
+
+func TestParseTenantLimits(t *testing.T) {
+	limits, err := parseTenantLimits([]string{"foo=10", "bar=0.5:3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits["foo"] != (tenantLimit{rate: 10}) || limits["bar"] != (tenantLimit{rate: 0.5, burst: 3}) {
+		t.Fatalf("Unexpected limits %v", limits)
+	}
+
+	for _, s := range []string{"foo", "=1", "foo=bar", "foo=0", "foo=1:-1"} {
+		if _, err := parseTenantLimits([]string{s}); err == nil {
+			t.Fatalf("Expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestTenantLimits(t *testing.T) {
+	tenants := map[string]tenantLimit{"big": {rate: 1, burst: 3}}
+	l := newTenantLimits("", tenantLimit{rate: 1, burst: 1}, tenants, RateLimitHeaders)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(tenant string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		if len(tenant) > 0 {
+			r.Header.Set(auth.NamespaceKey, tenant)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// a tenant gets its burst then is throttled
+	if w := send("small"); w.Code != http.StatusOK {
+		t.Fatalf("Expected the first request to be allowed got %d", w.Code)
+	}
+	w := send("small")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" || w.Header().Get("X-RateLimit-Limit") != "1" {
+		t.Fatalf("Unexpected rate limit headers %v", w.Header())
+	}
+
+	// other tenants keep their own quota, with their own limit
+	for i := 0; i < 3; i++ {
+		if w := send("big"); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d within the tenant burst to be allowed got %d", i, w.Code)
+		}
+	}
+	if w := send("big"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 got %d", w.Code)
+	}
+
+	// requests without a tenant aren't limited
+	for i := 0; i < 3; i++ {
+		if w := send(""); w.Code != http.StatusOK || len(w.Header().Get("X-RateLimit-Limit")) > 0 {
+			t.Fatalf("Expected requests without a tenant to be unlimited got %d %v", w.Code, w.Header())
+		}
+	}
+}
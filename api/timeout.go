@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/store"
+)
+
+var (
+	// header the gateway passes the deadline of a request to client
+	// wrappers in as unix nanoseconds, replacing any value sent by the client
+	deadlineHeader = "Micro-Api-Deadline"
+	// prefix of the keys per service timeouts are read from in the store
+	timeoutPrefix = "api/timeout/"
+)
+
+// requestTimeouts cancels requests which take longer than their timeout,
+// the client getting a 504. Services can have their own timeout in the
+// store keyed by the service name under the timeout prefix, e.g
+// api/timeout/go.micro.api.greeter = 30s, 0 disabling it.
+type requestTimeouts struct {
+	timeout time.Duration
+	store   store.Store
+	// how often the timeouts are read from the store
+	interval time.Duration
+
+	sync.RWMutex
+	services map[string]time.Duration
+}
+
+func newRequestTimeouts(timeout time.Duration, st store.Store, interval time.Duration) *requestTimeouts {
+	return &requestTimeouts{
+		timeout:  timeout,
+		store:    st,
+		interval: interval,
+		services: make(map[string]time.Duration),
+	}
+}
+
+// load reads the timeouts of services from the store
+func (t *requestTimeouts) load() error {
+	recs, err := t.store.Read(timeoutPrefix, store.ReadPrefix())
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	services := make(map[string]time.Duration, len(recs))
+	for _, rec := range recs {
+		name := strings.TrimPrefix(rec.Key, timeoutPrefix)
+		d, err := time.ParseDuration(string(rec.Value))
+		if err != nil || d < 0 {
+			log.Warnf("Ignoring timeout %q of service %s", rec.Value, name)
+			continue
+		}
+		services[name] = d
+	}
+
+	t.Lock()
+	t.services = services
+	t.Unlock()
+	return nil
+}
+
+// Start reads the timeouts from the store every interval
+func (t *requestTimeouts) Start() {
+	go func() {
+		tk := time.NewTicker(t.interval)
+		defer tk.Stop()
+		for range tk.C {
+			if err := t.load(); err != nil {
+				log.Errorf("Failed to load request timeouts: %v", err)
+			}
+		}
+	}()
+}
+
+// get returns the timeout of the service, 0 if it has none
+func (t *requestTimeouts) get(service string) time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	if d, ok := t.services[service]; ok {
+		return d
+	}
+	return t.timeout
+}
+
+// Handler sets the deadline of requests on their context and passes it in
+// the deadline header, as the rpc handlers don't pass the request context
+// on to the client.
+func (t *requestTimeouts) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(deadlineHeader)
+
+		// the service is only known once the request is resolved
+		d := t.get(service(r))
+		if d <= 0 || upgrading(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		deadline := time.Now().Add(d)
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+		r.Header.Set(deadlineHeader, strconv.FormatInt(deadline.UnixNano(), 10))
+
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// timeoutClient cancels calls at the deadline of the request they're made
+// for, returning a 504 once it's exceeded. Streams aren't cancelled as they
+// may be long lived e.g websockets.
+type timeoutClient struct {
+	client.Client
+}
+
+func (c *timeoutClient) deadline(ctx context.Context) (time.Time, bool) {
+	v, ok := metadata.Get(ctx, deadlineHeader)
+	if !ok {
+		return time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+func (c *timeoutClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	d, ok := c.deadline(ctx)
+	if !ok {
+		return c.Client.Call(ctx, req, rsp, opts...)
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, d)
+	defer cancel()
+	opts = append(opts[:len(opts):len(opts)], client.WithRequestTimeout(time.Until(d)))
+
+	err := c.Client.Call(ctx, req, rsp, opts...)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return errors.New("go.micro.api", http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+	}
+	return err
+}
+
+// timeoutWrapper returns a client wrapper cancelling calls at the deadline
+// of their request
+func timeoutWrapper(c client.Client) client.Client {
+	return &timeoutClient{Client: c}
+}
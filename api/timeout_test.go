@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/store"
+)
+
+// recordStore returns its records on read
+type recordStore struct {
+	store.Store
+	records []*store.Record
+}
+
+func (s *recordStore) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
+	return s.records, nil
+}
+
+func TestRequestTimeouts(t *testing.T) {
+	st := &recordStore{records: []*store.Record{
+		{Key: timeoutPrefix + "go.micro.api.slow", Value: []byte("1m")},
+		{Key: timeoutPrefix + "go.micro.api.stream", Value: []byte("0s")},
+		{Key: timeoutPrefix + "go.micro.api.bad", Value: []byte("soon")},
+	}}
+	timeouts := newRequestTimeouts(time.Second, st, time.Minute)
+	if err := timeouts.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	var deadline time.Time
+	var header string
+	h := timeouts.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+		header = r.Header.Get(deadlineHeader)
+	}))
+
+	testData := []struct {
+		service string
+		timeout time.Duration
+	}{
+		{"go.micro.api.greeter", time.Second},
+		{"go.micro.api.slow", time.Minute},
+		// services can disable the timeout
+		{"go.micro.api.stream", 0},
+		// invalid timeouts are ignored
+		{"go.micro.api.bad", time.Second},
+	}
+
+	for _, d := range testData {
+		deadline, header = time.Time{}, ""
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.Header.Set(deadlineHeader, "1")
+		r = r.WithContext(context.WithValue(r.Context(), resolver.Endpoint{}, &resolver.Endpoint{Name: d.service}))
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if d.timeout == 0 {
+			if !deadline.IsZero() || len(header) > 0 {
+				t.Fatalf("Expected no deadline for %s got %v %q", d.service, deadline, header)
+			}
+			continue
+		}
+		if left := time.Until(deadline); left <= 0 || left > d.timeout {
+			t.Fatalf("Expected a deadline within %v for %s got %v", d.timeout, d.service, left)
+		}
+		if header != strconv.FormatInt(deadline.UnixNano(), 10) {
+			t.Fatalf("Expected the deadline header to match the context got %q", header)
+		}
+	}
+}
+
+// blockingClient blocks calls until their context is done
+type blockingClient struct {
+	client.Client
+	options client.CallOptions
+}
+
+func (c *blockingClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	for _, o := range opts {
+		o(&c.options)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTimeoutClient(t *testing.T) {
+	bc := &blockingClient{}
+	c := timeoutWrapper(bc)
+
+	deadline := time.Now().Add(10 * time.Millisecond)
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{deadlineHeader: strconv.FormatInt(deadline.UnixNano(), 10)})
+
+	// the call is cancelled at the deadline of the request
+	err := c.Call(ctx, nil, nil)
+	if err == nil || errors.Parse(err.Error()).Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected a 504 got %v", err)
+	}
+	if bc.options.RequestTimeout <= 0 || bc.options.RequestTimeout > 10*time.Millisecond {
+		t.Fatalf("Expected the request timeout to be set from the deadline got %v", bc.options.RequestTimeout)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// timingWriter reports the time taken up to writing the response headers
+type timingWriter struct {
+	http.ResponseWriter
+	header      string
+	start       time.Time
+	wroteHeader bool
+}
+
+func (w *timingWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		// milliseconds
+		took := float64(time.Since(w.start)) / float64(time.Millisecond)
+		w.Header().Set(w.header, fmt.Sprintf("%.3f", took))
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *timingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// timingHandler sets the header to the milliseconds taken by the handler
+// to respond. Wrapping the backend handlers reports the upstream time while
+// wrapping the whole gateway reports the total response time.
+func timingHandler(header string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(&timingWriter{ResponseWriter: w, header: header, start: time.Now()}, r)
+	})
+}
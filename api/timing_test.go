@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTimingHeaders(t *testing.T) {
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 20)
+		w.Write([]byte("hello"))
+	})
+
+	// the upstream time wraps the backend and the response time the gateway
+	h := timingHandler("Micro-Response-Time", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond * 10)
+		timingHandler("Micro-Upstream-Time", backend).ServeHTTP(w, r)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	upstream, err := strconv.ParseFloat(w.Header().Get("Micro-Upstream-Time"), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, err := strconv.ParseFloat(w.Header().Get("Micro-Response-Time"), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upstream < 20 {
+		t.Fatalf("Expected upstream time of at least 20ms got %v", upstream)
+	}
+	if total < upstream+10 {
+		t.Fatalf("Expected response time to include the gateway overhead got %v for upstream %v", total, upstream)
+	}
+	if w.Body.String() != "hello" {
+		t.Fatalf("Expected body to be written got %s", w.Body.String())
+	}
+}
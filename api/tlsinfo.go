@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+)
+
+type tlsInfoKey struct{}
+
+// tlsInfo is the state of the tls connection a request was made over
+type tlsInfo struct {
+	Version     string
+	CipherSuite string
+	ServerName  string
+}
+
+// tlsVersions are the names of the tls versions
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "1.0",
+	tls.VersionTLS11: "1.1",
+	tls.VersionTLS12: "1.2",
+	tls.VersionTLS13: "1.3",
+}
+
+// cipherSuiteNames are the names of the cipher suites by id, including the
+// tls 1.3 suites
+var cipherSuiteNames = func() map[uint16]string {
+	names := map[uint16]string{
+		tls.TLS_AES_128_GCM_SHA256:       "TLS_AES_128_GCM_SHA256",
+		tls.TLS_AES_256_GCM_SHA384:       "TLS_AES_256_GCM_SHA384",
+		tls.TLS_CHACHA20_POLY1305_SHA256: "TLS_CHACHA20_POLY1305_SHA256",
+	}
+	for n, id := range cipherSuites {
+		names[id] = n
+	}
+	return names
+}()
+
+func newTLSInfo(cs *tls.ConnectionState) tlsInfo {
+	info := tlsInfo{
+		Version:     tlsVersions[cs.Version],
+		CipherSuite: cipherSuiteNames[cs.CipherSuite],
+		ServerName:  cs.ServerName,
+	}
+	if len(info.Version) == 0 {
+		info.Version = fmt.Sprintf("0x%04x", cs.Version)
+	}
+	if len(info.CipherSuite) == 0 {
+		info.CipherSuite = fmt.Sprintf("0x%04x", cs.CipherSuite)
+	}
+	return info
+}
+
+// tlsInfoFromContext returns the tls state of the request if made over tls
+func tlsInfoFromContext(ctx context.Context) (tlsInfo, bool) {
+	info, ok := ctx.Value(tlsInfoKey{}).(tlsInfo)
+	return info, ok
+}
+
+// tlsInfoHandler puts the tls state of requests in their context and, if
+// headers is set, passes the version, cipher suite and sni to backends in
+// headers under the prefix. The headers are dropped from requests which
+// didn't come over tls so clients can't spoof them.
+func tlsInfoHandler(prefix string, headers bool, h http.Handler) http.Handler {
+	version := prefix + "Tls-Version"
+	cipher := prefix + "Tls-Cipher-Suite"
+	serverName := prefix + "Tls-Server-Name"
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(version)
+		r.Header.Del(cipher)
+		r.Header.Del(serverName)
+		if r.TLS == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		info := newTLSInfo(r.TLS)
+		if headers {
+			r.Header.Set(version, info.Version)
+			r.Header.Set(cipher, info.CipherSuite)
+			if len(info.ServerName) > 0 {
+				r.Header.Set(serverName, info.ServerName)
+			}
+		}
+
+		// update the request in place so the endpoint the auth wrapper sets
+		// on it reaches the access log further out
+		*r = *r.WithContext(context.WithValue(r.Context(), tlsInfoKey{}, info))
+		h.ServeHTTP(w, r)
+	})
+}
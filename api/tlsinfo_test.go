@@ -0,0 +1,93 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	goauth "github.com/micro/go-micro/v2/auth"
+	"github.com/micro/micro/v2/api/auth"
+	"github.com/micro/micro/v2/internal/namespace"
+)
+
+func TestTLSInfoHandler(t *testing.T) {
+	var info tlsInfo
+	var found bool
+	var header http.Header
+	h := tlsInfoHandler("X-Micro-", true, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, found = tlsInfoFromContext(r.Context())
+		header = r.Header
+	}))
+
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.TLS = &tls.ConnectionState{
+		Version:     tls.VersionTLS12,
+		CipherSuite: tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		ServerName:  "api.example.com",
+	}
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	expected := tlsInfo{Version: "1.2", CipherSuite: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", ServerName: "api.example.com"}
+	if !found || info != expected {
+		t.Fatalf("Expected %+v in the context got %+v", expected, info)
+	}
+	if header.Get("X-Micro-Tls-Version") != "1.2" || header.Get("X-Micro-Tls-Cipher-Suite") != expected.CipherSuite || header.Get("X-Micro-Tls-Server-Name") != expected.ServerName {
+		t.Fatalf("Unexpected headers %v", header)
+	}
+
+	// requests without tls can't spoof the headers
+	r = httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("X-Micro-Tls-Version", "1.3")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if found || len(header.Get("X-Micro-Tls-Version")) > 0 {
+		t.Fatalf("Expected no tls info without tls got %+v %v", info, header)
+	}
+
+	// the tls 1.3 suites are named
+	if info := newTLSInfo(&tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}); info.Version != "1.3" || info.CipherSuite != "TLS_AES_128_GCM_SHA256" {
+		t.Fatalf("Unexpected tls 1.3 info %+v", info)
+	}
+}
+
+// allowAuth allows every account access to every resource
+type allowAuth struct {
+	goauth.Auth
+}
+
+func (allowAuth) Inspect(token string) (*goauth.Account, error) {
+	return &goauth.Account{}, nil
+}
+
+func (allowAuth) Verify(acc *goauth.Account, res *goauth.Resource) error {
+	return nil
+}
+
+func TestTLSInfoAccessLog(t *testing.T) {
+	defaultAuth := goauth.DefaultAuth
+	goauth.DefaultAuth = allowAuth{}
+	defer func() { goauth.DefaultAuth = defaultAuth }()
+
+	// the chain the server builds, the access log outside the wrappers
+	var buf bytes.Buffer
+	var found bool
+	h := auth.Wrapper(testResolver{}, namespace.NewResolver("api", "go.micro"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, found = tlsInfoFromContext(r.Context())
+	}))
+	h = tlsInfoHandler("X-Micro-", false, h)
+	h = newAccessLog(&buf, "json", false, []string{"go.micro.api.files"}, nil).Handler(h)
+
+	r := httptest.NewRequest("GET", "/files/list", nil)
+	r.TLS = &tls.ConnectionState{Version: tls.VersionTLS12}
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !found {
+		t.Fatal("Expected the tls info in the context of the handler")
+	}
+	// the endpoint resolved by auth is seen by the access log
+	if !strings.Contains(buf.String(), `"service":"go.micro.api.files"`) {
+		t.Fatalf("Expected the request to be logged with its service got %q", buf.String())
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// loadedCert describes a certificate loaded from disk
+type loadedCert struct {
+	CertFile string    `json:"cert_file"`
+	KeyFile  string    `json:"key_file"`
+	Subject  string    `json:"subject,omitempty"`
+	DNSNames []string  `json:"dns_names,omitempty"`
+	NotAfter time.Time `json:"not_after,omitempty"`
+}
+
+// certReloader serves the tls certificate loaded from the cert and key
+// files, replacing it when reloaded e.g once a deployment rotated them.
+// A certificate failing to load leaves the current one in use.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reload loads the certificate from the files
+func (c *certReloader) reload() (*loadedCert, error) {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf = leaf
+
+	c.Lock()
+	c.cert = &cert
+	c.Unlock()
+
+	return &loadedCert{
+		CertFile: c.certFile,
+		KeyFile:  c.keyFile,
+		Subject:  leaf.Subject.String(),
+		DNSNames: leaf.DNSNames,
+		NotAfter: leaf.NotAfter,
+	}, nil
+}
+
+// GetCertificate returns the certificate last loaded, set as the
+// GetCertificate of the tls config
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.cert, nil
+}
+
+// ServeHTTP reloads the certificate on POST, returning the certificates
+// loaded and any errors
+func (c *certReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rsp := struct {
+		Loaded []*loadedCert `json:"loaded"`
+		Errors []string      `json:"errors"`
+	}{
+		Loaded: []*loadedCert{},
+		Errors: []string{},
+	}
+
+	status := http.StatusOK
+	if cert, err := c.reload(); err != nil {
+		log.Errorf("Failed to reload certificate %s: %v", c.certFile, err)
+		rsp.Errors = append(rsp.Errors, err.Error())
+		status = http.StatusInternalServerError
+	} else {
+		log.Infof("Reloaded certificate %s for %s expiring %v", c.certFile, cert.Subject, cert.NotAfter)
+		rsp.Loaded = append(rsp.Loaded, cert)
+	}
+
+	b, err := json.Marshal(rsp)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCert writes a certificate with the serial and its key to the files
+func writeCert(t *testing.T, serial int64, certFile, keyFile string) {
+	cert, key := testCert(t, serial, nil, nil)
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	writeCert(t, 1, certFile, keyFile)
+	c, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial := func() int64 {
+		cert, _ := c.GetCertificate(nil)
+		return cert.Leaf.SerialNumber.Int64()
+	}
+	reload := func(method string) (int, map[string][]json.RawMessage) {
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest(method, "/_tls/reload", nil))
+		rsp := make(map[string][]json.RawMessage)
+		json.Unmarshal(w.Body.Bytes(), &rsp)
+		return w.Code, rsp
+	}
+
+	if s := serial(); s != 1 {
+		t.Fatalf("Expected certificate 1 got %d", s)
+	}
+
+	// a rotated certificate is served once reloaded
+	writeCert(t, 2, certFile, keyFile)
+	if s := serial(); s != 1 {
+		t.Fatalf("Expected certificate 1 until reloaded got %d", s)
+	}
+	if code, rsp := reload("POST"); code != http.StatusOK || len(rsp["loaded"]) != 1 || len(rsp["errors"]) != 0 {
+		t.Fatalf("Expected the certificate to be reloaded got %d %v", code, rsp)
+	}
+	if s := serial(); s != 2 {
+		t.Fatalf("Expected certificate 2 got %d", s)
+	}
+
+	// a broken certificate keeps the current one in use
+	if err := ioutil.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code, rsp := reload("POST"); code != http.StatusInternalServerError || len(rsp["loaded"]) != 0 || len(rsp["errors"]) != 1 {
+		t.Fatalf("Expected the reload to fail got %d %v", code, rsp)
+	}
+	if s := serial(); s != 2 {
+		t.Fatalf("Expected certificate 2 to be kept got %d", s)
+	}
+
+	if code, _ := reload("GET"); code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405 got %d", code)
+	}
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/micro/go-micro/v2/debug/trace"
+)
+
+var (
+	// headers the trace is propagated to backends in
+	traceIDHeader = "Micro-Trace-Id"
+	spanIDHeader  = "Micro-Span-Id"
+	// w3c trace context header, continued from clients and passed on
+	traceparentHeader = "Traceparent"
+)
+
+// parseTraceparent returns the trace and parent span ids of a w3c
+// traceparent header in the format version-trace-parent-flags
+func parseTraceparent(v string) (string, string, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return "", "", false
+	}
+	// later versions may append fields
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false
+	}
+	traceID, parentID := parts[1], parts[2]
+	if !validTraceID(traceID, 32) || !validTraceID(parentID, 16) {
+		return "", "", false
+	}
+	return traceID, parentID, true
+}
+
+// validTraceID determines whether the id is n lowercase hex digits, not all
+// zero as required of w3c trace and span ids
+func validTraceID(id string, n int) bool {
+	if len(id) != n || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// formatTraceparent returns the traceparent header of the sampled span,
+// if its ids are in the w3c format
+func formatTraceparent(traceID, spanID string) (string, bool) {
+	if !validTraceID(traceID, 32) || !validTraceID(spanID, 16) {
+		return "", false
+	}
+	return "00-" + traceID + "-" + spanID + "-01", true
+}
+
+// parseSampleRates parses sampling rates in the format service=rate
+func parseSampleRates(list []string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid sample rate, expected service=rate", s)
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid sample rate between 0 and 1", parts[1])
+		}
+		if err := validRate(rate); err != nil {
+			return nil, err
+		}
+		rates[parts[0]] = rate
+	}
+	return rates, nil
+}
+
+// validRate returns an error if the sample rate isn't between 0 and 1
+func validRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("%v is not a valid sample rate between 0 and 1", rate)
+	}
+	return nil
+}
+
+// loadPathSampleRates reads the sampling rates from a json file mapping
+// path prefixes to rates e.g {"/checkout": 1}
+func loadPathSampleRates(file string) (map[string]float64, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates map[string]float64
+	if err := json.Unmarshal(b, &rates); err != nil {
+		return nil, err
+	}
+
+	for prefix, rate := range rates {
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("%s is not a valid path prefix", prefix)
+		}
+		if err := validRate(rate); err != nil {
+			return nil, err
+		}
+	}
+
+	return rates, nil
+}
+
+// tracer starts a span for a sample of requests once they've been
+// resolved, so the rate can differ per service. Rates set for the path
+// take precedence so critical paths can always be traced.
+type tracer struct {
+	tracer trace.Tracer
+	// default sampling rate
+	rate float64
+	// sampling rates per service
+	rates map[string]float64
+	// sampling rates per path prefix
+	pathRates map[string]float64
+}
+
+func newTracer(t trace.Tracer, rate float64, rates, pathRates map[string]float64) *tracer {
+	return &tracer{
+		tracer:    t,
+		rate:      rate,
+		rates:     rates,
+		pathRates: pathRates,
+	}
+}
+
+// sampleRate returns the rate of the longest prefix of the path, or else of
+// the service
+func (t *tracer) sampleRate(service, path string) float64 {
+	var match string
+	for prefix := range t.pathRates {
+		if len(prefix) > len(match) && hasPathPrefix(path, prefix) {
+			match = prefix
+		}
+	}
+	if len(match) > 0 {
+		return t.pathRates[match]
+	}
+
+	if rate, ok := t.rates[service]; ok {
+		return rate
+	}
+	return t.rate
+}
+
+// sample determines whether a request to the service should be traced
+func (t *tracer) sample(service, path string) bool {
+	rate := t.sampleRate(service, path)
+	return rate > 0 && rand.Float64() < rate
+}
+
+func (t *tracer) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := service(r)
+		if len(name) == 0 || !t.sample(name, r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// continue the trace of the client
+		ctx := r.Context()
+		if traceID, parentID, ok := parseTraceparent(r.Header.Get(traceparentHeader)); ok {
+			ctx = trace.ToContext(ctx, traceID, parentID)
+		}
+
+		ctx, span := t.tracer.Start(ctx, name)
+		if span == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		defer t.tracer.Finish(span)
+
+		span.Type = trace.SpanTypeRequestInbound
+		span.Metadata["service"] = name
+		span.Metadata["method"] = r.Method
+		span.Metadata["path"] = r.URL.Path
+
+		// handlers build the backend call context from the headers
+		r = r.WithContext(ctx)
+		if traceID, spanID, ok := trace.FromContext(ctx); ok {
+			r.Header.Set(traceIDHeader, traceID)
+			r.Header.Set(spanIDHeader, spanID)
+			if v, ok := formatTraceparent(traceID, spanID); ok {
+				r.Header.Set(traceparentHeader, v)
+			}
+		}
+
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sr, r)
+
+		span.Metadata["status"] = strconv.Itoa(sr.status)
+		if sr.status >= 500 {
+			span.Metadata["error"] = http.StatusText(sr.status)
+		}
+	})
+}
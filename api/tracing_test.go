@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/debug/trace"
+)
+
+type testTracer struct {
+	spans []*trace.Span
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, *trace.Span) {
+	span := &trace.Span{Name: name, Trace: "trace", Id: "span", Metadata: make(map[string]string)}
+	return trace.ToContext(ctx, span.Trace, span.Id), span
+}
+
+func (t *testTracer) Finish(s *trace.Span) error {
+	t.spans = append(t.spans, s)
+	return nil
+}
+
+func (t *testTracer) Read(...trace.ReadOption) ([]*trace.Span, error) {
+	return t.spans, nil
+}
+
+func TestTracer(t *testing.T) {
+	rates, err := parseSampleRates([]string{"go.micro.api.broken=1", "go.micro.api.quiet=0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		service string
+		rate    float64
+		traced  bool
+	}{
+		{"go.micro.api.broken", 0, true},
+		{"go.micro.api.quiet", 1, false},
+		{"go.micro.api.other", 1, true},
+		{"go.micro.api.other", 0, false},
+		{"", 1, false},
+	}
+
+	for _, d := range testData {
+		tt := new(testTracer)
+
+		var header string
+		h := newTracer(tt, d.rate, rates, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get(traceIDHeader)
+		}))
+
+		r := httptest.NewRequest("GET", "/foo", nil)
+		if len(d.service) > 0 {
+			ep := &resolver.Endpoint{Name: d.service}
+			r = r.WithContext(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if traced := len(tt.spans) == 1; traced != d.traced {
+			t.Fatalf("Expected %s traced %v at rate %v got %v", d.service, d.traced, d.rate, traced)
+		}
+		if d.traced && header != "trace" {
+			t.Fatalf("Expected trace id header to be propagated got %q", header)
+		}
+	}
+
+	if _, err := parseSampleRates([]string{"go.micro.api.foo=2"}); err == nil {
+		t.Fatal("Expected error parsing rate above 1")
+	}
+}
+
+func TestPathSampleRates(t *testing.T) {
+	rates := map[string]float64{"go.micro.api.shop": 0}
+	pathRates := map[string]float64{"/shop/checkout": 1, "/shop/checkout/preview": 0}
+	tr := newTracer(new(testTracer), 0.5, rates, pathRates)
+
+	testData := []struct {
+		service string
+		path    string
+		rate    float64
+	}{
+		// paths take precedence over the service
+		{"go.micro.api.shop", "/shop/checkout", 1},
+		{"go.micro.api.shop", "/shop/checkout/pay", 1},
+		{"go.micro.api.shop", "/shop/checkout/preview", 0},
+		{"go.micro.api.shop", "/shop/cart", 0},
+		{"go.micro.api.shop", "/shop/checkouts", 0},
+		{"go.micro.api.other", "/other", 0.5},
+	}
+
+	for _, d := range testData {
+		if rate := tr.sampleRate(d.service, d.path); rate != d.rate {
+			t.Fatalf("Expected rate %v for %s %s got %v", d.rate, d.service, d.path, rate)
+		}
+	}
+}
+
+func TestSampleRates(t *testing.T) {
+	for rate, valid := range map[float64]bool{0: true, 0.5: true, 1: true, -0.1: false, 1.5: false, 100: false} {
+		if err := validRate(rate); (err == nil) != valid {
+			t.Fatalf("Expected rate %v valid %v got %v", rate, valid, err)
+		}
+	}
+
+	for list, valid := range map[string]bool{
+		"go.micro.api.greeter=0.1": true,
+		"go.micro.api.greeter=2":   false,
+		"go.micro.api.greeter=-1":  false,
+		"go.micro.api.greeter=foo": false,
+		"go.micro.api.greeter":     false,
+	} {
+		if _, err := parseSampleRates([]string{list}); (err == nil) != valid {
+			t.Fatalf("Expected %s valid %v got %v", list, valid, err)
+		}
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	testData := []struct {
+		header string
+		valid  bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01", false},
+		{"", false},
+	}
+
+	for _, d := range testData {
+		traceID, parentID, ok := parseTraceparent(d.header)
+		if ok != d.valid {
+			t.Fatalf("Expected %q valid %v got %v", d.header, d.valid, ok)
+		}
+		if ok && (traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || parentID != "00f067aa0ba902b7") {
+			t.Fatalf("Unexpected ids %s %s parsed from %q", traceID, parentID, d.header)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+func TestTrailerPropagation(t *testing.T) {
+	// backend conveying its status in trailers e.g grpc-web
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.Header().Set("Content-Type", "application/grpc-web")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("body"))
+		w.Header().Set("Grpc-Status", "0")
+		w.Header().Set("Grpc-Message", "OK")
+		// undeclared trailer
+		w.Header().Set(http.TrailerPrefix+"X-Extra", "extra")
+	}))
+	defer backend.Close()
+
+	service := &api.Service{
+		Name: "go.micro.api.greeter",
+		Services: []*registry.Service{{
+			Name:  "go.micro.api.greeter",
+			Nodes: []*registry.Node{{Id: "greeter-1", Address: strings.TrimPrefix(backend.URL, "http://")}},
+		}},
+	}
+
+	// the http handler and the middleware run() wraps it in, including
+	// the writers holding back responses
+	var h http.Handler = proxyHandler(&testRouter{service: service}, newProxyBufferPool(1024), 1<<20, newBackendDialer(0), randomStrategy)
+	h = redirectHandler(3, h)
+	h = bufferHandler(1024, 2, newAttemptTracker(), h)
+	h = newShedder(time.Second, 1.0, nil).Handler(h)
+	h = expectHandler("gateway", 0, h)
+	h = headHandler("auto", nil, h)
+	h = contentTypeHandler("application/json", true, h)
+	h = newStaleCache(time.Minute, nil).Handler(h)
+	// server wrappers
+	h = responseHeadersHandler(http.Header{"X-Frame-Options": {"DENY"}}, false, h)
+	h = newAccessLog(ioutil.Discard, "combined", true, nil, nil).Handler(h)
+
+	gateway := httptest.NewServer(h)
+	defer gateway.Close()
+
+	// GET is cached, PUT with a body buffered for retries
+	for _, method := range []string{"GET", "PUT"} {
+		req, err := http.NewRequest(method, gateway.URL+"/greeter", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := rsp.Trailer["Grpc-Status"]; !ok {
+			t.Fatalf("Expected Grpc-Status to be declared as a trailer of %s got %v", method, rsp.Trailer)
+		}
+
+		b, err := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "body" {
+			t.Fatalf("Expected body %q got %q", "body", string(b))
+		}
+		if ct := rsp.Header.Get("Content-Type"); ct != "application/grpc-web" {
+			t.Fatalf("Expected backend content type got %s", ct)
+		}
+
+		testData := map[string]string{
+			"Grpc-Status":  "0",
+			"Grpc-Message": "OK",
+			"X-Extra":      "extra",
+		}
+		for k, v := range testData {
+			if got := rsp.Trailer.Get(k); got != v {
+				t.Fatalf("Expected trailer %s=%s of %s got %q", k, v, method, got)
+			}
+		}
+	}
+}
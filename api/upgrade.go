@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// upgrading determines whether the request asks to upgrade the connection,
+// the Upgrade header is ignored unless Connection lists it
+func upgrading(r *http.Request) bool {
+	for _, v := range r.Header["Connection"] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeHandler rejects requests to upgrade to protocols not in the allowlist
+func upgradeHandler(allowed []string, h http.Handler) http.Handler {
+	allow := make(map[string]bool)
+	for _, p := range allowed {
+		allow[strings.ToLower(p)] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrade := r.Header.Get("Upgrade")
+		if len(upgrade) == 0 || !upgrading(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// the client may offer several protocols, all must be allowed
+		for _, p := range strings.Split(upgrade, ",") {
+			// strip the version e.g h2c/1.0
+			name := strings.TrimSpace(strings.SplitN(p, "/", 2)[0])
+			if !allow[strings.ToLower(name)] {
+				writeError(w, "Upgrade to "+name+" not allowed", http.StatusBadRequest)
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpgradeHandler(t *testing.T) {
+	h := upgradeHandler([]string{"websocket"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	testData := []struct {
+		connection string
+		upgrade    string
+		code       int
+	}{
+		{"", "", http.StatusOK},
+		{"Upgrade", "websocket", http.StatusOK},
+		{"keep-alive, Upgrade", "WebSocket/13", http.StatusOK},
+		{"Upgrade", "h2c", http.StatusBadRequest},
+		{"upgrade", "websocket, h2c", http.StatusBadRequest},
+		// the upgrade header is ignored without a connection upgrade
+		{"", "h2c", http.StatusOK},
+		{"keep-alive", "h2c", http.StatusOK},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		if len(d.connection) > 0 {
+			r.Header.Set("Connection", d.connection)
+		}
+		if len(d.upgrade) > 0 {
+			r.Header.Set("Upgrade", d.upgrade)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("Expected status %d for Connection %q Upgrade %q got %d", d.code, d.connection, d.upgrade, w.Code)
+		}
+	}
+}
+
+func TestUpgradeHandlerNoneAllowed(t *testing.T) {
+	// handlers which don't proxy can't upgrade
+	h := upgradeHandler(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected the upgrade to be rejected got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected requests without an upgrade to pass got %d", w.Code)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/micro/micro/v2/internal/errorformat"
+)
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// hasPathPrefix determines whether the path is under the prefix, matching
+// whole segments so /users doesn't match /usersearch
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	rest := path[len(prefix):]
+	return len(rest) == 0 || rest[0] == '/' || strings.HasSuffix(prefix, "/")
+}
+
+// writeError replies with a gateway generated error in the configured format
+func writeError(w http.ResponseWriter, msg string, code int) {
+	errorformat.Error(w, msg, code)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	log "github.com/micro/go-micro/v2/logger"
+)
+
+// isWebsocket determines whether the request asks to upgrade to a websocket
+func isWebsocket(r *http.Request) bool {
+	return upgrading(r) && strings.EqualFold(strings.TrimSpace(r.Header.Get("Upgrade")), "websocket")
+}
+
+// closeWrite closes the sending side of the connection so the peer reads
+// the end of the stream, closing it whole when it can't be half closed
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	c.Close()
+}
+
+// proxyWebsocket hijacks the connection of the client and dials the node
+// at addr with dial, sending it the handshake and copying bytes both ways until both
+// sides are done. The handshake response and the frames, including pings,
+// pongs and close frames, pass through as they are. The end of either side
+// is passed on by half closing the other so the close handshake completes.
+func proxyWebsocket(w http.ResponseWriter, r *http.Request, addr string, dial func(context.Context, string, string) (net.Conn, error)) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, "Websockets not supported", http.StatusInternalServerError)
+		return
+	}
+
+	backend, err := dial(r.Context(), "tcp", addr)
+	if err != nil {
+		log.Errorf("Failed to dial websocket backend at %s: %v", addr, err)
+		if dialTimedOut(err) {
+			writeError(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		writeError(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer backend.Close()
+
+	req := r.Clone(r.Context())
+	req.Host = r.Host
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := r.Header.Get("X-Forwarded-For"); len(prior) > 0 {
+			ip = prior + ", " + ip
+		}
+		req.Header.Set("X-Forwarded-For", ip)
+	}
+	if err := req.Write(backend); err != nil {
+		log.Errorf("Failed to send websocket handshake to %s: %v", addr, err)
+		writeError(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		log.Errorf("Failed to hijack websocket connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// the reader holds anything the client sent after the handshake
+		if _, err := io.Copy(backend, brw.Reader); err != nil {
+			log.Debugf("Websocket client to %s: %v", addr, err)
+		}
+		closeWrite(backend)
+	}()
+	go func() {
+		defer wg.Done()
+		if _, err := io.Copy(conn, backend); err != nil {
+			log.Debugf("Websocket %s to client: %v", addr, err)
+		}
+		closeWrite(conn)
+	}()
+	wg.Wait()
+}
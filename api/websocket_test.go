@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+func TestProxyWebsocket(t *testing.T) {
+	var (
+		ping       = []byte{0x89, 0x00}
+		pong       = []byte{0x8a, 0x00}
+		closeFrame = []byte{0x88, 0x00}
+	)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isWebsocket(r) || r.URL.Path != "/greeter/ws" {
+			http.Error(w, "not a websocket", http.StatusBadRequest)
+			return
+		}
+		conn, brw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+
+		// answer pings and echo the close frame, then close
+		frame := make([]byte, 2)
+		for {
+			if _, err := io.ReadFull(brw, frame); err != nil {
+				return
+			}
+			switch frame[0] {
+			case 0x89:
+				conn.Write(pong)
+			case 0x88:
+				conn.Write(closeFrame)
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+
+	service := &api.Service{
+		Name: "go.micro.api.greeter",
+		Services: []*registry.Service{{
+			Name:  "go.micro.api.greeter",
+			Nodes: []*registry.Node{{Id: "greeter-1", Address: strings.TrimPrefix(backend.URL, "http://")}},
+		}},
+	}
+	h := proxyHandler(&testRouter{service: service}, newProxyBufferPool(1024), 1<<20, newBackendDialer(0), randomStrategy)
+	gateway := httptest.NewServer(h)
+	defer gateway.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(gateway.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest("GET", gateway.URL+"/greeter/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	rsp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status 101 got %d", rsp.StatusCode)
+	}
+
+	// pings and pongs pass through
+	conn.Write(ping)
+	frame := make([]byte, 2)
+	if _, err := io.ReadFull(br, frame); err != nil || !bytes.Equal(frame, pong) {
+		t.Fatalf("Expected a pong got %v %v", frame, err)
+	}
+
+	// the close handshake completes and the backend closing ends the stream
+	conn.Write(closeFrame)
+	rest, err := ioutil.ReadAll(br)
+	if err != nil || !bytes.Equal(rest, closeFrame) {
+		t.Fatalf("Expected the close frame then the end of the stream got %v %v", rest, err)
+	}
+}
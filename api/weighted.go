@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+// weightedTarget is a service version sent a share of the traffic
+type weightedTarget struct {
+	service string
+	weight  int
+}
+
+// parseResolverWeights parses splits in the format service=target:weight,
+// repeated for each target of the service
+func parseResolverWeights(list []string) (map[string][]weightedTarget, error) {
+	weights := make(map[string][]weightedTarget)
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid weight, expected service=target:weight", s)
+		}
+		i := strings.LastIndex(parts[1], ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("%s is not a valid weight, expected service=target:weight", s)
+		}
+		weight, err := strconv.Atoi(parts[1][i+1:])
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("%s is not a valid weight", parts[1][i+1:])
+		}
+		weights[parts[0]] = append(weights[parts[0]], weightedTarget{
+			service: parts[1][:i],
+			weight:  weight,
+		})
+	}
+
+	for service, targets := range weights {
+		var total int
+		for _, t := range targets {
+			total += t.weight
+		}
+		if total == 0 {
+			return nil, fmt.Errorf("the weights of %s add up to 0", service)
+		}
+	}
+	return weights, nil
+}
+
+// weightedResolver splits the traffic of services between their versions,
+// e.g to send a canary a tenth of the requests. Every request resolved to
+// a split service is sent to one of its targets at random in proportion
+// to their weights, other services are left as resolved.
+type weightedResolver struct {
+	resolver.Resolver
+	weights map[string][]weightedTarget
+	// returns a random number in [0, n)
+	intn func(n int) int
+}
+
+func newWeightedResolver(r resolver.Resolver, weights map[string][]weightedTarget) *weightedResolver {
+	return &weightedResolver{
+		Resolver: r,
+		weights:  weights,
+		intn:     rand.Intn,
+	}
+}
+
+// pick returns the target of a request to the service
+func (w *weightedResolver) pick(targets []weightedTarget) string {
+	var total int
+	for _, t := range targets {
+		total += t.weight
+	}
+	n := w.intn(total)
+	for _, t := range targets {
+		if n < t.weight {
+			return t.service
+		}
+		n -= t.weight
+	}
+	return targets[len(targets)-1].service
+}
+
+func (w *weightedResolver) Resolve(req *http.Request) (*resolver.Endpoint, error) {
+	ep, err := w.Resolver.Resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	if targets, ok := w.weights[ep.Name]; ok {
+		ep.Name = w.pick(targets)
+	}
+	return ep, nil
+}
+
+func (w *weightedResolver) String() string {
+	return "weighted"
+}
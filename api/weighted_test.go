@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+// serviceResolver resolves every request to the service
+type serviceResolver struct {
+	service string
+}
+
+func (r *serviceResolver) Resolve(req *http.Request) (*resolver.Endpoint, error) {
+	return &resolver.Endpoint{Name: r.service, Path: req.URL.Path}, nil
+}
+
+func (r *serviceResolver) String() string {
+	return "test"
+}
+
+func TestWeightedResolver(t *testing.T) {
+	weights, err := parseResolverWeights([]string{
+		"go.micro.api.foo=go.micro.api.foo:90",
+		"go.micro.api.foo=go.micro.api.foo-v2:10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newWeightedResolver(&serviceResolver{service: "go.micro.api.foo"}, weights)
+	testData := []struct {
+		n       int
+		service string
+	}{
+		{0, "go.micro.api.foo"},
+		{89, "go.micro.api.foo"},
+		{90, "go.micro.api.foo-v2"},
+		{99, "go.micro.api.foo-v2"},
+	}
+	for _, d := range testData {
+		w.intn = func(n int) int {
+			if n != 100 {
+				t.Fatalf("Expected the weights to add up to 100 got %d", n)
+			}
+			return d.n
+		}
+		ep, err := w.Resolve(httptest.NewRequest("GET", "/foo", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ep.Name != d.service {
+			t.Fatalf("Expected %d to resolve to %s got %s", d.n, d.service, ep.Name)
+		}
+	}
+
+	// services without a split are left as resolved
+	w = newWeightedResolver(&serviceResolver{service: "go.micro.api.bar"}, weights)
+	if ep, _ := w.Resolve(httptest.NewRequest("GET", "/bar", nil)); ep.Name != "go.micro.api.bar" {
+		t.Fatalf("Expected go.micro.api.bar got %s", ep.Name)
+	}
+
+	for _, list := range [][]string{
+		{"go.micro.api.foo"},
+		{"go.micro.api.foo=go.micro.api.foo-v2"},
+		{"go.micro.api.foo=go.micro.api.foo-v2:-1"},
+		{"go.micro.api.foo=go.micro.api.foo-v2:0"},
+	} {
+		if _, err := parseResolverWeights(list); err == nil {
+			t.Fatalf("Expected %v to be rejected", list)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/client/selector"
+	"github.com/micro/go-micro/v2/errors"
+	log "github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// node metadata key identifying the zone of a backend
+var zoneKey = "zone"
+
+// zoneFilter returns a selector filter keeping the nodes in the zone, or
+// those outside of it unless local
+func zoneFilter(zone string, local bool) selector.Filter {
+	return func(old []*registry.Service) []*registry.Service {
+		var services []*registry.Service
+		for _, s := range old {
+			var nodes []*registry.Node
+			for _, n := range s.Nodes {
+				if (n.Metadata[zoneKey] == zone) == local {
+					nodes = append(nodes, n)
+				}
+			}
+			if len(nodes) == 0 {
+				continue
+			}
+			service := new(registry.Service)
+			*service = *s
+			service.Nodes = nodes
+			services = append(services, service)
+		}
+		return services
+	}
+}
+
+// inZone returns the call options to select a backend in the zone, or
+// outside of it unless local
+func inZone(zone string, local bool, opts []client.CallOption) []client.CallOption {
+	filter := selector.WithFilter(zoneFilter(zone, local))
+	return append(opts[:len(opts):len(opts)], client.WithSelectOption(filter))
+}
+
+// noneSelected determines whether the error means no backend could be
+// selected, so the request was never sent
+func noneSelected(err error) bool {
+	if err == selector.ErrNotFound || err == selector.ErrNoneAvailable {
+		return true
+	}
+	e := errors.Parse(err.Error())
+	return e.Id == clientErrorID &&
+		(strings.HasSuffix(e.Detail, selector.ErrNotFound.Error()) || strings.HasSuffix(e.Detail, selector.ErrNoneAvailable.Error()))
+}
+
+// zoneClient prefers backends in the zone of the gateway to save the
+// latency and cost of crossing zones. With the "missing" fallback requests
+// go to the other zones when the zone has no backends, "any" also fails
+// idempotent requests over when they can't be reached, while "none" keeps
+// requests in the zone. The http handler proxies requests itself so it
+// isn't covered.
+type zoneClient struct {
+	client.Client
+	zone     string
+	fallback string
+}
+
+// fallsBack determines whether the request failing in the zone is sent to
+// the other zones
+func (z *zoneClient) fallsBack(ctx context.Context, err error) bool {
+	switch z.fallback {
+	case "any":
+		return noneSelected(err) || (unreachable(err) && idempotent(ctx))
+	case "missing":
+		return noneSelected(err)
+	default:
+		return false
+	}
+}
+
+func (z *zoneClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	err := z.Client.Call(ctx, req, rsp, inZone(z.zone, true, opts)...)
+	if err == nil || !z.fallsBack(ctx, err) {
+		return err
+	}
+
+	log.Debugf("Falling back %s to other zones than %s: %v", req.Service(), z.zone, err)
+	return z.Client.Call(ctx, req, rsp, inZone(z.zone, false, opts)...)
+}
+
+func (z *zoneClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	stream, err := z.Client.Stream(ctx, req, inZone(z.zone, true, opts)...)
+	if err == nil || !z.fallsBack(ctx, err) {
+		return stream, err
+	}
+
+	log.Debugf("Falling back %s stream to other zones than %s: %v", req.Service(), z.zone, err)
+	return z.Client.Stream(ctx, req, inZone(z.zone, false, opts)...)
+}
+
+// zoneWrapper returns a client wrapper preferring backends in the zone
+func zoneWrapper(zone, fallback string) client.Wrapper {
+	return func(c client.Client) client.Client {
+		return &zoneClient{
+			Client:   c,
+			zone:     zone,
+			fallback: fallback,
+		}
+	}
+}
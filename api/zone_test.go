@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/errors"
+	"github.com/micro/go-micro/v2/metadata"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// unselectableClient fails every call as if no backend can be selected
+type unselectableClient struct {
+	client.Client
+	calls int
+}
+
+func (c *unselectableClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	c.calls++
+	return errors.InternalServerError(clientErrorID, "error selecting %s node: none available", req.Service())
+}
+
+func TestZoneFilter(t *testing.T) {
+	services := []*registry.Service{{
+		Name: "go.micro.srv.greeter",
+		Nodes: []*registry.Node{
+			{Id: "greeter-1", Metadata: map[string]string{zoneKey: "a"}},
+			{Id: "greeter-2", Metadata: map[string]string{zoneKey: "b"}},
+			{Id: "greeter-3"},
+		},
+	}}
+
+	ids := func(services []*registry.Service) string {
+		var ids []string
+		for _, s := range services {
+			for _, n := range s.Nodes {
+				ids = append(ids, n.Id)
+			}
+		}
+		return strings.Join(ids, ",")
+	}
+
+	if local := ids(zoneFilter("a", true)(services)); local != "greeter-1" {
+		t.Fatalf("Expected greeter-1 in the zone got %s", local)
+	}
+	if others := ids(zoneFilter("a", false)(services)); others != "greeter-2,greeter-3" {
+		t.Fatalf("Expected greeter-2,greeter-3 outside the zone got %s", others)
+	}
+	if len(zoneFilter("c", true)(services)) != 0 {
+		t.Fatal("Expected no services without nodes in the zone")
+	}
+	if len(services[0].Nodes) != 3 {
+		t.Fatal("Expected the nodes of the service to be kept")
+	}
+}
+
+func TestZoneFallback(t *testing.T) {
+	testData := []struct {
+		fallback   string
+		method     string
+		selectable bool
+		calls      int
+	}{
+		{"any", "GET", true, 2},
+		{"any", "POST", true, 1},
+		{"any", "POST", false, 2},
+		{"missing", "GET", true, 1},
+		{"missing", "POST", false, 2},
+		{"none", "GET", false, 1},
+	}
+
+	for _, d := range testData {
+		var c client.Client
+		var calls func() int
+		if d.selectable {
+			uc := &unreachableClient{}
+			c, calls = uc, func() int { return uc.calls }
+		} else {
+			uc := &unselectableClient{}
+			c, calls = uc, func() int { return uc.calls }
+		}
+		zc := zoneWrapper("a", d.fallback)(c)
+
+		h := methodHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// handlers build the call metadata from the headers
+			md := make(metadata.Metadata)
+			for k, v := range r.Header {
+				md[k] = strings.Join(v, ",")
+			}
+			zc.Call(metadata.NewContext(context.Background(), md), &testRequest{service: "go.micro.srv.greeter"}, nil)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(d.method, "/greeter", nil))
+
+		if n := calls(); n != d.calls {
+			t.Fatalf("Expected %d calls for %s with the %s fallback got %d", d.calls, d.method, d.fallback, n)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package errorformat formats the errors generated by the gateway to match
+// the conventions of the api behind it, so clients handle them like the
+// errors returned by backends
+package errorformat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/template"
+)
+
+// Formats lists the built in error formats
+var Formats = []string{"text", "micro", "error", "message", "jsonapi", "template"}
+
+var (
+	mtx sync.RWMutex
+	// envelope errors are written in, nil when none is configured
+	current *Envelope
+)
+
+// Envelope formats gateway generated errors
+type Envelope struct {
+	// one of the Formats
+	format string
+	tmpl   *template.Template
+}
+
+// data passed to error templates
+type data struct {
+	ID      string
+	Code    int
+	Status  string
+	Message string
+}
+
+// New returns an envelope of the format. The template is only used by the
+// template format and is passed the ID, Code, Status and Message of the
+// error, e.g {"failure": {{json .Message}}}
+func New(format, tmpl string) (*Envelope, error) {
+	e := &Envelope{format: format}
+	switch format {
+	case "text", "micro", "error", "message", "jsonapi":
+	case "template":
+		if len(tmpl) == 0 {
+			return nil, fmt.Errorf("the template error format requires a template")
+		}
+		t, err := template.New("error").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+		}).Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		e.tmpl = t
+	default:
+		return nil, fmt.Errorf("%s is not a valid error format", format)
+	}
+	return e, nil
+}
+
+// Set sets the envelope gateway generated errors are written in
+func Set(e *Envelope) {
+	mtx.Lock()
+	current = e
+	mtx.Unlock()
+}
+
+func get() *Envelope {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return current
+}
+
+// Encode returns the error in the envelope and its content type
+func (e *Envelope) Encode(id, msg string, code int) ([]byte, string, error) {
+	status := http.StatusText(code)
+
+	var v interface{}
+	switch e.format {
+	case "text":
+		return []byte(msg + "\n"), "text/plain; charset=utf-8", nil
+	case "micro":
+		// the fields of go-micro errors returned by backends
+		v = struct {
+			ID     string `json:"id"`
+			Code   int    `json:"code"`
+			Detail string `json:"detail"`
+			Status string `json:"status"`
+		}{id, code, msg, status}
+	case "error":
+		v = map[string]interface{}{"error": map[string]interface{}{"code": code, "message": msg}}
+	case "message":
+		v = map[string]interface{}{"message": msg}
+	case "jsonapi":
+		v = map[string]interface{}{"errors": []map[string]interface{}{{
+			"status": strconv.Itoa(code),
+			"title":  status,
+			"detail": msg,
+		}}}
+	case "template":
+		var buf bytes.Buffer
+		if err := e.tmpl.Execute(&buf, data{ID: id, Code: code, Status: status, Message: msg}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "application/json", nil
+	}
+
+	b, err := json.Marshal(v)
+	return b, "application/json", err
+}
+
+func (e *Envelope) write(w http.ResponseWriter, id, msg string, code int) {
+	b, ct, err := e.Encode(id, msg, code)
+	if err != nil {
+		http.Error(w, msg, code)
+		return
+	}
+
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
+// Error replies with a gateway generated error in the configured envelope,
+// in plain text when none is configured
+func Error(w http.ResponseWriter, msg string, code int) {
+	if e := get(); e != nil {
+		e.write(w, "go.micro.api", msg, code)
+		return
+	}
+	http.Error(w, msg, code)
+}
+
+// MicroError replies with a gateway generated error in the configured
+// envelope, in the micro format with the id when none is configured
+func MicroError(w http.ResponseWriter, id, msg string, code int) {
+	e := get()
+	if e == nil {
+		e = &Envelope{format: "micro"}
+	}
+	e.write(w, id, msg, code)
+}
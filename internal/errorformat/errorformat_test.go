@@ -0,0 +1,66 @@
+package errorformat
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFormats(t *testing.T) {
+	testData := []struct {
+		format string
+		tmpl   string
+		body   string
+	}{
+		{"text", "", "Service busy\n"},
+		{"micro", "", `{"id":"go.micro.api","code":503,"detail":"Service busy","status":"Service Unavailable"}`},
+		{"error", "", `{"error":{"code":503,"message":"Service busy"}}`},
+		{"message", "", `{"message":"Service busy"}`},
+		{"jsonapi", "", `{"errors":[{"detail":"Service busy","status":"503","title":"Service Unavailable"}]}`},
+		{"template", `{"failure": {{json .Message}}, "code": {{.Code}}}`, `{"failure": "Service busy", "code": 503}`},
+	}
+
+	defer Set(nil)
+
+	for _, d := range testData {
+		e, err := New(d.format, d.tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		Set(e)
+
+		w := httptest.NewRecorder()
+		Error(w, "Service busy", 503)
+
+		if w.Code != 503 {
+			t.Fatalf("Expected 503 got %d", w.Code)
+		}
+		if w.Body.String() != d.body {
+			t.Fatalf("Expected %s error %s got %s", d.format, d.body, w.Body.String())
+		}
+	}
+
+	if _, err := New("xml", ""); err == nil {
+		t.Fatal("Expected unknown format to be rejected")
+	}
+	if _, err := New("template", ""); err == nil {
+		t.Fatal("Expected template format without a template to be rejected")
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	Set(nil)
+
+	// plain text errors stay plain text
+	w := httptest.NewRecorder()
+	Error(w, "Forbidden request", 403)
+	if w.Body.String() != "Forbidden request\n" || w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Fatalf("Expected plain text error got %s %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	// micro errors stay micro errors
+	w = httptest.NewRecorder()
+	MicroError(w, "go.micro.rpc", "missing service", 400)
+	if w.Body.String() != `{"id":"go.micro.rpc","code":400,"detail":"missing service","status":"Bad Request"}` {
+		t.Fatalf("Expected micro error got %s", w.Body.String())
+	}
+}
@@ -4,66 +4,104 @@ import (
 	"net/http"
 
 	"github.com/micro/go-micro/v2"
+	"github.com/micro/go-micro/v2/api"
 	"github.com/micro/go-micro/v2/api/handler"
 	"github.com/micro/go-micro/v2/api/handler/event"
 	"github.com/micro/go-micro/v2/api/router"
 	"github.com/micro/go-micro/v2/client"
-	"github.com/micro/go-micro/v2/errors"
+	cgrpc "github.com/micro/go-micro/v2/client/grpc"
 
 	// TODO: only import handler package
 	aapi "github.com/micro/go-micro/v2/api/handler/api"
 	ahttp "github.com/micro/go-micro/v2/api/handler/http"
 	arpc "github.com/micro/go-micro/v2/api/handler/rpc"
 	aweb "github.com/micro/go-micro/v2/api/handler/web"
+
+	"github.com/micro/micro/v2/internal/errorformat"
 )
 
+// metadata key of the protocol a service speaks; {grpc, http}
+var protocolKey = "protocol"
+
 type metaHandler struct {
-	c  client.Client
+	c client.Client
+	// client of services speaking grpc
+	gc client.Client
 	r  router.Router
 	ns func(*http.Request) string
 }
 
+// protocol returns the protocol declared in the service metadata
+func protocol(service *api.Service) string {
+	for _, s := range service.Services {
+		if p := s.Metadata[protocolKey]; len(p) > 0 {
+			return p
+		}
+	}
+	return ""
+}
+
 func (m *metaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	service, err := m.r.Route(r)
 	if err != nil {
-		er := errors.InternalServerError(m.ns(r), err.Error())
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(er.Error()))
+		errorformat.MicroError(w, m.ns(r), err.Error(), 500)
 		return
 	}
 
+	// the protocol a service declares picks the client and, for http
+	// backends, the handler regardless of the endpoint metadata
+	c := m.c
+	switch protocol(service) {
+	case "http":
+		ahttp.WithService(service, handler.WithClient(c)).ServeHTTP(w, r)
+		return
+	case "grpc":
+		c = m.gc
+	}
+
 	// TODO: don't do this ffs
 	switch service.Endpoint.Handler {
 	// web socket handler
 	case aweb.Handler:
-		aweb.WithService(service, handler.WithClient(m.c)).ServeHTTP(w, r)
+		aweb.WithService(service, handler.WithClient(c)).ServeHTTP(w, r)
 	// proxy handler
 	case "proxy", ahttp.Handler:
-		ahttp.WithService(service, handler.WithClient(m.c)).ServeHTTP(w, r)
+		ahttp.WithService(service, handler.WithClient(c)).ServeHTTP(w, r)
 	// rpcx handler
 	case arpc.Handler:
-		arpc.WithService(service, handler.WithClient(m.c)).ServeHTTP(w, r)
+		arpc.WithService(service, handler.WithClient(c)).ServeHTTP(w, r)
 	// event handler
 	case event.Handler:
 		ev := event.NewHandler(
 			handler.WithNamespace(m.ns(r)),
-			handler.WithClient(m.c),
+			handler.WithClient(c),
 		)
 		ev.ServeHTTP(w, r)
 	// api handler
 	case aapi.Handler:
-		aapi.WithService(service, handler.WithClient(m.c)).ServeHTTP(w, r)
+		aapi.WithService(service, handler.WithClient(c)).ServeHTTP(w, r)
 	// default handler: rpc
 	default:
-		arpc.WithService(service, handler.WithClient(m.c)).ServeHTTP(w, r)
+		arpc.WithService(service, handler.WithClient(c)).ServeHTTP(w, r)
 	}
 }
 
-// Meta is a http.Handler that routes based on endpoint metadata
-func Meta(s micro.Service, r router.Router, ns func(*http.Request) string) http.Handler {
+// Meta is a http.Handler that routes based on endpoint metadata. Services
+// declaring their protocol in their metadata are called over it. The
+// wrappers are those of the service client in the order applied, they're
+// applied to the grpc client too so both clients behave the same.
+func Meta(s micro.Service, r router.Router, ns func(*http.Request) string, wrappers ...client.Wrapper) http.Handler {
+	gc := cgrpc.NewClient(
+		client.Registry(s.Options().Registry),
+		client.Selector(s.Client().Options().Selector),
+	)
+	for _, w := range wrappers {
+		gc = w(gc)
+	}
+
 	return &metaHandler{
 		c:  s.Client(),
+		gc: gc,
 		r:  r,
 		ns: ns,
 	}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/micro/go-micro/v2"
+	"github.com/micro/go-micro/v2/api"
+	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+func TestProtocol(t *testing.T) {
+	testData := []struct {
+		metadata []map[string]string
+		protocol string
+	}{
+		{nil, ""},
+		{[]map[string]string{{"protocol": "grpc"}}, "grpc"},
+		{[]map[string]string{{}, {"protocol": "http"}}, "http"},
+	}
+
+	for _, d := range testData {
+		service := &api.Service{Name: "go.micro.api.greeter"}
+		for _, md := range d.metadata {
+			service.Services = append(service.Services, &registry.Service{
+				Name:     "go.micro.api.greeter",
+				Metadata: md,
+			})
+		}
+		if p := protocol(service); p != d.protocol {
+			t.Fatalf("Expected protocol %q got %q", d.protocol, p)
+		}
+	}
+}
+
+// wrappedClient marks a client as wrapped
+type wrappedClient struct {
+	client.Client
+}
+
+func TestMetaWrappers(t *testing.T) {
+	wrapper := func(c client.Client) client.Client {
+		return &wrappedClient{c}
+	}
+
+	m := Meta(micro.NewService(), nil, nil, wrapper).(*metaHandler)
+	if _, ok := m.gc.(*wrappedClient); !ok {
+		t.Fatalf("Expected the grpc client to be wrapped got %T", m.gc)
+	}
+}
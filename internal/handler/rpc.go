@@ -31,7 +31,9 @@ func RPC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// only calls are posted, other methods have no body to parse
 	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST, OPTIONS")
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
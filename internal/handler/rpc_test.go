@@ -97,3 +97,15 @@ func TestRPCHandler(t *testing.T) {
 	}
 
 }
+
+func TestRPCHandlerMethod(t *testing.T) {
+	w := httptest.NewRecorder()
+	RPC(w, httptest.NewRequest("GET", "/rpc", nil))
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected 405 response got %d %s", w.Code, w.Body.String())
+	}
+	if allow := w.Header().Get("Allow"); allow != "POST, OPTIONS" {
+		t.Fatalf("Expected POST and OPTIONS allowed got %q", allow)
+	}
+}
@@ -1,38 +1,140 @@
 package namespace
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"strings"
 
+	"github.com/micro/go-micro/v2/api/resolver"
 	"github.com/micro/go-micro/v2/auth"
 	"github.com/micro/go-micro/v2/logger"
 	"golang.org/x/net/publicsuffix"
 )
 
-func NewResolver(srvType, namespace string) *Resolver {
-	return &Resolver{srvType, namespace}
+func NewResolver(srvType, namespace string, overrides ...Override) *Resolver {
+	return &Resolver{srvType, namespace, overrides}
+}
+
+// Override sets the namespace and type of requests under a path prefix,
+// e.g. /grpc/ served by the grpc type while the rest are served by api.
+// An empty namespace or type keeps the default.
+type Override struct {
+	Prefix    string
+	Namespace string
+	Type      string
+}
+
+// ParseOverrides parses overrides in the format prefix=type or
+// prefix=namespace:type, e.g. /grpc/=grpc or /legacy/=com.example:api
+func ParseOverrides(list []string) ([]Override, error) {
+	var overrides []Override
+	for _, s := range list {
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, fmt.Errorf("%s is not a valid override, expected prefix=[namespace:]type", s)
+		}
+
+		o := Override{Prefix: parts[0], Type: parts[1]}
+		if i := strings.LastIndex(parts[1], ":"); i >= 0 {
+			o.Namespace = parts[1][:i]
+			o.Type = parts[1][i+1:]
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, nil
 }
 
 // Resolver determines the namespace for a request
 type Resolver struct {
 	srvType   string
 	namespace string
+	overrides []Override
 }
 
 func (r Resolver) String() string {
 	return "internal/namespace"
 }
 
+// match returns the override with the longest prefix matching the path
+func (r Resolver) match(path string) (Override, bool) {
+	var match Override
+	var ok bool
+	for _, o := range r.overrides {
+		if !strings.HasPrefix(path, o.Prefix) || len(o.Prefix) <= len(match.Prefix) {
+			continue
+		}
+		match, ok = o, true
+	}
+	return match, ok
+}
+
+// overrideKey holds the override matched before the prefix was stripped
+type overrideKey struct{}
+
+// override returns the namespace and type of the request, using the
+// override stripped from its path or otherwise matching it
+func (r Resolver) override(req *http.Request) (string, string) {
+	namespace, srvType := r.namespace, r.srvType
+
+	o, ok := req.Context().Value(overrideKey{}).(Override)
+	if !ok && req.URL != nil {
+		o, ok = r.match(req.URL.Path)
+	}
+	if !ok {
+		return namespace, srvType
+	}
+
+	if len(o.Namespace) > 0 {
+		namespace = o.Namespace
+	}
+	if len(o.Type) > 0 {
+		srvType = o.Type
+	}
+	return namespace, srvType
+}
+
+// Strip returns a resolver removing the prefix of the matching override
+// from the path before resolving the request with rr, so /grpc/foo/bar is
+// resolved as /foo/bar in the namespace of the override
+func (r Resolver) Strip(rr resolver.Resolver) resolver.Resolver {
+	return &stripResolver{Resolver: rr, ns: r}
+}
+
+type stripResolver struct {
+	resolver.Resolver
+	ns Resolver
+}
+
+func (s *stripResolver) Resolve(req *http.Request) (*resolver.Endpoint, error) {
+	o, ok := s.ns.match(req.URL.Path)
+	if !ok {
+		return s.Resolver.Resolve(req)
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, o.Prefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	stripped := req.Clone(context.WithValue(req.Context(), overrideKey{}, o))
+	stripped.URL.Path = path
+	stripped.URL.RawPath = ""
+	return s.Resolver.Resolve(stripped)
+}
+
 func (r Resolver) Resolve(req *http.Request) string {
+	namespace, srvType := r.override(req)
+
 	withTypeSuffix := func(ns string) string {
-		return ns + "." + r.srvType
+		return ns + "." + srvType
 	}
 
 	// check to see what the provided namespace is, we only do
 	// domain mapping if the namespace is set to 'domain'
-	if r.namespace != "domain" {
-		return withTypeSuffix(r.namespace)
+	if namespace != "domain" {
+		return withTypeSuffix(namespace)
 	}
 
 	// determine the host, e.g. dev.micro.mu:8080
@@ -2,10 +2,13 @@ package namespace
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/micro/go-micro/v2/api/resolver"
 	"github.com/micro/go-micro/v2/auth"
+	micro "github.com/micro/micro/v2/internal/resolver/api"
 )
 
 func TestResolve(t *testing.T) {
@@ -112,3 +115,61 @@ func TestResolve(t *testing.T) {
 		})
 	}
 }
+
+func TestOverrides(t *testing.T) {
+	overrides, err := ParseOverrides([]string{"/v1/=api", "/grpc/=grpc", "/grpc/legacy/=com.example:grpc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		Path   string
+		Result string
+	}{
+		{Path: "/foo/bar", Result: "go.micro.web"},
+		{Path: "/v1/foo/bar", Result: "go.micro.api"},
+		{Path: "/grpc/foo.Bar/Baz", Result: "go.micro.grpc"},
+		{Path: "/grpc/legacy/foo.Bar/Baz", Result: "com.example.grpc"},
+	}
+
+	r := NewResolver("web", "go.micro", overrides...)
+	for _, tc := range tt {
+		result := r.Resolve(&http.Request{URL: &url.URL{Host: "micro.mu", Path: tc.Path}})
+		if result != tc.Result {
+			t.Errorf("Expected namespace %v for path %v, actually got %v", tc.Result, tc.Path, result)
+		}
+	}
+
+	// routes are resolved without the prefix
+	routes := []struct {
+		Path    string
+		Service string
+		Method  string
+	}{
+		{Path: "/greeter/Say/Hello", Service: "go.micro.web.greeter", Method: "Say.Hello"},
+		{Path: "/grpc/greeter/Say/Hello", Service: "go.micro.grpc.greeter", Method: "Say.Hello"},
+		{Path: "/grpc/legacy/greeter/Say/Hello", Service: "com.example.grpc.greeter", Method: "Say.Hello"},
+	}
+
+	rr := r.Strip(micro.NewResolver(
+		resolver.WithHandler("rpc"),
+		resolver.WithNamespace(r.Resolve),
+	))
+	for _, tc := range routes {
+		req := httptest.NewRequest("POST", tc.Path, nil)
+		ep, err := rr.Resolve(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ep.Name != tc.Service || ep.Method != tc.Method {
+			t.Errorf("Expected %v %v for path %v, actually got %v %v", tc.Service, tc.Method, tc.Path, ep.Name, ep.Method)
+		}
+		if req.URL.Path != tc.Path {
+			t.Errorf("Expected path %v of the request to be kept, actually got %v", tc.Path, req.URL.Path)
+		}
+	}
+
+	if _, err := ParseOverrides([]string{"/v1/"}); err == nil {
+		t.Error("Expected error parsing override without a type")
+	}
+}
@@ -0,0 +1,43 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/micro/go-micro/v2/store"
+)
+
+func getRegion(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	val, ok := ctx.Value("AWS_REGION").(string)
+	if !ok {
+		return ""
+	}
+	return val
+}
+
+// Region sets the aws region of the bucket
+func Region(r string) store.Option {
+	return func(o *store.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, "AWS_REGION", r)
+	}
+}
+
+// Bucket sets the s3 bucket records are kept in
+func Bucket(b string) store.Option {
+	return func(o *store.Options) {
+		o.Database = b
+	}
+}
+
+// Prefix sets the prefix of the object keys, keeping the records apart from
+// others in the bucket
+func Prefix(p string) store.Option {
+	return func(o *store.Options) {
+		o.Table = p
+	}
+}
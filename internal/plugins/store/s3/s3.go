@@ -0,0 +1,240 @@
+// Package s3 is a store implementation backed by an aws s3 bucket
+package s3
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/micro/go-micro/v2/store"
+)
+
+// expiryKey is the object metadata the expiry of records is kept in
+const expiryKey = "Expiry"
+
+type s3Store struct {
+	options store.Options
+	// s3 bucket
+	bucket string
+	// prefix of the object keys
+	prefix string
+	client *s3.S3
+}
+
+func (s *s3Store) Close() error {
+	return nil
+}
+
+func (s *s3Store) Init(opts ...store.Option) error {
+	for _, o := range opts {
+		o(&s.options)
+	}
+	if len(s.options.Database) > 0 {
+		s.bucket = s.options.Database
+	}
+	s.prefix = s.options.Table
+	return nil
+}
+
+func (s *s3Store) key(k string) string {
+	return s.prefix + k
+}
+
+func (s *s3Store) list(prefix string) ([]string, error) {
+	var keys []string
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.key(prefix)),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.StringValue(o.Key), s.prefix))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *s3Store) List(opts ...store.ListOption) ([]string, error) {
+	var options store.ListOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	keys, err := s.list(options.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(options.Suffix) == 0 {
+		return keys, nil
+	}
+
+	var matched []string
+	for _, k := range keys {
+		if strings.HasSuffix(k, options.Suffix) {
+			matched = append(matched, k)
+		}
+	}
+	return matched, nil
+}
+
+// get reads the record, returning store.ErrNotFound once expired
+func (s *s3Store) get(k string) (*store.Record, error) {
+	rsp, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(k)),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, store.ErrNotFound
+		}
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	record := &store.Record{Key: k}
+	if v, ok := rsp.Metadata[expiryKey]; ok {
+		expiry, err := strconv.ParseInt(aws.StringValue(v), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		record.Expiry = time.Until(time.Unix(expiry, 0))
+		if record.Expiry <= 0 {
+			return nil, store.ErrNotFound
+		}
+	}
+
+	record.Value, err = ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *s3Store) Read(key string, opts ...store.ReadOption) ([]*store.Record, error) {
+	var options store.ReadOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if !options.Prefix && !options.Suffix {
+		record, err := s.get(key)
+		if err != nil {
+			return nil, err
+		}
+		return []*store.Record{record}, nil
+	}
+
+	var prefix string
+	if options.Prefix {
+		prefix = key
+	}
+	keys, err := s.list(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	//nolint:prealloc
+	var records []*store.Record
+	for _, k := range keys {
+		if options.Suffix && !strings.HasSuffix(k, key) {
+			continue
+		}
+		record, err := s.get(k)
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *s3Store) Write(r *store.Record, opts ...store.WriteOption) error {
+	var options store.WriteOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	expiry := r.Expiry
+	if !options.Expiry.IsZero() {
+		expiry = time.Until(options.Expiry)
+	}
+	if options.TTL != 0 {
+		expiry = options.TTL
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(r.Key)),
+		Body:   bytes.NewReader(r.Value),
+	}
+	if expiry != 0 {
+		input.Metadata = map[string]*string{
+			expiryKey: aws.String(strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)),
+		}
+	}
+
+	_, err := s.client.PutObject(input)
+	return err
+}
+
+func (s *s3Store) Delete(key string, opts ...store.DeleteOption) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	return err
+}
+
+func (s *s3Store) String() string {
+	return "s3"
+}
+
+func (s *s3Store) Options() store.Options {
+	return s.options
+}
+
+// NewStore returns a store keeping records in the s3 bucket, using the aws
+// credentials of the environment
+func NewStore(opts ...store.Option) store.Store {
+	var options store.Options
+	for _, o := range opts {
+		o(&options)
+	}
+
+	region := getRegion(options.Context)
+	if len(region) == 0 {
+		region = os.Getenv("AWS_REGION")
+	}
+	if len(region) == 0 {
+		log.Fatal("Store: AWS_REGION is blank")
+	}
+	if len(options.Database) == 0 {
+		log.Fatal("Store: the s3 bucket is blank")
+	}
+
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		log.Fatalf("Store: %v", err)
+	}
+
+	return &s3Store{
+		options: options,
+		bucket:  options.Database,
+		prefix:  options.Table,
+		client:  s3.New(sess),
+	}
+}
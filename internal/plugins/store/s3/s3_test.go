@@ -0,0 +1,66 @@
+package s3
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/store"
+)
+
+func TestS3(t *testing.T) {
+	if len(os.Getenv("IN_TRAVIS_CI")) != 0 {
+		t.Skip()
+	}
+
+	bucket, region := os.Getenv("S3_TEST_BUCKET"), os.Getenv("AWS_REGION")
+	if len(bucket) == 0 || len(region) == 0 {
+		t.Skip("No s3 bucket available, skipping test")
+	}
+	rand.Seed(time.Now().UnixNano())
+	randomK := strconv.Itoa(rand.Int())
+	randomV := strconv.Itoa(rand.Int())
+
+	s := NewStore(
+		Bucket(bucket),
+		Region(region),
+		Prefix("micro-test/"),
+	)
+
+	if err := s.Write(&store.Record{Key: randomK, Value: []byte(randomV)}); err != nil {
+		t.Fatalf("Write: %s", err.Error())
+	}
+	if err := s.Write(&store.Record{Key: "expired", Value: []byte("gone")}, store.WriteExpiry(time.Now().Add(-time.Second))); err != nil {
+		t.Fatalf("Write: %s", err.Error())
+	}
+
+	records, err := s.Read(randomK)
+	if err != nil {
+		t.Fatalf("Read: %s", err.Error())
+	}
+	if len(records) != 1 || string(records[0].Value) != randomV {
+		t.Fatalf("Read: expected %s got %v", randomV, records)
+	}
+	if _, err := s.Read("expired"); err != store.ErrNotFound {
+		t.Fatalf("Read: expected expired record not to be found got %v", err)
+	}
+
+	keys, err := s.List(store.ListPrefix(randomK))
+	if err != nil {
+		t.Fatalf("List: %s", err.Error())
+	}
+	if len(keys) != 1 || keys[0] != randomK {
+		t.Fatalf("List: expected %s got %v", randomK, keys)
+	}
+
+	for _, k := range []string{randomK, "expired"} {
+		if err := s.Delete(k); err != nil {
+			t.Errorf("Delete: %s", err.Error())
+		}
+	}
+	if _, err := s.Read(randomK); err != store.ErrNotFound {
+		t.Fatalf("Read: expected deleted record not to be found got %v", err)
+	}
+}
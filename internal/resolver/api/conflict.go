@@ -0,0 +1,270 @@
+package micro
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// Policy determines which service serves a request when the path
+// prefixes of more than one registered service match it. The gateway has no
+// policy by default, resolving requests with the micro resolver which picks
+// the service by the path alone without checking the registry for conflicts.
+// NewConflictResolver defaults to LongestPrefix.
+type Policy string
+
+const (
+	// LongestPrefix picks the service with the most specific path prefix
+	LongestPrefix Policy = "longest"
+	// FirstRegistered picks the service registered first. Services
+	// registered before the gateway started are ordered as the registry
+	// lists them.
+	FirstRegistered Policy = "first"
+	// Priority picks the service with the highest "priority" metadata value,
+	// falling back to the longest prefix
+	Priority Policy = "priority"
+)
+
+// ConflictResolver is a micro resolver which checks the registry for
+// services with overlapping path prefixes, e.g /foo/bar/baz could be
+// served by foo or foo.bar, and picks one using the policy
+type ConflictResolver struct {
+	*Resolver
+
+	registry registry.Registry
+	policy   Policy
+
+	sync.RWMutex
+	// order services were registered in, only kept by FirstRegistered
+	order map[string]int
+}
+
+func (r *ConflictResolver) Resolve(req *http.Request) (*resolver.Endpoint, error) {
+	ep, err := r.Resolver.Resolve(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cands := candidates(r.Options.Handler, req.URL.Path)
+	if len(cands) == 0 {
+		return ep, nil
+	}
+
+	ns := r.Options.Namespace(req)
+
+	var matches []*registry.Service
+	var methods []string
+	for _, c := range cands {
+		services, err := r.registry.GetService(ns + "." + c.name)
+		if err != nil || len(services) == 0 {
+			continue
+		}
+		matches = append(matches, services[0])
+		methods = append(methods, c.method)
+	}
+
+	if len(matches) == 0 {
+		return ep, nil
+	}
+
+	if len(matches) > 1 {
+		var found []string
+		for _, m := range matches {
+			found = append(found, m.Name)
+		}
+		logger.Debugf("Ambiguous route for %s matches %s, picking by %s", req.URL.Path, strings.Join(found, ", "), r.policy)
+	}
+
+	i := pick(r.policy, matches, r.position)
+	ep.Name = matches[i].Name
+	// the method of the internal handlers depends on the prefix picked
+	if len(methods[i]) > 0 {
+		ep.Method = methods[i]
+	}
+	return ep, nil
+}
+
+// position returns the order the service was registered in, -1 if unknown
+func (r *ConflictResolver) position(name string) int {
+	r.RLock()
+	defer r.RUnlock()
+	if i, ok := r.order[name]; ok {
+		return i
+	}
+	return -1
+}
+
+func (r *ConflictResolver) add(name string) {
+	r.Lock()
+	if _, ok := r.order[name]; !ok {
+		r.order[name] = len(r.order)
+	}
+	r.Unlock()
+}
+
+// watch keeps the order services are registered in
+func (r *ConflictResolver) watch(reg registry.Registry) {
+	for {
+		w, err := reg.Watch()
+		if err != nil {
+			logger.Debugf("Error watching the registry for route conflicts: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		// the watcher is started first so no registration is missed
+		services, err := reg.ListServices()
+		if err == nil {
+			for _, s := range services {
+				r.add(s.Name)
+			}
+		}
+
+		for {
+			res, err := w.Next()
+			if err != nil {
+				break
+			}
+			// registries differ in whether new services are created or updated
+			if res.Action != "delete" && res.Service != nil {
+				r.add(res.Service.Name)
+			}
+		}
+		w.Stop()
+		time.Sleep(time.Second)
+	}
+}
+
+// pick returns the index of the service chosen from the matches which are
+// ordered shortest prefix first
+func pick(policy Policy, matches []*registry.Service, position func(string) int) int {
+	best := len(matches) - 1
+
+	switch policy {
+	case FirstRegistered:
+		// services not yet seen come after all others
+		pos := func(i int) int {
+			if p := position(matches[i].Name); p >= 0 {
+				return p
+			}
+			return int(^uint(0) >> 1)
+		}
+		for i := len(matches) - 1; i >= 0; i-- {
+			if pos(i) < pos(best) {
+				best = i
+			}
+		}
+	case Priority:
+		prio := priority(matches[best])
+		for i := len(matches) - 1; i >= 0; i-- {
+			if p := priority(matches[i]); p > prio {
+				best, prio = i, p
+			}
+		}
+	}
+
+	return best
+}
+
+// priority returns the priority set in the service metadata
+func priority(s *registry.Service) int {
+	if s.Metadata == nil {
+		return 0
+	}
+	p, err := strconv.Atoi(s.Metadata["priority"])
+	if err != nil {
+		return 0
+	}
+	return p
+}
+
+// candidate is a service which could serve a path and the method it's
+// called with, empty for the proxy handlers which use the http method
+type candidate struct {
+	name   string
+	method string
+}
+
+// candidates returns the services which could serve the path, shortest
+// prefix first. Nothing is returned when the path can only be served by a
+// single service.
+func candidates(handler, p string) []candidate {
+	var parts []string
+	for _, part := range strings.Split(path.Clean(p), "/") {
+		if len(part) > 0 {
+			parts = append(parts, part)
+		}
+	}
+
+	switch handler {
+	// internal handlers
+	case "meta", "api", "rpc", "micro":
+		// the last two parts are always the method so the service
+		// is either everything before them or including the first
+		if len(parts) < 3 {
+			return nil
+		}
+		if len(parts) == 3 && versionRe.MatchString(parts[0]) {
+			return nil
+		}
+		// the method is routed as if the path started at the last part
+		// of the service, e.g foo.bar serves /foo/bar/baz as Bar.Baz
+		var cands []candidate
+		for _, n := range []int{len(parts) - 2, len(parts) - 1} {
+			_, method := apiRoute(strings.Join(parts[n-1:], "/"))
+			cands = append(cands, candidate{strings.Join(parts[:n], "."), method})
+		}
+		return cands
+	default:
+		// the service is the first part, or the first two when versioned,
+		// plus any of the remaining parts
+		start := 1
+		if len(parts) > 1 && versionRe.MatchString(parts[0]) {
+			start = 2
+		}
+		if len(parts) <= start {
+			return nil
+		}
+
+		var cands []candidate
+		for i := start; i <= len(parts); i++ {
+			if !proxyRe.MatchString(parts[i-1]) {
+				break
+			}
+			cands = append(cands, candidate{name: strings.Join(parts[:i], ".")})
+		}
+		if len(cands) < 2 {
+			return nil
+		}
+		return cands
+	}
+}
+
+// NewConflictResolver creates a micro resolver which picks between services
+// with overlapping path prefixes using the policy. The registry is queried
+// on every request so should be cached.
+func NewConflictResolver(reg registry.Registry, policy Policy, opts ...resolver.Option) resolver.Resolver {
+	if len(policy) == 0 {
+		policy = LongestPrefix
+	}
+
+	r := &ConflictResolver{
+		Resolver: &Resolver{
+			Options: resolver.NewOptions(opts...),
+		},
+		registry: reg,
+		policy:   policy,
+		order:    make(map[string]int),
+	}
+	if policy == FirstRegistered {
+		go r.watch(reg)
+	}
+	return r
+}
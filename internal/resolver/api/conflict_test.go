@@ -0,0 +1,144 @@
+package micro
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-micro/v2/registry/memory"
+)
+
+func TestCandidates(t *testing.T) {
+	testData := []struct {
+		handler string
+		path    string
+		cands   []candidate
+	}{
+		{"api", "/foo/bar", nil},
+		{"api", "/v1/foo/bar", nil},
+		{"api", "/foo/bar/baz", []candidate{{"foo", "Bar.Baz"}, {"foo.bar", "Bar.Baz"}}},
+		{"api", "/foo/bar/baz/cat", []candidate{{"foo.bar", "Baz.Cat"}, {"foo.bar.baz", "Baz.Cat"}}},
+		{"api", "/v1/foo/bar/baz", []candidate{{"v1.foo", "Bar.Baz"}, {"v1.foo.bar", "Bar.Baz"}}},
+		{"http", "/foo", nil},
+		{"http", "/foo/bar", []candidate{{name: "foo"}, {name: "foo.bar"}}},
+		{"http", "/v1/foo/bar", []candidate{{name: "v1.foo"}, {name: "v1.foo.bar"}}},
+		{"http", "/foo/bar.json", nil},
+	}
+
+	for _, d := range testData {
+		cands := candidates(d.handler, d.path)
+		if !reflect.DeepEqual(cands, d.cands) {
+			t.Fatalf("Expected candidates %v for %s %s got %v", d.cands, d.handler, d.path, cands)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	foo := &registry.Service{Name: "foo", Metadata: map[string]string{"priority": "10"}}
+	fooBar := &registry.Service{Name: "foo.bar"}
+	matches := []*registry.Service{foo, fooBar}
+	order := map[string]int{"foo.bar": 0, "foo": 1}
+	position := func(name string) int {
+		if i, ok := order[name]; ok {
+			return i
+		}
+		return -1
+	}
+
+	testData := []struct {
+		policy  Policy
+		service string
+	}{
+		{LongestPrefix, "foo.bar"},
+		{FirstRegistered, "foo.bar"},
+		{Priority, "foo"},
+	}
+
+	for _, d := range testData {
+		if i := pick(d.policy, matches, position); matches[i].Name != d.service {
+			t.Fatalf("Expected %s for policy %s got %s", d.service, d.policy, matches[i].Name)
+		}
+	}
+
+	// equal priorities fall back to the longest prefix
+	foo.Metadata = nil
+	if i := pick(Priority, matches, position); matches[i].Name != "foo.bar" {
+		t.Fatalf("Expected foo.bar got %s", matches[i].Name)
+	}
+
+	// services not yet seen registered come last
+	delete(order, "foo.bar")
+	if i := pick(FirstRegistered, matches, position); matches[i].Name != "foo" {
+		t.Fatalf("Expected foo got %s", matches[i].Name)
+	}
+}
+
+func TestConflictResolver(t *testing.T) {
+	reg := memory.NewRegistry()
+	service := func(name string) *registry.Service {
+		return &registry.Service{
+			Name:    "go.micro.api." + name,
+			Version: "latest",
+			Nodes:   []*registry.Node{{Id: name + "-1", Address: "127.0.0.1:8080"}},
+		}
+	}
+
+	opts := []resolver.Option{
+		resolver.WithHandler("api"),
+		resolver.WithNamespace(resolver.StaticNamespace("go.micro.api")),
+	}
+	longest := NewConflictResolver(reg, LongestPrefix, opts...)
+	first := NewConflictResolver(reg, FirstRegistered, opts...).(*ConflictResolver)
+
+	// wait for the registrations to be seen in the order they're made
+	for _, name := range []string{"foo", "foo.bar"} {
+		if err := reg.Register(service(name)); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for first.position("go.micro.api."+name) < 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s to be seen registered", name)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	testData := []struct {
+		resolver resolver.Resolver
+		path     string
+		service  string
+		method   string
+	}{
+		// foo was registered first, foo.bar has the longest prefix
+		{longest, "/foo/bar/baz", "go.micro.api.foo.bar", "Bar.Baz"},
+		{first, "/foo/bar/baz", "go.micro.api.foo", "Bar.Baz"},
+		// only foo serves /foo/baz/cat
+		{longest, "/foo/baz/cat", "go.micro.api.foo", "Baz.Cat"},
+		// no conflict without a third part
+		{first, "/foo/bar", "go.micro.api.foo", "Foo.Bar"},
+	}
+
+	for _, d := range testData {
+		ep, err := d.resolver.Resolve(httptest.NewRequest("POST", d.path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ep.Name != d.service || ep.Method != d.method {
+			t.Fatalf("Expected %s %s for %s got %s %s", d.service, d.method, d.path, ep.Name, ep.Method)
+		}
+	}
+
+	// a new node of foo.bar doesn't change the order
+	s := service("foo.bar")
+	s.Nodes[0].Id = "foo.bar-2"
+	if err := reg.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	if first.position("go.micro.api.foo") != 0 || first.position("go.micro.api.foo.bar") != 1 {
+		t.Fatalf("Expected registration order to be kept")
+	}
+}
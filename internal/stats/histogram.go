@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"sort"
+	"time"
+)
+
+// default upper bounds of histogram buckets in seconds
+var defaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// histogram counts observed durations into buckets
+type histogram struct {
+	// upper bounds of the buckets in seconds
+	Buckets []float64 `json:"buckets"`
+	// counts per bucket, the last counting those above every bound
+	Counts []int   `json:"counts"`
+	Sum    float64 `json:"sum"`
+	Count  int     `json:"count"`
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		Buckets: defaultBuckets,
+		Counts:  make([]int, len(defaultBuckets)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	i := sort.SearchFloat64s(h.Buckets, v)
+	h.Counts[i]++
+	h.Sum += v
+	h.Count++
+}
+
+// Observe records the duration in the histogram of the name and label
+// e.g the time a request to a service waited for a slot
+func (s *stats) Observe(name, label string, d time.Duration) {
+	s.Lock()
+	counter := s.Counters[len(s.Counters)-1]
+	if counter.Histograms[name] == nil {
+		counter.Histograms[name] = make(map[string]*histogram)
+	}
+	h, ok := counter.Histograms[name][label]
+	if !ok {
+		h = newHistogram()
+		counter.Histograms[name][label] = h
+	}
+	h.observe(d)
+	s.Unlock()
+}
+
+// RecordLabel counts t against the label of the name e.g rejections
+// broken down by service
+func (s *stats) RecordLabel(name, label string, t int) {
+	s.Lock()
+	counter := s.Counters[len(s.Counters)-1]
+	if counter.Labels[name] == nil {
+		counter.Labels[name] = make(map[string]int)
+	}
+	counter.Labels[name][label] += t
+	s.Unlock()
+}
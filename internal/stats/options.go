@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"net/http"
+
+	"github.com/micro/go-micro/v2/api/resolver"
+)
+
+// Options for the stats
+type Options struct {
+	// Dimensions to break down requests by e.g route
+	Dimensions []string
+	// Status reports the state of the server e.g draining
+	Status func() string
+}
+
+// Option sets a stats option
+type Option func(o *Options)
+
+// Dimensions breaks down the requests counted by the given dimensions
+func Dimensions(d ...string) Option {
+	return func(o *Options) {
+		o.Dimensions = append(o.Dimensions, d...)
+	}
+}
+
+// Status reports the state of the server returned by fn in the stats
+func Status(fn func() string) Option {
+	return func(o *Options) {
+		o.Status = fn
+	}
+}
+
+// dimensions map a dimension name to the value of a request
+var dimensions = map[string]func(r *http.Request, status int) string{
+	// name of the service the request resolved to e.g go.micro.api.foo,
+	// set in the request context when it's resolved
+	"route": func(r *http.Request, status int) string {
+		if ep, ok := r.Context().Value(resolver.Endpoint{}).(*resolver.Endpoint); ok && ep != nil && len(ep.Name) > 0 {
+			return ep.Name
+		}
+		return "unresolved"
+	},
+	"method": func(r *http.Request, status int) string {
+		return r.Method
+	},
+	"host": func(r *http.Request, status int) string {
+		return r.Host
+	},
+	// status class e.g 20x
+	"status": func(r *http.Request, status int) string {
+		return statusClass(status)
+	},
+}
+
+// ValidDimension determines whether the dimension is supported
+func ValidDimension(d string) bool {
+	_, ok := dimensions[d]
+	return ok
+}
@@ -14,12 +14,14 @@ type stats struct {
 	sync.RWMutex
 
 	Started int64  `json:"started"`
+	Status  string `json:"status,omitempty"`
 	Memory  string `json:"memory"`
 	Threads int    `json:"threads"`
 	GC      string `json:"gc_pause"`
 
 	Counters []*counter `json:"counters"`
 
+	opts    Options
 	running bool
 	exit    chan bool
 }
@@ -30,6 +32,22 @@ type counter struct {
 	// counters
 	Status map[string]int `json:"status_codes"`
 	Total  int            `json:"total_reqs"`
+	// requests broken down by dimension
+	Dimensions map[string]map[string]int `json:"dimensions,omitempty"`
+	// events counted by name and label e.g rejections per service
+	Labels map[string]map[string]int `json:"labels,omitempty"`
+	// durations observed by name and label
+	Histograms map[string]map[string]*histogram `json:"histograms,omitempty"`
+}
+
+func newCounter() *counter {
+	return &counter{
+		Timestamp:  time.Now().Unix(),
+		Status:     make(map[string]int),
+		Dimensions: make(map[string]map[string]int),
+		Labels:     make(map[string]map[string]int),
+		Histograms: make(map[string]map[string]*histogram),
+	}
 }
 
 var (
@@ -69,10 +87,7 @@ func (s *stats) run() {
 		case <-t.C:
 			// roll
 			s.Lock()
-			s.Counters = append(s.Counters, &counter{
-				Timestamp: time.Now().Unix(),
-				Status:    make(map[string]int),
-			})
+			s.Counters = append(s.Counters, newCounter())
 			if len(s.Counters) >= total {
 				s.Counters = s.Counters[1:]
 			}
@@ -100,33 +115,61 @@ func (s *stats) Record(c string, t int) {
 	s.Unlock()
 }
 
+// recordDimensions counts the request against the configured dimensions
+func (s *stats) recordDimensions(r *http.Request, status int) {
+	if len(s.opts.Dimensions) == 0 {
+		return
+	}
+
+	s.Lock()
+	counter := s.Counters[len(s.Counters)-1]
+	for _, d := range s.opts.Dimensions {
+		fn, ok := dimensions[d]
+		if !ok {
+			continue
+		}
+		if counter.Dimensions[d] == nil {
+			counter.Dimensions[d] = make(map[string]int)
+		}
+		counter.Dimensions[d][fn(r, status)]++
+	}
+	s.Unlock()
+}
+
+// statusClass returns the class of the status code e.g 20x
+func statusClass(status int) string {
+	switch {
+	case status >= 500:
+		return "50x"
+	case status >= 400:
+		return "40x"
+	case status >= 300:
+		return "30x"
+	case status >= 200:
+		return "20x"
+	}
+	return ""
+}
+
 func (s *stats) ServeHTTP(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var code string
 		rw := &writer{w, 200}
 
-		h.ServeHTTP(w, r)
-
-		switch {
-		case rw.status >= 500:
-			code = "50x"
-		case rw.status >= 400:
-			code = "40x"
-		case rw.status >= 300:
-			code = "30x"
-		case rw.status >= 200:
-			code = "20x"
-		}
+		h.ServeHTTP(rw, r)
 
-		s.Record(code, 1)
+		s.Record(statusClass(rw.status), 1)
+		s.recordDimensions(r, rw.status)
 	})
 }
 
 func (s *stats) StatsHandler(w http.ResponseWriter, r *http.Request) {
 	if ct := r.Header.Get("Content-Type"); ct == "application/json" {
-		s.RLock()
+		s.Lock()
+		if s.opts.Status != nil {
+			s.Status = s.opts.Status()
+		}
 		b, err := json.Marshal(s)
-		s.RUnlock()
+		s.Unlock()
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -166,19 +209,20 @@ func (s *stats) Stop() error {
 	return nil
 }
 
-func New() *stats {
+func New(opts ...Option) *stats {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+
 	var mstat runtime.MemStats
 	runtime.ReadMemStats(&mstat)
 
 	return &stats{
-		Threads: runtime.NumGoroutine(),
-		Memory:  fmt.Sprintf("%.2fmb", float64(mstat.Alloc)/float64(1024*1024)),
-		GC:      fmt.Sprintf("%.3fms", float64(mstat.PauseTotalNs)/(1000*1000)),
-		Counters: []*counter{
-			&counter{
-				Timestamp: time.Now().Unix(),
-				Status:    make(map[string]int),
-			},
-		},
+		opts:     options,
+		Threads:  runtime.NumGoroutine(),
+		Memory:   fmt.Sprintf("%.2fmb", float64(mstat.Alloc)/float64(1024*1024)),
+		GC:       fmt.Sprintf("%.3fms", float64(mstat.PauseTotalNs)/(1000*1000)),
+		Counters: []*counter{newCounter()},
 	}
 }
@@ -1,7 +1,13 @@
 package stats
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/api/resolver"
 )
 
 func TestStats(t *testing.T) {
@@ -41,3 +47,74 @@ func TestStats(t *testing.T) {
 		}
 	}
 }
+
+func TestDimensions(t *testing.T) {
+	s := New(Dimensions("route", "method", "status"))
+
+	h := s.ServeHTTP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bar" {
+			w.WriteHeader(404)
+			return
+		}
+		// resolve the request as the auth wrapper does
+		ep := &resolver.Endpoint{Name: "go.micro.api.foo"}
+		*r = *r.Clone(context.WithValue(r.Context(), resolver.Endpoint{}, ep))
+	}))
+
+	for _, path := range []string{"/foo/a", "/foo/b", "/bar"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	d := s.Counters[0].Dimensions
+
+	testData := []struct {
+		dimension string
+		value     string
+		count     int
+	}{
+		// routes are the services resolved so the paths don't matter
+		{"route", "go.micro.api.foo", 2},
+		{"route", "unresolved", 1},
+		{"method", "GET", 3},
+		{"status", "20x", 2},
+		{"status", "40x", 1},
+	}
+
+	for _, td := range testData {
+		if c := d[td.dimension][td.value]; c != td.count {
+			t.Fatalf("Expected %s %s count %d got %d", td.dimension, td.value, td.count, c)
+		}
+	}
+
+	if c := s.Counters[0].Status["40x"]; c != 1 {
+		t.Fatalf("Expected 1 40x status got %d", c)
+	}
+}
+
+func TestHistograms(t *testing.T) {
+	s := New()
+
+	s.Observe("wait", "go.micro.api.foo", time.Millisecond*3)
+	s.Observe("wait", "go.micro.api.foo", time.Second*20)
+	s.Observe("wait", "go.micro.api.bar", 0)
+	s.RecordLabel("rejected", "go.micro.api.foo", 1)
+
+	h := s.Counters[0].Histograms["wait"]["go.micro.api.foo"]
+	if h == nil || h.Count != 2 {
+		t.Fatalf("Expected 2 observations got %+v", h)
+	}
+	// 3ms falls in the 5ms bucket and 20s above every bucket
+	if h.Counts[1] != 1 || h.Counts[len(h.Counts)-1] != 1 {
+		t.Fatalf("Expected observations in the 5ms and overflow buckets got %v", h.Counts)
+	}
+	if c := s.Counters[0].Histograms["wait"]["go.micro.api.bar"].Counts[0]; c != 1 {
+		t.Fatalf("Expected observation in the first bucket got %d", c)
+	}
+	// labels are kept apart from the dimensions of requests
+	if _, ok := s.Counters[0].Dimensions["rejected"]; ok {
+		t.Fatal("Expected labels not to be recorded as dimensions")
+	}
+	if c := s.Counters[0].Labels["rejected"]["go.micro.api.foo"]; c != 1 {
+		t.Fatalf("Expected 1 rejection got %d", c)
+	}
+}
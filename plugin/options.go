@@ -11,6 +11,7 @@ type Options struct {
 	Commands []*cli.Command
 	Handlers []Handler
 	Init     func(*cli.Context) error
+	Status   func() error
 }
 
 type Option func(o *Options)
@@ -49,3 +50,11 @@ func WithInit(fn func(*cli.Context) error) Option {
 		o.Init = fn
 	}
 }
+
+// WithStatus sets the function reporting the health of the plugin,
+// plugins without one are always healthy
+func WithStatus(fn func() error) Option {
+	return func(o *Options) {
+		o.Status = fn
+	}
+}
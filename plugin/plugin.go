@@ -56,6 +56,14 @@ func (p *plugin) Init(ctx *cli.Context) error {
 	return p.opts.Init(ctx)
 }
 
+// Status reports the health of the plugin, nil if it's healthy
+func (p *plugin) Status() error {
+	if p.opts.Status == nil {
+		return nil
+	}
+	return p.opts.Status()
+}
+
 func (p *plugin) String() string {
 	return p.opts.Name
 }